@@ -19,6 +19,16 @@ import (
 var ver = "dev"
 var DefaultConfig = "config.yaml"
 
+// exitHooks os.Exit不会执行defer, 强制退出前依次执行, 例如恢复终端
+var exitHooks []func()
+
+func exit(code int) {
+	for _, hook := range exitHooks {
+		hook()
+	}
+	os.Exit(code)
+}
+
 func init() {
 	logs.Log.SetColorMap(map[logs.Level]func(string) string{
 		logs.Info:      logs.PurpleBold,
@@ -30,13 +40,142 @@ func init() {
 func Spray() {
 	var option internal.Option
 
-	if files.IsExist(DefaultConfig) {
-		logs.Log.Debug("config.yaml exist, loading")
-		err := internal.LoadConfig(DefaultConfig, &option)
+	if len(os.Args) > 1 && os.Args[1] == "convert" {
+		if len(os.Args) < 3 {
+			fmt.Println("usage: spray convert <input> [output], convert result file between json and msgpack")
+			return
+		}
+		var dst string
+		if len(os.Args) > 3 {
+			dst = os.Args[3]
+		}
+		if err := internal.Convert(os.Args[2], dst); err != nil {
+			logs.Log.Error(err.Error())
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "verify" {
+		var opts internal.VerifyOptions
+		parser := flags.NewParser(&opts, flags.Default)
+		parser.Usage = "verify [OPTIONS] <result.json>..."
+		args, err := parser.ParseArgs(os.Args[2:])
+		if err != nil {
+			return
+		}
+		if len(args) == 0 {
+			parser.WriteHelp(os.Stdout)
+			return
+		}
+		for _, filename := range args {
+			if err := internal.Verify(filename, &opts); err != nil {
+				logs.Log.Error(err.Error())
+			}
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "expr-test" {
+		var opts internal.ExprTestOptions
+		parser := flags.NewParser(&opts, flags.Default)
+		parser.Usage = "expr-test [OPTIONS]"
+		if _, err := parser.ParseArgs(os.Args[2:]); err != nil {
+			return
+		}
+		if err := internal.ExprTest(&opts); err != nil {
+			logs.Log.Error(err.Error())
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "rule-test" {
+		var opts internal.RuleTestOptions
+		parser := flags.NewParser(&opts, flags.Default)
+		parser.Usage = "rule-test [OPTIONS] [words...]"
+		args, err := parser.ParseArgs(os.Args[2:])
+		if err != nil {
+			return
+		}
+		if err := pkg.Load(); err != nil {
+			iutils.Fatal(err.Error())
+		}
+		if err := internal.RuleTest(&opts, args); err != nil {
+			logs.Log.Error(err.Error())
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "server" {
+		var opts internal.ServerOptions
+		parser := flags.NewParser(&opts, flags.Default)
+		parser.Usage = "server [OPTIONS] -- <scan options>"
+		args, err := parser.ParseArgs(os.Args[2:])
+		if err != nil {
+			return
+		}
+		if _, err := internal.ParseWithConfig(flags.NewParser(&option, flags.Default), &option, args, DefaultConfig); err != nil {
+			return
+		}
+		logs.AddLevel(pkg.LogVerbose, "verbose", "[=] %s {{suffix}}")
+		if err := internal.RunServer(&opts, &option); err != nil {
+			logs.Log.Error(err.Error())
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "daemon" {
+		var opts internal.DaemonOptions
+		parser := flags.NewParser(&opts, flags.Default)
+		parser.Usage = "daemon [OPTIONS] -- [default scan options]"
+		args, err := parser.ParseArgs(os.Args[2:])
 		if err != nil {
+			return
+		}
+		if _, err := internal.ParseWithConfig(flags.NewParser(&option, flags.Default), &option, args, DefaultConfig); err != nil {
+			return
+		}
+		logs.AddLevel(pkg.LogVerbose, "verbose", "[=] %s {{suffix}}")
+		if err := internal.RunDaemon(&opts, &option); err != nil {
+			logs.Log.Error(err.Error())
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "words" {
+		parser := flags.NewParser(&option, flags.Default)
+		parser.Usage = "words [OPTIONS]"
+		if _, err := internal.ParseWithConfig(parser, &option, os.Args[2:], DefaultConfig); err != nil {
+			return
+		}
+		logs.AddLevel(pkg.LogVerbose, "verbose", "[=] %s {{suffix}}")
+		if len(option.Verbose) > 0 {
+			logs.Log.SetLevel(pkg.LogVerbose)
+		}
+		if err := pkg.Load(); err != nil {
+			iutils.Fatal(err.Error())
+		}
+		if err := internal.PreviewWords(&option); err != nil {
 			logs.Log.Error(err.Error())
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "worker" {
+		var opts internal.WorkerOptions
+		parser := flags.NewParser(&opts, flags.Default)
+		parser.Usage = "worker [OPTIONS] -- [proxy and credential options]"
+		args, err := parser.ParseArgs(os.Args[2:])
+		if err != nil {
+			return
+		}
+		if _, err := internal.ParseWithConfig(flags.NewParser(&option, flags.Default), &option, args, DefaultConfig); err != nil {
 			return
 		}
+		logs.AddLevel(pkg.LogVerbose, "verbose", "[=] %s {{suffix}}")
+		if err := internal.RunWorker(&opts, &option); err != nil {
+			logs.Log.Error(err.Error())
+		}
+		return
 	}
 
 	parser := flags.NewParser(&option, flags.Default)
@@ -65,11 +204,38 @@ func Spray() {
 
     resume:
       spray --resume stat.json
+
+    preview generated words and exact total without sending requests:
+      spray words -w '/api/{?0}{?@ext}' -d 1.txt -r rule.txt --limit 50
+
+    preview how hashcat rules transform sample words, then scan with them:
+      spray rule-test -r best64.rule password admin
+      spray -u http://example.com -d 1.txt -r best64.rule --rule-syntax hashcat
+
+    convert result file between json and msgpack:
+      spray convert result.msgpack result.json
+
+    re-request findings and mark confirmed, changed or gone:
+      spray verify result.json --proxy http://127.0.0.1:8080 -f verified.json
+
+    test match/filter expression against saved response:
+      spray expr-test --match 'current.Status == 200 && current.Words > 10' --response 404.http
+
+    distributed scan, master split wordlist into shards and dispatch to workers:
+      spray server --listen 0.0.0.0:9527 --token secret -- -l url.txt -d 1.txt
+      spray worker --master 10.0.0.1:9527 --token secret
+      spray worker --master 10.0.0.1:9527 --token secret -- --proxy socks5://127.0.0.1:1080 --header 'Authorization: Bearer xxx'
+
+    rest api daemon, submit scan with json of options:
+      spray daemon --listen 127.0.0.1:8080 --token secret
+      curl -H 'X-Spray-Token: secret' -H 'Content-Type: application/json' -d '{"URL": ["http://example.com"], "Dictionaries": ["1.txt"]}' http://127.0.0.1:8080/scans
 `
 
-	_, err := parser.Parse()
+	_, err := internal.ParseWithConfig(parser, &option, os.Args[1:], DefaultConfig)
 	if err != nil {
-		if err.(*flags.Error).Type != flags.ErrHelp {
+		if ferr, ok := err.(*flags.Error); !ok {
+			logs.Log.Error(err.Error())
+		} else if ferr.Type != flags.ErrHelp {
 			fmt.Println(err.Error())
 		}
 		return
@@ -96,11 +262,6 @@ func Spray() {
 		return
 	}
 	if option.Config != "" {
-		err := internal.LoadConfig(option.Config, &option)
-		if err != nil {
-			logs.Log.Error(err.Error())
-			return
-		}
 		if files.IsExist(DefaultConfig) {
 			logs.Log.Warnf("custom config %s, override default config", option.Config)
 		} else {
@@ -128,7 +289,7 @@ func Spray() {
 		return
 	}
 
-	if option.Format != "" {
+	if option.Format != "" && !pkg.IsOutputTemplate(option.Format) {
 		internal.Format(option)
 		return
 	}
@@ -148,13 +309,18 @@ func Spray() {
 		ihttp.DefaultMaxBodySize = -1
 	}
 
-	ctx, canceler := context.WithTimeout(context.Background(), time.Duration(runner.Deadline)*time.Second)
+	// 暂停期间不计入deadline
+	ctx, canceler := runner.Pauser.WithTimeout(context.Background(), time.Duration(runner.Deadline)*time.Second)
+	restore := watchKeyboard(runner, canceler)
+	defer restore()
+	exitHooks = append(exitHooks, restore)
 	go func() {
 		select {
 		case <-ctx.Done():
 			time.Sleep(10 * time.Second)
 			logs.Log.Errorf("deadline and timeout not work, hard exit!!!")
-			os.Exit(0)
+			runner.Snapshot()
+			exit(0)
 		}
 	}()
 
@@ -172,13 +338,21 @@ func Spray() {
 					canceler()
 				} else if sigCount == 2 {
 					logs.Log.Infof("forcing exit...")
-					os.Exit(1)
+					// 强制退出时正在运行的pool来不及保存, 保存完整快照后再退出
+					runner.Snapshot()
+					exit(1)
 				}
 			}
 		}()
 	}()
 
+	// 只重新加载-c指定的配置文件, 默认的config.yaml可能与本次扫描无关
+	if option.Config != "" {
+		watchReload(runner, option.Config)
+	}
+
 	err = runner.Prepare(ctx)
+	runner.Close()
 	if err != nil {
 		logs.Log.Errorf(err.Error())
 		return
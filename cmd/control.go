@@ -0,0 +1,58 @@
+//go:build !windows
+
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/chainreactors/logs"
+	"github.com/chainreactors/spray/internal"
+	"golang.org/x/term"
+)
+
+// watchKeyboard 在终端中显示进度条时读取按键控制扫描, 通过stty关闭行缓冲与回显, 保留ctrl+c等信号, 返回恢复终端的函数
+func watchKeyboard(runner *internal.Runner, canceler context.CancelFunc) func() {
+	if runner.Progress == nil || !term.IsTerminal(int(os.Stdin.Fd())) {
+		return func() {}
+	}
+	saved, err := stty("-g")
+	if err != nil {
+		logs.Log.Debugf("[control] %s", err.Error())
+		return func() {}
+	}
+	if _, err := stty("-icanon", "-echo", "min", "1"); err != nil {
+		logs.Log.Debugf("[control] %s", err.Error())
+		return func() {}
+	}
+	logs.Log.Important("[control] " + internal.ControlHelp)
+
+	go func() {
+		buf := make([]byte, 1)
+		for {
+			if n, err := os.Stdin.Read(buf); err != nil {
+				return
+			} else if n == 1 && runner.HandleKey(buf[0]) {
+				canceler()
+				return
+			}
+		}
+	}()
+	// 正常退出与强制退出都会调用, 只恢复一次
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			stty(strings.TrimSpace(saved))
+		})
+	}
+}
+
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	out, err := cmd.Output()
+	return string(out), err
+}
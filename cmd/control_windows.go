@@ -0,0 +1,14 @@
+//go:build windows
+
+package cmd
+
+import (
+	"context"
+
+	"github.com/chainreactors/spray/internal"
+)
+
+// windows 暂不支持键盘控制
+func watchKeyboard(runner *internal.Runner, canceler context.CancelFunc) func() {
+	return func() {}
+}
@@ -0,0 +1,30 @@
+//go:build !windows
+
+package cmd
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/chainreactors/files"
+	"github.com/chainreactors/logs"
+	"github.com/chainreactors/spray/internal"
+)
+
+// watchReload 收到SIGHUP时从配置文件中重新加载部分运行时配置
+func watchReload(runner *internal.Runner, filename string) {
+	hupChan := make(chan os.Signal, 1)
+	signal.Notify(hupChan, syscall.SIGHUP)
+	go func() {
+		for range hupChan {
+			if filename == "" || !files.IsExist(filename) {
+				logs.Log.Warn("[reload] SIGHUP received, but no config file to reload")
+				continue
+			}
+			if err := runner.Reload(filename); err != nil {
+				logs.Log.Errorf("[reload] %s", err.Error())
+			}
+		}
+	}()
+}
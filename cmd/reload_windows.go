@@ -0,0 +1,8 @@
+//go:build windows
+
+package cmd
+
+import "github.com/chainreactors/spray/internal"
+
+// windows 不支持SIGHUP
+func watchReload(runner *internal.Runner, filename string) {}
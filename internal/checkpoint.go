@@ -0,0 +1,138 @@
+package internal
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/chainreactors/logs"
+	"github.com/chainreactors/spray/internal/pool"
+	"github.com/chainreactors/spray/pkg"
+)
+
+// Checkpoint 定期将所有目标的stat原子地重写到stat文件, 包括已经完成的目标, 正在运行的目标的当前进度, 以及尚未开始的递归目录,
+// 进程崩溃或被OOM kill后stat文件仍然是完整的, 可以通过--resume继续
+type Checkpoint struct {
+	Filename string
+	Interval time.Duration
+	Requests int
+
+	finished []string // 已经完成的目标的stat
+	reqs     int64    // 已经完成的目标的请求数
+	locker   sync.Mutex
+}
+
+func NewCheckpoint(filename string, interval, requests int) *Checkpoint {
+	return &Checkpoint{
+		Filename: filename,
+		Interval: time.Duration(interval) * time.Second,
+		Requests: requests,
+	}
+}
+
+// Add 记录已经完成的目标, 在下一次Save时写入
+func (c *Checkpoint) Add(stat *pkg.Statistor) {
+	c.locker.Lock()
+	defer c.locker.Unlock()
+	c.finished = append(c.finished, stat.Json())
+	atomic.AddInt64(&c.reqs, int64(stat.ReqTotal))
+}
+
+// Save 写入临时文件后rename覆盖stat文件, 保证任何时候读到的stat文件都是完整的
+func (c *Checkpoint) Save(progress []*pkg.Statistor) error {
+	c.locker.Lock()
+	defer c.locker.Unlock()
+	tmp, err := os.CreateTemp(filepath.Dir(c.Filename), filepath.Base(c.Filename)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	for _, content := range c.finished {
+		w.WriteString(content)
+	}
+	for _, stat := range progress {
+		w.WriteString(stat.Json())
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), c.Filename)
+}
+
+// progress 正在运行的目标的当前进度, 以及已经加入但尚未开始的任务, 同一个目标只保留一次
+func (r *Runner) progress() []*pkg.Statistor {
+	var stats []*pkg.Statistor
+	seen := make(map[string]struct{})
+	r.activePools.Range(func(_, v interface{}) bool {
+		stat := v.(*pool.BrutePool).Statistor.Progress()
+		seen[stat.BaseUrl] = struct{}{}
+		stats = append(stats, stat)
+		return true
+	})
+	r.pending.Range(func(k, _ interface{}) bool {
+		t := k.(*Task)
+		if _, ok := seen[t.baseUrl]; !ok {
+			seen[t.baseUrl] = struct{}{}
+			stats = append(stats, t.Stat())
+		}
+		return true
+	})
+	return stats
+}
+
+// saveCheckpoint 跳过已经完成的目标, 避免与Add记录的stat重复
+func (r *Runner) saveCheckpoint() {
+	progress := r.progress()
+	r.statLocker.Lock()
+	defer r.statLocker.Unlock()
+	if r.snapshotted {
+		return
+	}
+	var stats []*pkg.Statistor
+	for _, stat := range progress {
+		if _, ok := r.recorded[stat.BaseUrl]; !ok {
+			stats = append(stats, stat)
+		}
+	}
+	if err := r.Checkpoint.Save(stats); err != nil {
+		logs.Log.Warnf("[checkpoint] %s", err.Error())
+	}
+}
+
+// runCheckpoint 每秒检查一次, 距离上次保存超过--checkpoint秒或新增--checkpoint-requests个请求时保存
+func (r *Runner) runCheckpoint(stop chan struct{}) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	last := time.Now()
+	var lastReqs int64
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			reqs := atomic.LoadInt64(&r.Checkpoint.reqs)
+			r.activePools.Range(func(_, v interface{}) bool {
+				reqs += int64(atomic.LoadInt32(&v.(*pool.BrutePool).Statistor.ReqTotal))
+				return true
+			})
+			if (r.Checkpoint.Interval > 0 && time.Since(last) >= r.Checkpoint.Interval) ||
+				(r.Checkpoint.Requests > 0 && reqs-lastReqs >= int64(r.Checkpoint.Requests)) {
+				r.saveCheckpoint()
+				last, lastReqs = time.Now(), reqs
+			}
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/chainreactors/files"
+	"github.com/chainreactors/logs"
+	"github.com/chainreactors/spray/pkg"
+)
+
+// Chunk 一个分块内的目标与其独立的stat文件, 失败时只需要 --resume 对应的stat文件
+type Chunk struct {
+	Index    int      `json:"index"`
+	StatFile string   `json:"stat_file"`
+	Targets  []string `json:"targets"`
+	Finished int      `json:"finished"`
+	Failed   int      `json:"failed"`
+
+	file *files.File
+}
+
+// ChunkManifest 按 --chunk-size 对目标分块, 记录每个分块的stat文件与完成情况
+type ChunkManifest struct {
+	Name      string   `json:"name"`
+	ChunkSize int      `json:"chunk_size"`
+	Chunks    []*Chunk `json:"chunks"`
+
+	filename string
+	targets  map[string]*Chunk
+	locker   sync.Mutex
+}
+
+func NewChunkManifest(name string, size int) *ChunkManifest {
+	return &ChunkManifest{
+		Name:      name,
+		ChunkSize: size,
+		filename:  name + ".manifest.json",
+		targets:   make(map[string]*Chunk),
+	}
+}
+
+// Assign 按任务到达的顺序分配分块, 每满ChunkSize个目标创建新的分块与stat文件
+func (m *ChunkManifest) Assign(baseUrl string) error {
+	m.locker.Lock()
+	defer m.locker.Unlock()
+	if _, ok := m.targets[baseUrl]; ok {
+		return nil
+	}
+	if len(m.Chunks) == 0 || len(m.Chunks[len(m.Chunks)-1].Targets) >= m.ChunkSize {
+		chunk := &Chunk{
+			Index:    len(m.Chunks),
+			StatFile: fmt.Sprintf("%s.chunk%d.stat", m.Name, len(m.Chunks)),
+		}
+		file, err := files.NewFile(chunk.StatFile, false, true, true)
+		if err != nil {
+			return err
+		}
+		file.Mod = os.O_WRONLY | os.O_CREATE
+		if err = file.Init(); err != nil {
+			return err
+		}
+		chunk.file = file
+		m.Chunks = append(m.Chunks, chunk)
+		logs.Log.Importantf("[chunk] start chunk %d, stat save to %s", chunk.Index, chunk.StatFile)
+	}
+	chunk := m.Chunks[len(m.Chunks)-1]
+	chunk.Targets = append(chunk.Targets, baseUrl)
+	m.targets[baseUrl] = chunk
+	return m.save()
+}
+
+// SaveStat 将stat写入目标所在分块的stat文件, 不属于任何分块(例如递归产生的任务)时返回false
+func (m *ChunkManifest) SaveStat(stat *pkg.Statistor) bool {
+	m.locker.Lock()
+	defer m.locker.Unlock()
+	chunk, ok := m.targets[stat.BaseUrl]
+	if !ok {
+		return false
+	}
+	chunk.file.SafeWrite(stat.Json())
+	chunk.file.SafeSync()
+	chunk.Finished++
+	if stat.Error != "" {
+		chunk.Failed++
+	}
+	if chunk.Finished == len(chunk.Targets) {
+		logs.Log.Importantf("[chunk] chunk %d finished, %d targets, %d failed", chunk.Index, chunk.Finished, chunk.Failed)
+	}
+	if err := m.save(); err != nil {
+		logs.Log.Warn(err.Error())
+	}
+	return true
+}
+
+func (m *ChunkManifest) save() error {
+	content, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(m.filename, content, 0o644)
+}
+
+// Close 等待所有分块的stat写入文件, 用于强制退出之前
+func (m *ChunkManifest) Close() {
+	m.locker.Lock()
+	defer m.locker.Unlock()
+	for _, chunk := range m.Chunks {
+		if chunk.file != nil && !chunk.file.Closed {
+			chunk.file.Close()
+		}
+	}
+}
@@ -0,0 +1,281 @@
+package internal
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/chainreactors/spray/pkg"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// 分布式扫描: master(spray server)展开目标与字典, 按字典分片生成任务, worker(spray worker)通过grpc拉取任务,
+// 扫描过程中定时上报结果与stat, master汇总后统一输出, 递归由master根据上报的结果重新分发
+
+const (
+	tokenHeader   = "X-Spray-Token"
+	tokenMetadata = "x-spray-token"
+)
+
+var ErrClusterFinished = errors.New("master finished all jobs")
+
+// newToken 没有指定--token时随机生成, server与daemon总是需要认证
+func newToken() string {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+func checkToken(req *http.Request, token string) bool {
+	return subtle.ConstantTimeCompare([]byte(req.Header.Get(tokenHeader)), []byte(token)) == 1
+}
+
+type ServerOptions struct {
+	Listen    string `long:"listen" default:"0.0.0.0:9527" description:"String, grpc listen address of master, e.g.: --listen 0.0.0.0:9527"`
+	Token     string `long:"token" description:"String, shared token between master and worker, random token will be generated if not set"`
+	ShardSize int    `long:"shard-size" default:"10000" description:"Int, words of each shard, every target is split into len(wordlist)/shard-size jobs"`
+	Lease     int    `long:"lease" default:"300" description:"Int, re-dispatch job when worker has not reported for seconds"`
+}
+
+type WorkerOptions struct {
+	Master string `long:"master" required:"true" description:"String, grpc address of master, e.g.: --master 10.0.0.1:9527"`
+	Token  string `long:"token" required:"true" description:"String, shared token between master and worker"`
+	Name   string `long:"name" description:"String, worker name, default hostname"`
+	Poll   int    `long:"poll" default:"3" description:"Int, poll interval (seconds) when master has no pending job"`
+}
+
+// ClusterJob 一个目标与一个字典分片, Option为master解析后去掉凭证的配置
+type ClusterJob struct {
+	ID     string          `json:"id"`
+	URL    string          `json:"url"`
+	Depth  int             `json:"depth"`
+	Shard  int             `json:"shard"`
+	Words  []string        `json:"words"`
+	Option json.RawMessage `json:"option"`
+}
+
+// ClusterReport worker定时上报的结果与stat, 同时作为任务的心跳续约
+type ClusterReport struct {
+	Job       string            `json:"job"`
+	Baselines []json.RawMessage `json:"baselines,omitempty"`
+	Stats     []*pkg.Statistor  `json:"stats,omitempty"`
+	Done      bool              `json:"done,omitempty"`
+	Error     string            `json:"error,omitempty"`
+}
+
+type ClusterFetch struct {
+	Worker string `json:"worker"`
+}
+
+// ClusterFetchReply 没有待分发的任务时Job为空, master已经结束时Finished为true
+type ClusterFetchReply struct {
+	Job      *ClusterJob `json:"job,omitempty"`
+	Finished bool        `json:"finished,omitempty"`
+}
+
+type ClusterReportReply struct{}
+
+// clusterCodec 消息使用json编码, 不需要protoc生成代码, 与stat和结果文件的格式一致
+type clusterCodec struct{}
+
+func (clusterCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (clusterCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+func (clusterCodec) Name() string {
+	return "json"
+}
+
+func init() {
+	encoding.RegisterCodec(clusterCodec{})
+}
+
+// clusterService spray.Cluster的grpc服务, 由master实现
+type clusterService interface {
+	Fetch(ctx context.Context, req *ClusterFetch) (*ClusterFetchReply, error)
+	Report(ctx context.Context, req *ClusterReport) (*ClusterReportReply, error)
+}
+
+var clusterServiceDesc = grpc.ServiceDesc{
+	ServiceName: "spray.Cluster",
+	HandlerType: (*clusterService)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Fetch",
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+				in := new(ClusterFetch)
+				if err := dec(in); err != nil {
+					return nil, err
+				}
+				handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+					return srv.(clusterService).Fetch(ctx, req.(*ClusterFetch))
+				}
+				if interceptor == nil {
+					return handler(ctx, in)
+				}
+				return interceptor(ctx, in, &grpc.UnaryServerInfo{Server: srv, FullMethod: "/spray.Cluster/Fetch"}, handler)
+			},
+		},
+		{
+			MethodName: "Report",
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+				in := new(ClusterReport)
+				if err := dec(in); err != nil {
+					return nil, err
+				}
+				handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+					return srv.(clusterService).Report(ctx, req.(*ClusterReport))
+				}
+				if interceptor == nil {
+					return handler(ctx, in)
+				}
+				return interceptor(ctx, in, &grpc.UnaryServerInfo{Server: srv, FullMethod: "/spray.Cluster/Report"}, handler)
+			},
+		},
+	},
+	Metadata: "spray/cluster",
+}
+
+// clusterAuth 校验worker携带的token
+func clusterAuth(token string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		values := md.Get(tokenMetadata)
+		if len(values) == 0 || subtle.ConstantTimeCompare([]byte(values[0]), []byte(token)) != 1 {
+			return nil, status.Error(codes.Unauthenticated, "invalid token")
+		}
+		return handler(ctx, req)
+	}
+}
+
+// clusterSecretHeaders 名称中包含这些关键字的header视为凭证
+var clusterSecretHeaders = []string{"authorization", "cookie", "token", "auth", "secret", "key", "session", "signature"}
+
+// stripSecrets 去掉包含凭证的配置(代理, 认证, 签名, 凭证类header与外部输出), master不会将其下发给worker,
+// worker需要时在--之后自行指定, 返回被去掉的配置名
+func stripSecrets(opt *Option) []string {
+	var stripped []string
+	strip := func(name string, set bool) {
+		if set {
+			stripped = append(stripped, name)
+		}
+	}
+	strip("--proxy", opt.Proxy != "")
+	strip("--proxy-file", opt.ProxyFile != "")
+	strip("--replay-proxy", opt.ReplayProxy != "")
+	strip("--cookie", len(opt.Cookie) > 0)
+	strip("--auth-basic", opt.AuthBasic != "")
+	strip("--auth-digest", opt.AuthDigest != "")
+	strip("--ntlm", opt.NTLM != "")
+	strip("--oauth-token-url", opt.OAuthTokenURL != "")
+	strip("--jwt", opt.JWT != "")
+	strip("--sign", opt.Sign != "")
+	strip("--cert", opt.Cert != "")
+	strip("--output", len(opt.Outputs) > 0)
+	strip("--notify", opt.Notify != "")
+	opt.Proxy, opt.ProxyFile, opt.ReplayProxy = "", "", ""
+	opt.Cookie, opt.AuthBasic, opt.AuthDigest, opt.NTLM = nil, "", "", ""
+	opt.OAuthTokenURL, opt.OAuthClientID, opt.OAuthSecret, opt.OAuthScope = "", "", "", ""
+	opt.JWT, opt.JWTKey, opt.Sign, opt.Cert, opt.Key = "", "", "", "", ""
+	opt.Outputs, opt.Notify = nil, ""
+
+	var headers []string
+	for _, h := range opt.Headers {
+		if isSecretHeader(h) {
+			stripped = append(stripped, "--header "+strings.TrimSpace(strings.SplitN(h, ":", 2)[0]))
+			continue
+		}
+		headers = append(headers, h)
+	}
+	opt.Headers = headers
+	return stripped
+}
+
+func isSecretHeader(h string) bool {
+	name := strings.ToLower(strings.SplitN(h, ":", 2)[0])
+	for _, s := range clusterSecretHeaders {
+		if strings.Contains(name, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// applySecrets 使用worker本地指定的凭证, header追加在master下发的header之后
+func applySecrets(opt, local *Option) {
+	opt.Proxy, opt.ProxyFile, opt.ReplayProxy = local.Proxy, local.ProxyFile, local.ReplayProxy
+	opt.Cookie, opt.AuthBasic, opt.AuthDigest, opt.NTLM = local.Cookie, local.AuthBasic, local.AuthDigest, local.NTLM
+	opt.OAuthTokenURL, opt.OAuthClientID, opt.OAuthSecret, opt.OAuthScope = local.OAuthTokenURL, local.OAuthClientID, local.OAuthSecret, local.OAuthScope
+	opt.JWT, opt.JWTKey, opt.Sign, opt.Cert, opt.Key = local.JWT, local.JWTKey, local.Sign, local.Cert, local.Key
+	opt.Headers = append(opt.Headers, local.Headers...)
+}
+
+// clusterClient worker到master的grpc客户端
+type clusterClient struct {
+	master string
+	token  string
+	name   string
+	conn   *grpc.ClientConn
+}
+
+func newClusterClient(opts *WorkerOptions) (*clusterClient, error) {
+	master := opts.Master
+	if i := strings.Index(master, "://"); i != -1 {
+		master = master[i+3:]
+	}
+	master = strings.TrimSuffix(master, "/")
+	conn, err := grpc.NewClient(master,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.CallContentSubtype(clusterCodec{}.Name())))
+	if err != nil {
+		return nil, err
+	}
+	return &clusterClient{
+		master: master,
+		token:  opts.Token,
+		name:   opts.Name,
+		conn:   conn,
+	}, nil
+}
+
+func (c *clusterClient) invoke(method string, req, reply interface{}) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	ctx = metadata.AppendToOutgoingContext(ctx, tokenMetadata, c.token)
+	return c.conn.Invoke(ctx, "/spray.Cluster/"+method, req, reply)
+}
+
+// fetch 拉取任务, 没有待分发的任务时返回nil, master已经结束时返回ErrClusterFinished
+func (c *clusterClient) fetch() (*ClusterJob, error) {
+	var reply ClusterFetchReply
+	if err := c.invoke("Fetch", &ClusterFetch{Worker: c.name}, &reply); err != nil {
+		return nil, err
+	}
+	if reply.Finished {
+		return nil, ErrClusterFinished
+	}
+	return reply.Job, nil
+}
+
+func (c *clusterClient) report(report *ClusterReport) error {
+	return c.invoke("Report", report, &ClusterReportReply{})
+}
+
+func (c *clusterClient) Close() error {
+	return c.conn.Close()
+}
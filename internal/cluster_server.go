@@ -0,0 +1,270 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/chainreactors/logs"
+	"github.com/chainreactors/parsers"
+	"github.com/chainreactors/spray/pkg"
+	"google.golang.org/grpc"
+)
+
+// clusterLease 已分发的任务, 超过lease未上报时重新分发. 上报的stat暂存在lease中, 任务完成后才汇总到目标,
+// 超时重新分发时随lease一起丢弃, 避免同一分片的stat被重复计算
+type clusterLease struct {
+	job      *ClusterJob
+	worker   string
+	deadline time.Time
+	stats    []*pkg.Statistor
+}
+
+// clusterTarget 同一目标所有分片的汇总stat
+type clusterTarget struct {
+	stat   *pkg.Statistor
+	remain int
+}
+
+type clusterMaster struct {
+	*Runner
+	opts       *ServerOptions
+	option     json.RawMessage
+	locker     sync.Mutex
+	seq        int
+	pending    []*ClusterJob
+	running    map[string]*clusterLease
+	targets    map[string]*clusterTarget
+	workers    map[string]struct{}
+	generating bool
+	finished   bool
+	doneCh     chan struct{}
+}
+
+// RunServer spray server, 解析与普通扫描相同的参数, 将目标与字典分片后分发给worker
+func RunServer(opts *ServerOptions, option *Option) error {
+	if option.ResumeFrom != "" || option.RawFile != "" || option.RequestFile != "" || len(option.Payloads) > 0 || len(option.Slots) > 0 {
+		return errors.New("server mode not support --resume, --raw, --request, --payload and --slot")
+	}
+	if option.Offset != 0 || option.Limit != 0 {
+		return errors.New("server mode not support --offset and --limit, wordlist is split by --shard-size")
+	}
+	if opts.ShardSize <= 0 {
+		return errors.New("--shard-size must be positive")
+	}
+	if opts.Token == "" {
+		opts.Token = newToken()
+		logs.Log.Importantf("[server] --token not set, generated token: %s", opts.Token)
+	}
+	if err := option.Prepare(); err != nil {
+		return err
+	}
+	// 在NewRunner修改配置之前保存, worker以此还原完整的扫描配置, 凭证与代理不会下发, 需要在worker的--之后指定
+	secret := *option
+	if stripped := stripSecrets(&secret); len(stripped) > 0 {
+		logs.Log.Warnf("[server] %v will not be sent to workers, pass them to worker after --", stripped)
+	}
+	snapshot, err := json.Marshal(&secret)
+	if err != nil {
+		return err
+	}
+	r, err := option.NewRunner()
+	if err != nil {
+		return err
+	}
+	if r.IsCheck || r.Words.Count == 0 {
+		return errors.New("server mode need wordlist, e.g.: -d 1.txt")
+	}
+	r.Wordlist = r.wordlist()
+
+	m := &clusterMaster{
+		Runner:     r,
+		opts:       opts,
+		option:     snapshot,
+		running:    make(map[string]*clusterLease),
+		targets:    make(map[string]*clusterTarget),
+		workers:    make(map[string]struct{}),
+		generating: true,
+		doneCh:     make(chan struct{}),
+	}
+	r.OutputHandler()
+	go m.generate()
+
+	lis, err := net.Listen("tcp", opts.Listen)
+	if err != nil {
+		return err
+	}
+	server := grpc.NewServer(grpc.UnaryInterceptor(clusterAuth(opts.Token)))
+	server.RegisterService(&clusterServiceDesc, m)
+	go func() {
+		<-m.doneCh
+		// 给worker留出拉取结束状态的时间
+		time.Sleep(2 * time.Second)
+		server.GracefulStop()
+	}()
+	logs.Log.Importantf("[server] listen on %s, %d words, shard size %d", opts.Listen, len(r.Wordlist), opts.ShardSize)
+	if err := server.Serve(lis); err != nil {
+		return err
+	}
+	r.outwg.Wait()
+	r.Close()
+	logs.Log.Importantf("[server] all jobs finished, %d workers joined", len(m.workers))
+	return nil
+}
+
+// generate 消费任务生成器, 每个目标按字典分片生成任务
+func (m *clusterMaster) generate() {
+	for t := range m.Tasks.tasks {
+		if t.template != "" {
+			logs.Log.Warnf("[server] path template %s not support in server mode, skip", t.baseUrl)
+			continue
+		}
+		m.locker.Lock()
+		m.addTarget(t.baseUrl, 0)
+		m.locker.Unlock()
+	}
+	m.locker.Lock()
+	m.generating = false
+	m.checkFinished()
+	m.locker.Unlock()
+}
+
+// addTarget 调用时需要持有locker
+func (m *clusterMaster) addTarget(baseUrl string, depth int) {
+	if _, ok := m.targets[baseUrl]; ok {
+		return
+	}
+	target := &clusterTarget{stat: pkg.NewStatistor(baseUrl)}
+	for i := 0; i*m.opts.ShardSize < len(m.Wordlist); i++ {
+		end := (i + 1) * m.opts.ShardSize
+		if end > len(m.Wordlist) {
+			end = len(m.Wordlist)
+		}
+		m.seq++
+		m.pending = append(m.pending, &ClusterJob{
+			ID:     strconv.Itoa(m.seq),
+			URL:    baseUrl,
+			Depth:  depth,
+			Shard:  i,
+			Words:  m.Wordlist[i*m.opts.ShardSize : end],
+			Option: m.option,
+		})
+		target.remain++
+	}
+	m.targets[baseUrl] = target
+	logs.Log.Logf(pkg.LogVerbose, "[server] %s split into %d jobs", baseUrl, target.remain)
+}
+
+// checkFinished 目标生成完毕且没有待分发与运行中的任务时结束, 调用时需要持有locker
+func (m *clusterMaster) checkFinished() {
+	if m.finished || m.generating || len(m.pending) > 0 || len(m.running) > 0 {
+		return
+	}
+	m.finished = true
+	close(m.doneCh)
+}
+
+// Fetch 分发一个待执行的任务, 暂时没有任务时Job为空, 全部完成时Finished为true
+func (m *clusterMaster) Fetch(ctx context.Context, req *ClusterFetch) (*ClusterFetchReply, error) {
+	worker := req.Worker
+	m.locker.Lock()
+	m.workers[worker] = struct{}{}
+	now := time.Now()
+	for id, lease := range m.running {
+		if now.After(lease.deadline) {
+			// 超时的任务以新的id重新分发, 原worker之后的上报将被忽略, 已上报的stat随lease丢弃
+			logs.Log.Warnf("[server] job %s (%s shard %d) of %s lease expired, re-dispatch", id, lease.job.URL, lease.job.Shard, lease.worker)
+			delete(m.running, id)
+			job := *lease.job
+			m.seq++
+			job.ID = strconv.Itoa(m.seq)
+			m.pending = append(m.pending, &job)
+		}
+	}
+	if m.finished {
+		m.locker.Unlock()
+		return &ClusterFetchReply{Finished: true}, nil
+	}
+	if len(m.pending) == 0 {
+		m.locker.Unlock()
+		return &ClusterFetchReply{}, nil
+	}
+	job := m.pending[0]
+	m.pending = m.pending[1:]
+	m.running[job.ID] = &clusterLease{job: job, worker: worker, deadline: now.Add(time.Duration(m.opts.Lease) * time.Second)}
+	m.locker.Unlock()
+
+	logs.Log.Infof("[server] dispatch job %s (%s shard %d, %d words) to %s", job.ID, job.URL, job.Shard, len(job.Words), worker)
+	return &ClusterFetchReply{Job: job}, nil
+}
+
+// Report 输出上报的结果, 任务完成时汇总stat, 并根据递归规则分发子目录
+func (m *clusterMaster) Report(ctx context.Context, report *ClusterReport) (*ClusterReportReply, error) {
+	m.locker.Lock()
+	lease, ok := m.running[report.Job]
+	if !ok {
+		m.locker.Unlock()
+		logs.Log.Debugf("[server] ignore report of unknown job %s", report.Job)
+		return &ClusterReportReply{}, nil
+	}
+	lease.deadline = time.Now().Add(time.Duration(m.opts.Lease) * time.Second)
+
+	var baselines []*pkg.Baseline
+	for _, raw := range report.Baselines {
+		var bl pkg.Baseline
+		if err := json.Unmarshal(raw, &bl); err != nil {
+			logs.Log.Warn(err.Error())
+			continue
+		}
+		if bl.Source == parsers.InitIndexSource && lease.job.Shard != 0 {
+			// 每个分片都会请求index, 只输出第一个分片的
+			continue
+		}
+		baselines = append(baselines, &bl)
+		if bl.IsValid && lease.job.Depth < m.Depth && m.RecursiveExpr != nil &&
+			pkg.CompareWithExpr(m.RecursiveExpr, pkg.NewExprEnv(&bl, nil, nil)) {
+			m.addTarget(bl.UrlString, lease.job.Depth+1)
+		}
+	}
+
+	target := m.targets[lease.job.URL]
+	lease.stats = append(lease.stats, report.Stats...)
+	var finished *pkg.Statistor
+	if report.Done {
+		delete(m.running, report.Job)
+		for _, stat := range lease.stats {
+			target.stat.Merge(stat)
+		}
+		if report.Error != "" {
+			logs.Log.Warnf("[server] job %s (%s shard %d) failed, %s", report.Job, lease.job.URL, lease.job.Shard, report.Error)
+			if target.stat.Error == "" {
+				target.stat.Error = report.Error
+			}
+		}
+		target.remain--
+		if target.remain == 0 {
+			finished = target.stat
+		}
+	}
+	m.locker.Unlock()
+
+	for _, bl := range baselines {
+		m.outwg.Add(1)
+		m.outputCh <- bl
+	}
+	if finished != nil {
+		finished.Total = m.Total
+		finished.EndTime = time.Now().Unix()
+		m.PrintStat(finished)
+	}
+	if report.Done {
+		m.locker.Lock()
+		m.checkFinished()
+		m.locker.Unlock()
+	}
+	return &ClusterReportReply{}, nil
+}
@@ -0,0 +1,163 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/chainreactors/logs"
+	"github.com/chainreactors/spray/pkg"
+)
+
+// RunWorker spray worker, 循环从master拉取任务并执行, master结束后退出. local为worker在--之后指定的配置,
+// 只使用其中的代理与凭证, master不会下发这些配置
+func RunWorker(opts *WorkerOptions, local *Option) error {
+	if opts.Name == "" {
+		opts.Name, _ = os.Hostname()
+	}
+	client, err := newClusterClient(opts)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	logs.Log.Importantf("[worker] %s connect to %s", opts.Name, client.master)
+	var prepared json.RawMessage
+	for {
+		job, err := client.fetch()
+		if errors.Is(err, ErrClusterFinished) {
+			logs.Log.Importantf("[worker] %s", err.Error())
+			return nil
+		} else if err != nil {
+			logs.Log.Warn(err.Error())
+		}
+		if job == nil {
+			time.Sleep(time.Duration(opts.Poll) * time.Second)
+			continue
+		}
+
+		logs.Log.Importantf("[worker] job %s, %s shard %d, %d words", job.ID, job.URL, job.Shard, len(job.Words))
+		err = runClusterJob(client, job, local, !bytes.Equal(prepared, job.Option))
+		prepared = job.Option
+		report := &ClusterReport{Job: job.ID, Done: true}
+		if err != nil {
+			logs.Log.Error(err.Error())
+			report.Error = err.Error()
+		}
+		if err := client.report(report); err != nil {
+			logs.Log.Warn(err.Error())
+		}
+	}
+}
+
+// runClusterJob 以master的配置扫描单个目标的一个字典分片, 输出与递归交给master
+func runClusterJob(client *clusterClient, job *ClusterJob, local *Option, prepare bool) error {
+	var option Option
+	if err := json.Unmarshal(job.Option, &option); err != nil {
+		return err
+	}
+	applySecrets(&option, local)
+	option.URL = []string{job.URL}
+	option.URLFile, option.RawFile, option.RequestFile, option.ResumeFrom = "", "", "", ""
+	option.CIDRs, option.PortRange = nil, ""
+	option.Dictionaries, option.DefaultDict, option.Word = nil, false, ""
+	option.Prefixes, option.Suffixes, option.ForceExtension = nil, nil, false
+	option.Offset, option.Limit, option.Depth = 0, 0, 0
+	option.OutputFile, option.FuzzyFile, option.DumpFile, option.NucleiOut = "", "", "", ""
+	option.Outputs, option.Notify, option.Dump, option.AutoFile = nil, "", false, false
+	option.NoStat, option.ChunkSize = true, 0
+	option.shard = job.Words
+	if prepare {
+		// 相同的配置只需要初始化一次全局状态(指纹, 状态码等)
+		if err := option.Prepare(); err != nil {
+			return err
+		}
+	}
+
+	r, err := option.NewRunner()
+	if err != nil {
+		return err
+	}
+	sink := newClusterSink(client, job.ID)
+	r.Sinks = append(r.Sinks, sink)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(r.Deadline)*time.Second)
+	defer cancel()
+	err = r.Prepare(ctx)
+	r.Close()
+	return err
+}
+
+// ClusterSink 缓冲worker的结果与stat, 每秒上报给master, 没有结果时上报空的report作为心跳
+type ClusterSink struct {
+	client  *clusterClient
+	job     string
+	locker  sync.Mutex
+	report  *ClusterReport
+	closeCh chan struct{}
+	wg      sync.WaitGroup
+}
+
+func newClusterSink(client *clusterClient, job string) *ClusterSink {
+	s := &ClusterSink{
+		client:  client,
+		job:     job,
+		report:  &ClusterReport{Job: job},
+		closeCh: make(chan struct{}),
+	}
+	s.wg.Add(1)
+	go s.loop()
+	return s
+}
+
+func (s *ClusterSink) loop() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.flush()
+		case <-s.closeCh:
+			s.flush()
+			return
+		}
+	}
+}
+
+func (s *ClusterSink) flush() {
+	s.locker.Lock()
+	report := s.report
+	s.report = &ClusterReport{Job: s.job}
+	s.locker.Unlock()
+	if err := s.client.report(report); err != nil {
+		logs.Log.Warn(err.Error())
+		// 上报失败的结果留到下一次
+		s.locker.Lock()
+		s.report.Baselines = append(report.Baselines, s.report.Baselines...)
+		s.report.Stats = append(report.Stats, s.report.Stats...)
+		s.locker.Unlock()
+	}
+}
+
+func (s *ClusterSink) WriteBaseline(bl *pkg.Baseline) error {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	s.report.Baselines = append(s.report.Baselines, json.RawMessage(bl.ToJson()))
+	return nil
+}
+
+func (s *ClusterSink) WriteStat(stat *pkg.Statistor) error {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	s.report.Stats = append(s.report.Stats, stat)
+	return nil
+}
+
+func (s *ClusterSink) Close() error {
+	close(s.closeCh)
+	s.wg.Wait()
+	return nil
+}
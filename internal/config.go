@@ -2,7 +2,9 @@ package internal
 
 import (
 	"fmt"
+	"github.com/chainreactors/files"
 	"github.com/gookit/config/v2"
+	"github.com/jessevdk/go-flags"
 	"reflect"
 	"strconv"
 	"strings"
@@ -58,6 +60,53 @@ func LoadConfig(filename string, v interface{}) error {
 	return nil
 }
 
+// ParseWithConfig 解析命令行参数, 存在defaultConfig或通过-c指定配置文件时, 依次加载配置文件与--profile, 再以命令行中指定的参数覆盖
+// 配置文件与命令行中都没有出现的参数使用默认值, 配置文件支持yaml, toml与json, 以后缀区分
+func ParseWithConfig(parser *flags.Parser, option *Option, args []string, defaultConfig string) ([]string, error) {
+	rest, err := parser.ParseArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	var filenames []string
+	if defaultConfig != "" && files.IsExist(defaultConfig) {
+		filenames = append(filenames, defaultConfig)
+	}
+	if option.Config != "" {
+		filenames = append(filenames, option.Config)
+	}
+	profile := option.Profile
+	if len(filenames) == 0 && profile == "" {
+		return rest, nil
+	}
+
+	*option = Option{}
+	if _, err := flags.NewParser(option, flags.None).ParseArgs(nil); err != nil {
+		return nil, err
+	}
+	for _, filename := range filenames {
+		if err := LoadConfig(filename, option); err != nil {
+			return nil, fmt.Errorf("load config %s, %w", filename, err)
+		}
+	}
+	if profile != "" {
+		if err := LoadProfile(profile, option); err != nil {
+			return nil, err
+		}
+	}
+	// 默认值与配置文件已经填充, 再次解析时只有命令行中出现的参数会被设置
+	clearDefaults(parser.Groups())
+	return parser.ParseArgs(args)
+}
+
+func clearDefaults(groups []*flags.Group) {
+	for _, group := range groups {
+		for _, opt := range group.Options() {
+			opt.Default = nil
+		}
+		clearDefaults(group.Groups())
+	}
+}
+
 func convertToFieldType(fieldType reflect.StructField, defaultVal string) interface{} {
 	switch fieldType.Type.Kind() {
 	case reflect.Bool:
@@ -0,0 +1,69 @@
+package internal
+
+import (
+	"github.com/chainreactors/logs"
+	"github.com/chainreactors/spray/internal/pool"
+)
+
+// ControlHelp 扫描过程中可用的按键
+const ControlHelp = "space pause/resume, s skip current target, +/- adjust threads, q save state and quit"
+
+// controlThreadStep 每次按键调整的线程数
+const controlThreadStep = 5
+
+// HandleKey 处理扫描过程中的按键, 返回true时需要与收到退出信号一样保存状态并退出
+func (r *Runner) HandleKey(key byte) bool {
+	switch key {
+	case ' ':
+		if r.Pauser.Toggle() {
+			logs.Log.Important("[control] paused, press space to resume")
+		} else {
+			logs.Log.Important("[control] resumed")
+		}
+	case 's', 'S':
+		r.skipCurrent()
+	case '+', '=':
+		r.tuneThreads(controlThreadStep)
+	case '-', '_':
+		r.tuneThreads(-controlThreadStep)
+	case 'q', 'Q':
+		logs.Log.Important("[control] saving state and exiting...")
+		return true
+	}
+	return false
+}
+
+// skipCurrent 跳过运行时间最长的目标, 多个目标并发时需要多次按键
+func (r *Runner) skipCurrent() {
+	var current *pool.BrutePool
+	r.activePools.Range(func(_, v interface{}) bool {
+		p := v.(*pool.BrutePool)
+		if current == nil || p.Statistor.StartTime < current.Statistor.StartTime {
+			current = p
+		}
+		return true
+	})
+	if current == nil {
+		logs.Log.Warn("[control] no running target to skip")
+		return
+	}
+	logs.Log.Importantf("[control] skip %s at %d/%d", current.BaseURL, current.Statistor.End, current.Statistor.Total)
+	current.Skip()
+}
+
+// tuneThreads 调整正在运行与之后创建的pool的线程数, 最少为1
+func (r *Runner) tuneThreads(delta int) {
+	r.reloadLocker.Lock()
+	r.Threads += delta
+	if r.Threads < 1 {
+		r.Threads = 1
+	}
+	thread := r.Threads
+	r.reloadLocker.Unlock()
+
+	r.activePools.Range(func(_, v interface{}) bool {
+		v.(*pool.BrutePool).Tune(thread)
+		return true
+	})
+	logs.Log.Importantf("[control] thread: %d", thread)
+}
@@ -0,0 +1,495 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/chainreactors/logs"
+	"github.com/chainreactors/spray/internal/pool"
+	"github.com/chainreactors/spray/pkg"
+)
+
+const (
+	ScanQueued   = "queued"
+	ScanRunning  = "running"
+	ScanFinished = "finished"
+	ScanFailed   = "failed"
+	ScanCanceled = "canceled"
+)
+
+type DaemonOptions struct {
+	Listen     string `long:"listen" default:"127.0.0.1:8080" description:"String, listen address of rest api, e.g.: --listen :8080"`
+	Token      string `long:"token" description:"String, api token, request must carry X-Spray-Token header, random token will be generated if not set"`
+	MaxResults int    `long:"max-results" default:"100000" description:"Int, max results kept in memory per scan, oldest results will be dropped, e.g.: --max-results 10000"`
+}
+
+// daemonAllowedFields api提交的扫描只允许设置的字段: 目标, 预设的字典与规则, 过滤条件, 速率与线程等,
+// 其他字段可能读取本地文件, 写文件, 执行命令或是向其他地址发送数据. 值为nil时不做额外检查
+var daemonAllowedFields = map[string]func(v reflect.Value) error{
+	// 目标
+	"URL": nil, "PortRange": nil, "CIDRs": nil, "MergeSameIP": nil,
+	// 字典, 只允许预设的字典与规则
+	"Dictionaries": presetOnly(isPresetDict), "Rules": presetOnly(isPresetRule), "AppendRule": presetOnly(isPresetRule),
+	"DefaultDict": nil, "Quick": nil, "Word": nil, "RuleSyntax": nil, "FilterRule": nil, "Offset": nil, "Limit": nil,
+	"Extensions": nil, "ForceExtension": nil, "ExcludeExtensions": nil, "RemoveExtensions": nil, "Uppercase": nil,
+	"Lowercase": nil, "Prefixes": nil, "Suffixes": nil, "Replaces": nil, "Skips": nil, "Encode": nil,
+	"UniqueWords": nil, "Shuffle": nil, "SortPriority": nil,
+	// 过滤
+	"Match": nil, "Filter": nil, "MatcherMode": nil, "MatchSize": nil, "MatchWords": nil, "MatchLines": nil,
+	"MatchRegex": nil, "MatchTime": nil, "FilterSize": nil, "FilterWords": nil, "FilterLines": nil, "FilterRegex": nil,
+	"FilterTime": nil, "Fuzzy": nil, "FuzzyMatch": nil, "FuzzyFilter": nil, "Explain": nil, "BlackStatus": nil,
+	"WhiteStatus": nil, "FuzzyStatus": nil, "UniqueStatus": nil, "Unique": nil,
+	// 速率与线程
+	"RateLimit": nil, "Rate": nil, "RatePerHost": nil, "AutoThrottle": nil, "Threads": nil, "PoolSize": nil,
+	"Timeout": nil, "Deadline": nil, "MaxTimePerHost": nil, "HostConcurrency": nil,
+}
+
+func isPresetDict(name string) bool {
+	_, ok := pkg.Dicts[name]
+	return ok
+}
+
+func isPresetRule(name string) bool {
+	_, ok := pkg.Rules[name]
+	return ok
+}
+
+// presetOnly 字典与规则只允许使用预设的名称, 不允许读取本地文件
+func presetOnly(isPreset func(string) bool) func(v reflect.Value) error {
+	return func(v reflect.Value) error {
+		for _, name := range v.Interface().([]string) {
+			if !isPreset(name) {
+				return fmt.Errorf("%s is not a preset", name)
+			}
+		}
+		return nil
+	}
+}
+
+// checkSubmission 提交的内容按照与扫描相同的方式解析到空的Option中, 设置了白名单以外字段的提交会被拒绝.
+// 检查解析后的值而不是json的键名, json的字段名匹配规则(tag, 大小写折叠)与解析时完全一致
+func checkSubmission(content []byte) error {
+	submitted := &Option{}
+	if err := json.Unmarshal(content, submitted); err != nil {
+		return err
+	}
+	return checkAllowed(reflect.ValueOf(submitted).Elem())
+}
+
+func checkAllowed(v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field, value := t.Field(i), v.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			if err := checkAllowed(value); err != nil {
+				return err
+			}
+			continue
+		}
+		if value.IsZero() {
+			continue
+		}
+		check, ok := daemonAllowedFields[field.Name]
+		if !ok {
+			return fmt.Errorf("field %s is not allowed in api submission", field.Name)
+		}
+		if check != nil {
+			if err := check(value); err != nil {
+				return fmt.Errorf("field %s is not allowed in api submission, %w", field.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+type ScanInfo struct {
+	ID       string `json:"id"`
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Created  int64  `json:"created"`
+	Started  int64  `json:"started,omitempty"`
+	Finished int64  `json:"finished,omitempty"`
+}
+
+// DaemonScan 通过api提交的扫描任务
+type DaemonScan struct {
+	ScanInfo
+	option  *Option
+	runner  *Runner
+	cancel  context.CancelFunc
+	results []json.RawMessage
+	dropped int // 超过--max-results被丢弃的结果数, offset仍然从第一个结果开始计算
+	max     int
+	stats   []*pkg.Statistor
+	notify  chan struct{} // 有新结果或状态变化时关闭并重建, 唤醒所有follow的请求
+	locker  sync.Mutex
+}
+
+// DaemonProgress 运行中的pool的进度, 只读取计数字段, 避免与pool并发读写map
+type DaemonProgress struct {
+	URL      string `json:"url"`
+	End      int64  `json:"end"`
+	Total    int    `json:"total"`
+	Found    int64  `json:"found"`
+	ReqTotal int32  `json:"req_total"`
+	Failed   int32  `json:"failed"`
+}
+
+type daemonStatus struct {
+	ScanInfo
+	Tasks   int               `json:"tasks"`
+	Results int               `json:"results"`
+	Dropped int               `json:"dropped,omitempty"`
+	Stats   []*pkg.Statistor  `json:"stats,omitempty"`
+	Running []*DaemonProgress `json:"running,omitempty"`
+}
+
+func (s *DaemonScan) status() *daemonStatus {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	st := &daemonStatus{ScanInfo: s.ScanInfo, Results: s.dropped + len(s.results), Dropped: s.dropped, Stats: s.stats}
+	if s.runner != nil {
+		st.Tasks = s.runner.Count
+		s.runner.activePools.Range(func(_, v interface{}) bool {
+			p := v.(*pool.BrutePool)
+			st.Running = append(st.Running, &DaemonProgress{
+				URL:      p.Statistor.BaseUrl,
+				End:      atomic.LoadInt64(&p.Statistor.End),
+				Total:    p.Statistor.Total,
+				Found:    atomic.LoadInt64(&p.Statistor.FoundNumber),
+				ReqTotal: atomic.LoadInt32(&p.Statistor.ReqTotal),
+				Failed:   atomic.LoadInt32(&p.Statistor.FailedNumber),
+			})
+			return true
+		})
+	}
+	return st
+}
+
+// setStatus 调用时需要持有locker
+func (s *DaemonScan) setStatus(status string) {
+	s.Status = status
+	s.wake()
+}
+
+// wake 调用时需要持有locker
+func (s *DaemonScan) wake() {
+	close(s.notify)
+	s.notify = make(chan struct{})
+}
+
+func (s *DaemonScan) done() bool {
+	return s.Status == ScanFinished || s.Status == ScanFailed || s.Status == ScanCanceled
+}
+
+// daemonSink 将结果与stat保存在任务中, 供api查询与流式读取
+type daemonSink struct {
+	scan *DaemonScan
+}
+
+func (d *daemonSink) WriteBaseline(bl *pkg.Baseline) error {
+	d.scan.locker.Lock()
+	defer d.scan.locker.Unlock()
+	if d.scan.max > 0 && len(d.scan.results) >= d.scan.max {
+		// 一次丢弃1/10, 避免每个结果都移动整个切片
+		n := d.scan.max/10 + 1
+		d.scan.results = append(d.scan.results[:0], d.scan.results[n:]...)
+		d.scan.dropped += n
+	}
+	d.scan.results = append(d.scan.results, json.RawMessage(bl.ToJson()))
+	d.scan.wake()
+	return nil
+}
+
+func (d *daemonSink) WriteStat(stat *pkg.Statistor) error {
+	d.scan.locker.Lock()
+	defer d.scan.locker.Unlock()
+	d.scan.stats = append(d.scan.stats, stat)
+	return nil
+}
+
+func (d *daemonSink) Close() error {
+	return nil
+}
+
+type daemon struct {
+	opts   *DaemonOptions
+	base   []byte // 命令行与config.yaml解析得到的默认配置, 提交的json在此基础上覆盖
+	locker sync.Mutex
+	seq    int
+	scans  map[string]*DaemonScan
+	order  []string
+	queue  chan *DaemonScan
+}
+
+// RunDaemon spray daemon, 提供提交, 查询, 流式读取结果与取消扫描的rest api
+// 指纹, 状态码等配置是全局状态, 提交的扫描按顺序逐个执行
+//
+//	POST   /scans              提交扫描, body为Option的json, e.g.: {"URL": ["http://example.com"], "Dictionaries": ["1.txt"]}
+//	GET    /scans              所有扫描的状态
+//	GET    /scans/{id}         扫描状态, 已完成目标的stat与运行中目标的进度
+//	GET    /scans/{id}/results 结果的json lines, ?follow=true时持续输出直到扫描结束, ?offset=n跳过前n个结果
+//	DELETE /scans/{id}         取消扫描
+func RunDaemon(opts *DaemonOptions, base *Option) error {
+	content, err := json.Marshal(base)
+	if err != nil {
+		return err
+	}
+	if opts.Token == "" {
+		opts.Token = newToken()
+		logs.Log.Importantf("[daemon] --token not set, generated token: %s", opts.Token)
+	}
+	d := &daemon{
+		opts:  opts,
+		base:  content,
+		scans: make(map[string]*DaemonScan),
+		queue: make(chan *DaemonScan, 1024),
+	}
+	go d.loop()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /scans", d.auth(d.handleSubmit))
+	mux.HandleFunc("GET /scans", d.auth(d.handleList))
+	mux.HandleFunc("GET /scans/{id}", d.auth(d.handleStatus))
+	mux.HandleFunc("GET /scans/{id}/results", d.auth(d.handleResults))
+	mux.HandleFunc("DELETE /scans/{id}", d.auth(d.handleCancel))
+	logs.Log.Importantf("[daemon] listen on %s", opts.Listen)
+	return http.ListenAndServe(opts.Listen, mux)
+}
+
+func (d *daemon) loop() {
+	for scan := range d.queue {
+		scan.locker.Lock()
+		if scan.Status != ScanQueued {
+			scan.locker.Unlock()
+			continue
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		scan.cancel = cancel
+		scan.Started = time.Now().Unix()
+		scan.setStatus(ScanRunning)
+		scan.locker.Unlock()
+
+		logs.Log.Importantf("[daemon] scan %s start", scan.ID)
+		err := d.run(ctx, scan)
+		cancel()
+
+		scan.locker.Lock()
+		scan.Finished = time.Now().Unix()
+		if ctx.Err() != nil && scan.Status == ScanCanceled {
+			scan.wake()
+		} else if err != nil {
+			scan.Error = err.Error()
+			scan.setStatus(ScanFailed)
+		} else {
+			scan.setStatus(ScanFinished)
+		}
+		logs.Log.Importantf("[daemon] scan %s %s, %d results", scan.ID, scan.Status, scan.dropped+len(scan.results))
+		scan.locker.Unlock()
+	}
+}
+
+func (d *daemon) run(ctx context.Context, scan *DaemonScan) error {
+	if err := scan.option.Prepare(); err != nil {
+		return err
+	}
+	r, err := scan.option.NewRunner()
+	if err != nil {
+		return err
+	}
+	r.Sinks = append(r.Sinks, &daemonSink{scan: scan})
+	scan.locker.Lock()
+	scan.runner = r
+	scan.locker.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(r.Deadline)*time.Second)
+	defer cancel()
+	err = r.Prepare(ctx)
+	r.Close()
+	return err
+}
+
+func (d *daemon) auth(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if !checkToken(req, d.opts.Token) {
+			writeJson(w, http.StatusUnauthorized, map[string]string{"error": "invalid token"})
+			return
+		}
+		handler(w, req)
+	}
+}
+
+func (d *daemon) get(req *http.Request) *DaemonScan {
+	d.locker.Lock()
+	defer d.locker.Unlock()
+	return d.scans[req.PathValue("id")]
+}
+
+func (d *daemon) handleSubmit(w http.ResponseWriter, req *http.Request) {
+	option := &Option{}
+	if err := json.Unmarshal(d.base, option); err != nil {
+		writeJson(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	// 只接受application/json, 浏览器跨站提交的表单与text/plain请求不能设置该类型
+	if mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type")); mediaType != "application/json" {
+		writeJson(w, http.StatusUnsupportedMediaType, map[string]string{"error": "content-type must be application/json"})
+		return
+	}
+	content, err := io.ReadAll(req.Body)
+	if err != nil {
+		writeJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if err := checkSubmission(content); err != nil {
+		writeJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if err := json.Unmarshal(content, option); err != nil {
+		writeJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if err := option.Validate(); err != nil {
+		writeJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	// daemon没有终端, 关闭进度条
+	option.NoBar = true
+
+	d.locker.Lock()
+	d.seq++
+	scan := &DaemonScan{
+		ScanInfo: ScanInfo{
+			ID:      strconv.Itoa(d.seq),
+			Status:  ScanQueued,
+			Created: time.Now().Unix(),
+		},
+		option: option,
+		max:    d.opts.MaxResults,
+		notify: make(chan struct{}),
+	}
+	d.scans[scan.ID] = scan
+	d.order = append(d.order, scan.ID)
+	d.locker.Unlock()
+
+	select {
+	case d.queue <- scan:
+	default:
+		scan.locker.Lock()
+		scan.Error = "queue is full"
+		scan.setStatus(ScanFailed)
+		scan.locker.Unlock()
+		writeJson(w, http.StatusServiceUnavailable, map[string]string{"id": scan.ID, "error": scan.Error})
+		return
+	}
+	logs.Log.Importantf("[daemon] scan %s queued", scan.ID)
+	writeJson(w, http.StatusCreated, map[string]string{"id": scan.ID, "status": ScanQueued})
+}
+
+func (d *daemon) handleList(w http.ResponseWriter, req *http.Request) {
+	d.locker.Lock()
+	scans := make([]*DaemonScan, 0, len(d.order))
+	for _, id := range d.order {
+		scans = append(scans, d.scans[id])
+	}
+	d.locker.Unlock()
+
+	var status []*daemonStatus
+	for _, scan := range scans {
+		st := scan.status()
+		// 列表中只返回概要
+		st.Stats, st.Running = nil, nil
+		status = append(status, st)
+	}
+	writeJson(w, http.StatusOK, status)
+}
+
+func (d *daemon) handleStatus(w http.ResponseWriter, req *http.Request) {
+	scan := d.get(req)
+	if scan == nil {
+		writeJson(w, http.StatusNotFound, map[string]string{"error": "scan not found"})
+		return
+	}
+	writeJson(w, http.StatusOK, scan.status())
+}
+
+func (d *daemon) handleResults(w http.ResponseWriter, req *http.Request) {
+	scan := d.get(req)
+	if scan == nil {
+		writeJson(w, http.StatusNotFound, map[string]string{"error": "scan not found"})
+		return
+	}
+	offset, _ := strconv.Atoi(req.URL.Query().Get("offset"))
+	follow := req.URL.Query().Get("follow") == "true"
+	flusher, _ := w.(http.Flusher)
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	for {
+		scan.locker.Lock()
+		var lines []json.RawMessage
+		if offset < scan.dropped {
+			// 请求的结果已经被丢弃, 从保留的第一个结果开始
+			offset = scan.dropped
+		}
+		if i := offset - scan.dropped; i < len(scan.results) {
+			lines = append(lines, scan.results[i:]...)
+			offset = scan.dropped + len(scan.results)
+		}
+		done, notify := scan.done(), scan.notify
+		scan.locker.Unlock()
+
+		for _, line := range lines {
+			w.Write(line)
+			w.Write([]byte{'\n'})
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		if !follow || (done && len(lines) == 0) {
+			return
+		}
+		select {
+		case <-notify:
+		case <-req.Context().Done():
+			return
+		}
+	}
+}
+
+func (d *daemon) handleCancel(w http.ResponseWriter, req *http.Request) {
+	scan := d.get(req)
+	if scan == nil {
+		writeJson(w, http.StatusNotFound, map[string]string{"error": "scan not found"})
+		return
+	}
+	scan.locker.Lock()
+	defer scan.locker.Unlock()
+	if scan.done() {
+		writeJson(w, http.StatusConflict, map[string]string{"id": scan.ID, "status": scan.Status})
+		return
+	}
+	if scan.cancel != nil {
+		scan.cancel()
+	}
+	scan.setStatus(ScanCanceled)
+	logs.Log.Importantf("[daemon] scan %s canceled", scan.ID)
+	writeJson(w, http.StatusOK, map[string]string{"id": scan.ID, "status": ScanCanceled})
+}
+
+func writeJson(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,37 @@
+package internal
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestCheckSubmissionRejected(t *testing.T) {
+	for name, body := range map[string]string{
+		"json tag":    `{"URL":["http://127.0.0.1/"],"output_file":"/etc/cron.d/x"}`,
+		"kelvin sign": `{"URL":["http://127.0.0.1/"],"Lin` + "\u212A" + `edFile":"/etc/cron.d/x"}`,
+		"outputs":     `{"URL":["http://127.0.0.1/"],"Outputs":["http://attacker.example/"]}`,
+		"local dict":  `{"URL":["http://127.0.0.1/"],"Dictionaries":["/etc/passwd"]}`,
+	} {
+		if err := checkSubmission([]byte(body)); err == nil {
+			t.Errorf("%s: submission %s should be rejected", name, body)
+		}
+	}
+}
+
+func TestCheckSubmissionKelvinSign(t *testing.T) {
+	// encoding/json按大小写折叠匹配字段名, 开尔文符号(U+212A)同样会设置LinkedFile
+	opt := &Option{}
+	if err := json.Unmarshal([]byte(`{"Lin`+"\u212A"+`edFile":"x"}`), opt); err != nil {
+		t.Fatal(err)
+	}
+	if opt.LinkedFile != "x" {
+		t.Skip("encoding/json no longer folds the kelvin sign")
+	}
+}
+
+func TestCheckSubmissionAllowed(t *testing.T) {
+	body := `{"URL":["http://127.0.0.1/"],"Word":"admin","Threads":10,"RateLimit":5,"BlackStatus":"404","Match":["current.Status == 200"]}`
+	if err := checkSubmission([]byte(body)); err != nil {
+		t.Errorf("submission %s should be allowed, %s", body, err.Error())
+	}
+}
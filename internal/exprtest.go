@@ -0,0 +1,157 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/chainreactors/logs"
+	"github.com/chainreactors/parsers"
+	"github.com/chainreactors/spray/internal/ihttp"
+	"github.com/chainreactors/spray/pkg"
+	"github.com/expr-lang/expr"
+)
+
+type ExprTestOptions struct {
+	Match       []string `long:"match" description:"Strings, match expression to test, same syntax as scan, e.g.: --match 'current.Status == 200'"`
+	Filter      []string `long:"filter" description:"Strings, filter expression to test, same syntax as scan"`
+	Recursive   string   `long:"recursive" description:"String, recursive expression to test, e.g.: --recursive current.IsDir()"`
+	MatcherMode string   `long:"matcher-mode" default:"or" choice:"and" choice:"or" description:"String, how multiple --match/--filter are combined"`
+	Response    string   `long:"response" description:"String, saved raw http response (status line, headers and body), - for stdin, e.g.: --response 404.http"`
+	URL         string   `short:"u" long:"url" default:"http://example.com/" description:"String, url of the response, affect current.Path and current.IsDir()"`
+	Status      int      `long:"status" default:"200" description:"Int, status of synthetic response when --response not set"`
+	Headers     []string `long:"header" description:"Strings, header of synthetic response, e.g.: --header 'Server: nginx'"`
+	Body        string   `long:"body" description:"String, body of synthetic response"`
+}
+
+// rawResponse 读取--response指定的响应, 未指定时根据--status, --header与--body构造
+func (opts *ExprTestOptions) rawResponse() ([]byte, error) {
+	if opts.Response == "-" {
+		return io.ReadAll(os.Stdin)
+	} else if opts.Response != "" {
+		return os.ReadFile(opts.Response)
+	}
+	var raw bytes.Buffer
+	raw.WriteString(fmt.Sprintf("HTTP/1.1 %d %s\r\n", opts.Status, http.StatusText(opts.Status)))
+	for _, h := range opts.Headers {
+		raw.WriteString(h + "\r\n")
+	}
+	raw.WriteString("Content-Length: " + strconv.Itoa(len(opts.Body)) + "\r\n\r\n")
+	raw.WriteString(opts.Body)
+	return raw.Bytes(), nil
+}
+
+// normalizeHeader 兼容使用\n换行保存的响应, 只转换header部分, body保持原样, 避免改变body的长度与hash
+func normalizeHeader(raw []byte) []byte {
+	end := bytes.Index(raw, []byte("\n\n"))
+	if crlf := bytes.Index(raw, []byte("\r\n\r\n")); crlf != -1 && (end == -1 || crlf < end) {
+		return raw
+	}
+	header, body := raw, []byte(nil)
+	if end != -1 {
+		header, body = raw[:end], raw[end+2:]
+	}
+	header = bytes.TrimRight(bytes.ReplaceAll(header, []byte("\r\n"), []byte("\n")), "\r\n")
+	normalized := bytes.ReplaceAll(header, []byte("\n"), []byte("\r\n"))
+	normalized = append(normalized, "\r\n\r\n"...)
+	return append(normalized, body...)
+}
+
+// NewRawBaseline 由原始的http响应构造Baseline, 保存的响应中可能没有Content-Length或是chunked, 统一读取完整body
+func NewRawBaseline(u string, raw []byte) (*pkg.Baseline, error) {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(normalizeHeader(raw))), nil)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil && len(body) == 0 {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	resp.TransferEncoding = nil
+
+	bl := pkg.NewBaseline(u, parsed.Host, &ihttp.Response{StandardResponse: resp, ClientType: ihttp.STANDARD})
+	if bl.ContentType == "html" {
+		bl.Title = parsers.MatchTitle(bl.Body)
+	}
+	bl.Hashes = parsers.NewHashes(bl.Raw)
+	return bl, nil
+}
+
+// ExprTest 在保存的响应或构造的响应上执行--match, --filter与--recursive表达式, 输出每个表达式的结果, 无需发起扫描
+func ExprTest(opts *ExprTestOptions) error {
+	raw, err := opts.rawResponse()
+	if err != nil {
+		return err
+	}
+	bl, err := NewRawBaseline(opts.URL, raw)
+	if err != nil {
+		return err
+	}
+	match, err := pkg.CompileMatchers(opts.Match, opts.MatcherMode)
+	if err != nil {
+		return fmt.Errorf("--match %w", err)
+	}
+	filter, err := pkg.CompileMatchers(opts.Filter, opts.MatcherMode)
+	if err != nil {
+		return fmt.Errorf("--filter %w", err)
+	}
+
+	env := pkg.NewExprEnv(bl, nil, nil)
+	logs.Log.Console(bl.String() + "\n")
+	var headers []string
+	for k, v := range env["current"].(*pkg.ExprBaseline).Headers {
+		headers = append(headers, k+": "+v)
+	}
+	sort.Strings(headers)
+	logs.Log.Consolef("[header] %s\n", strings.Join(headers, ", "))
+
+	valid := true
+	if match != nil {
+		valid = evalMatchers("match", match, env)
+	}
+	if filter != nil && evalMatchers("filter", filter, env) {
+		valid = false
+	}
+	if opts.Recursive != "" {
+		program, err := expr.Compile(opts.Recursive)
+		if err != nil {
+			return fmt.Errorf("--recursive %w", err)
+		}
+		res, err := expr.Run(program, env)
+		if err != nil {
+			logs.Log.Consolef("[recursive] %s => error: %s\n", opts.Recursive, err.Error())
+		} else {
+			logs.Log.Consolef("[recursive] %s => %v\n", opts.Recursive, res)
+		}
+	}
+	logs.Log.Consolef("[result] valid: %v\n", valid)
+	return nil
+}
+
+// evalMatchers 逐个输出每个表达式的结果, 最终结果按matcher-mode计算
+func evalMatchers(typ string, m *pkg.Matchers, env map[string]interface{}) bool {
+	for _, matcher := range m.Items {
+		res, err := expr.Run(matcher.Program, env)
+		if err != nil {
+			logs.Log.Consolef("[%s] %s: %s => error: %s\n", typ, matcher.Name, matcher.Expr, err.Error())
+		} else {
+			logs.Log.Consolef("[%s] %s: %s => %v\n", typ, matcher.Name, matcher.Expr, res)
+		}
+	}
+	ok, name := m.Eval(env)
+	logs.Log.Consolef("[%s] %s => %v %s\n", typ, m.Mode, ok, name)
+	return ok
+}
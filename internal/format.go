@@ -24,8 +24,13 @@ func Format(opts Option) {
 	if err != nil {
 		return
 	}
+	lines, err := resultLines(content)
+	if err != nil {
+		logs.Log.Error(err.Error())
+		return
+	}
 	group := make(map[string][]*pkg.Baseline)
-	for _, line := range bytes.Split(bytes.TrimSpace(content), []byte("\n")) {
+	for _, line := range lines {
 		var result pkg.Baseline
 		err := json.Unmarshal(line, &result)
 		if err != nil {
@@ -86,3 +91,74 @@ func PrintPreset() {
 
 	logs.Log.Consolef("\nload %d active path\n", len(pkg.ActivePath))
 }
+
+// resultLines 将结果文件拆分为json行, messagepack格式的结果会先转换为json
+func resultLines(content []byte) ([][]byte, error) {
+	if !pkg.IsMsgpack(content) {
+		return bytes.Split(bytes.TrimSpace(content), []byte("\n")), nil
+	}
+	var lines [][]byte
+	decoder := pkg.NewMsgpackDecoder(bytes.NewReader(content))
+	for {
+		line, err := decoder.DecodeJson()
+		if err == io.EOF {
+			return lines, nil
+		} else if err != nil {
+			return nil, err
+		}
+		lines = append(lines, line)
+	}
+}
+
+// Convert 在json与messagepack结果文件之间转换, 根据输入文件内容自动判断方向, dst为空时输出到stdout
+func Convert(src, dst string) error {
+	content, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+
+	var out io.Writer = os.Stdout
+	if dst != "" {
+		f, err := os.Create(dst)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+
+	if pkg.IsMsgpack(content) {
+		lines, err := resultLines(content)
+		if err != nil {
+			return err
+		}
+		for _, line := range lines {
+			if _, err = out.Write(append(line, '\n')); err != nil {
+				return err
+			}
+		}
+		if dst != "" {
+			logs.Log.Importantf("converted %d results from msgpack to json, save to %s", len(lines), dst)
+		}
+		return nil
+	}
+
+	var count int
+	for _, line := range bytes.Split(bytes.TrimSpace(content), []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		packed, err := pkg.JsonToMsgpack(line)
+		if err != nil {
+			return err
+		}
+		if _, err = out.Write(packed); err != nil {
+			return err
+		}
+		count++
+	}
+	if dst != "" {
+		logs.Log.Importantf("converted %d results from json to msgpack, save to %s", count, dst)
+	}
+	return nil
+}
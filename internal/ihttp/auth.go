@@ -0,0 +1,235 @@
+package ihttp
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/Azure/go-ntlmssp"
+)
+
+const (
+	AuthNTLM   = "ntlm" // 同时支持NTLM与Negotiate, 由服务端的WWW-Authenticate决定
+	AuthBasic  = "basic"
+	AuthDigest = "digest"
+)
+
+// HTTPAuth 需要与服务端交互完成的http认证
+// ntlm在standard client的transport层处理401 challenge/response, basic与digest在Client.do中处理, 两种client均可使用
+type HTTPAuth struct {
+	Scheme     string
+	Username   string
+	Password   string
+	Domain     string
+	challenges sync.Map // host -> *digestChallenge, 所有pool共享同一host的nonce
+}
+
+// NewHTTPAuth 解析 user:pass 格式的凭证, 密码中可以包含冒号
+// ntlm支持 user:pass:domain, 此时最后一段视为domain; 也支持 DOMAIN\user:pass
+func NewHTTPAuth(scheme, spec string) (*HTTPAuth, error) {
+	parts := strings.Split(spec, ":")
+	if len(parts) < 2 || parts[0] == "" {
+		if scheme == AuthNTLM {
+			return nil, fmt.Errorf("invalid %s credential %q, format: user:pass[:domain]", scheme, spec)
+		}
+		return nil, fmt.Errorf("invalid %s credential %q, format: user:pass", scheme, spec)
+	}
+	auth := &HTTPAuth{Scheme: scheme, Username: parts[0], Password: strings.Join(parts[1:], ":")}
+	if scheme == AuthNTLM && len(parts) > 2 {
+		auth.Password = strings.Join(parts[1:len(parts)-1], ":")
+		auth.Domain = parts[len(parts)-1]
+	}
+	return auth, nil
+}
+
+func (a *HTTPAuth) user() string {
+	if a.Domain != "" {
+		return a.Domain + "\\" + a.Username
+	}
+	return a.Username
+}
+
+// RoundTripper 包装transport, 使每个请求都经过认证流程
+func (a *HTTPAuth) RoundTripper(rt http.RoundTripper) http.RoundTripper {
+	return &ntlmTransport{auth: a, negotiator: ntlmssp.Negotiator{RoundTripper: rt}}
+}
+
+// ntlmTransport ntlm认证绑定在连接上, 连接复用时匿名请求即可通过, 收到401时才会在同一连接上完成三次握手
+type ntlmTransport struct {
+	auth       *HTTPAuth
+	negotiator ntlmssp.Negotiator
+}
+
+func (t *ntlmTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.SetBasicAuth(t.auth.user(), t.auth.Password)
+	return t.negotiator.RoundTrip(req)
+}
+
+// Authorize 发送前注入Authorization, digest在收到第一个challenge之前不携带认证信息
+func (a *HTTPAuth) Authorize(req *Request) {
+	switch a.Scheme {
+	case AuthBasic:
+		req.SetHeader("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(a.Username+":"+a.Password)))
+	case AuthDigest:
+		u, err := url.Parse(req.URI())
+		if err != nil {
+			return
+		}
+		if c, ok := a.challenges.Load(signHost(req, u)); ok {
+			req.SetHeader("Authorization", c.(*digestChallenge).authorization(a, req, u.RequestURI()))
+		}
+	}
+}
+
+// Challenge 处理digest的401响应, 记录新的nonce, 返回true表示需要携带新的认证信息重发
+// 使用当前nonce认证依然失败时视为凭证错误, 不再重发
+func (a *HTTPAuth) Challenge(req *Request, resp *Response) bool {
+	if a.Scheme != AuthDigest || resp.StatusCode() != http.StatusUnauthorized {
+		return false
+	}
+	challenge := parseDigestChallenge(resp.GetHeader("WWW-Authenticate"))
+	if challenge == nil {
+		return false
+	}
+	u, err := url.Parse(req.URI())
+	if err != nil {
+		return false
+	}
+	host := signHost(req, u)
+	if old, ok := a.challenges.Load(host); ok && req.GetHeader("Authorization") != "" {
+		if old.(*digestChallenge).nonce == challenge.nonce && !challenge.stale {
+			return false
+		}
+	}
+	a.challenges.Store(host, challenge)
+	return true
+}
+
+type digestChallenge struct {
+	realm     string
+	nonce     string
+	opaque    string
+	algorithm string
+	qop       string
+	stale     bool
+	nc        uint32
+}
+
+// parseDigestChallenge 解析 Digest realm="x", nonce="y", qop="auth,auth-int", algorithm=MD5, opaque="z"
+func parseDigestChallenge(header string) *digestChallenge {
+	if len(header) < 7 || !strings.EqualFold(header[:7], "digest ") {
+		return nil
+	}
+	params := make(map[string]string)
+	s := strings.TrimSpace(header[7:])
+	for s != "" {
+		i := strings.IndexByte(s, '=')
+		if i < 0 {
+			break
+		}
+		key := strings.ToLower(strings.TrimSpace(s[:i]))
+		s = strings.TrimSpace(s[i+1:])
+		var value string
+		if strings.HasPrefix(s, "\"") {
+			end := 1
+			for end < len(s) && s[end] != '"' {
+				if s[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			value = strings.ReplaceAll(s[1:min(end, len(s))], "\\", "")
+			s = s[min(end+1, len(s)):]
+		} else {
+			end := strings.IndexByte(s, ',')
+			if end < 0 {
+				end = len(s)
+			}
+			value = strings.TrimSpace(s[:end])
+			s = s[end:]
+		}
+		params[key] = value
+		s = strings.TrimLeft(s, ", ")
+	}
+	if params["nonce"] == "" {
+		return nil
+	}
+	c := &digestChallenge{
+		realm:     params["realm"],
+		nonce:     params["nonce"],
+		opaque:    params["opaque"],
+		algorithm: params["algorithm"],
+		stale:     strings.EqualFold(params["stale"], "true"),
+	}
+	// 优先使用qop=auth, 只提供auth-int时对body进行摘要
+	for _, q := range strings.Split(params["qop"], ",") {
+		q = strings.TrimSpace(q)
+		if q == "auth" {
+			c.qop = q
+			break
+		} else if q == "auth-int" {
+			c.qop = q
+		}
+	}
+	return c
+}
+
+func (c *digestChallenge) hash() hash.Hash {
+	if strings.HasPrefix(strings.ToUpper(c.algorithm), "SHA-256") {
+		return sha256.New()
+	}
+	return md5.New()
+}
+
+func (c *digestChallenge) h(s string) string {
+	h := c.hash()
+	h.Write([]byte(s))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// authorization 按RFC 7616计算response, 每次调用nonce count递增
+func (c *digestChallenge) authorization(a *HTTPAuth, req *Request, uri string) string {
+	nc := fmt.Sprintf("%08x", atomic.AddUint32(&c.nc, 1))
+	b := make([]byte, 8)
+	rand.Read(b)
+	cnonce := hex.EncodeToString(b)
+
+	ha1 := c.h(a.Username + ":" + c.realm + ":" + a.Password)
+	if strings.HasSuffix(strings.ToLower(c.algorithm), "-sess") {
+		ha1 = c.h(ha1 + ":" + c.nonce + ":" + cnonce)
+	}
+	ha2 := c.h(req.Method() + ":" + uri)
+	if c.qop == "auth-int" {
+		ha2 = c.h(req.Method() + ":" + uri + ":" + c.h(string(req.Body())))
+	}
+
+	var response string
+	if c.qop != "" {
+		response = c.h(strings.Join([]string{ha1, c.nonce, nc, cnonce, c.qop, ha2}, ":"))
+	} else {
+		response = c.h(ha1 + ":" + c.nonce + ":" + ha2)
+	}
+
+	auth := fmt.Sprintf(`Digest username="%s", realm="%s", nonce="%s", uri="%s", response="%s"`,
+		a.Username, c.realm, c.nonce, uri, response)
+	if c.algorithm != "" {
+		auth += ", algorithm=" + c.algorithm
+	}
+	if c.opaque != "" {
+		auth += fmt.Sprintf(`, opaque="%s"`, c.opaque)
+	}
+	if c.qop != "" {
+		auth += fmt.Sprintf(`, qop=%s, nc=%s, cnonce="%s"`, c.qop, nc, cnonce)
+	}
+	return auth
+}
@@ -1,16 +1,19 @@
 package ihttp
 
 import (
+	"bufio"
+	"context"
 	"crypto/tls"
+	"encoding/base64"
 	"fmt"
 	"github.com/chainreactors/logs"
 	"github.com/valyala/fasthttp"
-	"github.com/valyala/fasthttp/fasthttpproxy"
 	"golang.org/x/net/proxy"
 	"net"
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -32,6 +35,7 @@ const (
 	Auto = iota
 	FAST
 	STANDARD
+	H3
 )
 
 func NewClient(config *ClientConfig) *Client {
@@ -39,14 +43,11 @@ func NewClient(config *ClientConfig) *Client {
 	if config.Type == FAST {
 		client = &Client{
 			fastClient: &fasthttp.Client{
-				TLSConfig: &tls.Config{
-					Renegotiation:      tls.RenegotiateOnceAsClient,
-					InsecureSkipVerify: true,
-				},
-				Dial:                customDialFunc(config.ProxyAddr, config.Timeout),
+				Dial:                config.dialFunc(),
 				MaxConnsPerHost:     config.Thread * 3 / 2,
 				MaxIdleConnDuration: config.Timeout,
-				//MaxConnWaitTimeout:  time.Duration(timeout) * time.Second,
+				// 运行中调高线程数后连接数可能不足, 等待空闲连接而不是直接失败
+				MaxConnWaitTimeout:            config.Timeout,
 				ReadTimeout:                   config.Timeout,
 				WriteTimeout:                  config.Timeout,
 				ReadBufferSize:                16384, // 16k
@@ -57,19 +58,28 @@ func NewClient(config *ClientConfig) *Client {
 			},
 			ClientConfig: config,
 		}
+		tlsConfig := config.TLS.Config(tls.RenegotiateOnceAsClient)
+		tlsConfig.VerifyConnection = func(state tls.ConnectionState) error {
+			client.tlsStates.Store(state.ServerName, &state)
+			return nil
+		}
+		client.fastClient.TLSConfig = tlsConfig
+		if config.Browser != "" {
+			client.fastClient.ConfigureClient = func(hc *fasthttp.HostClient) error {
+				if hc.IsTLS {
+					// 返回已完成握手的连接, fasthttp不会再次进行tls握手
+					hc.Dial = func(addr string) (net.Conn, error) {
+						ctx, cancel := context.WithTimeout(context.Background(), config.Timeout)
+						defer cancel()
+						return client.dialUTLS(ctx, addr, "http/1.1")
+					}
+				}
+				return nil
+			}
+		}
 	} else {
 		client = &Client{
 			standardClient: &http.Client{
-				Transport: &http.Transport{
-					TLSClientConfig: &tls.Config{
-						Renegotiation:      tls.RenegotiateNever,
-						InsecureSkipVerify: true,
-					},
-					TLSHandshakeTimeout: config.Timeout,
-					MaxConnsPerHost:     config.Thread * 3 / 2,
-					IdleConnTimeout:     config.Timeout,
-					ReadBufferSize:      16384, // 16k
-				},
 				Timeout: config.Timeout,
 				CheckRedirect: func(req *http.Request, via []*http.Request) error {
 					return http.ErrUseLastResponse
@@ -77,25 +87,81 @@ func NewClient(config *ClientConfig) *Client {
 			},
 			ClientConfig: config,
 		}
-		if config.ProxyAddr != "" {
-			client.standardClient.Transport.(*http.Transport).Proxy = func(_ *http.Request) (*url.URL, error) {
-				return url.Parse(config.ProxyAddr)
-			}
+		client.standardClient.Transport = client.standardTransport()
+		if config.Auth != nil && config.Auth.Scheme == AuthNTLM {
+			client.standardClient.Transport = config.Auth.RoundTripper(client.standardClient.Transport)
 		}
 	}
 	return client
 }
 
+// standardTransport 根据h3/http2/浏览器指纹与代理配置构造standard client的transport
+func (c *Client) standardTransport() http.RoundTripper {
+	config := c.ClientConfig
+	transport := &http.Transport{
+		TLSClientConfig:     config.TLS.Config(tls.RenegotiateNever),
+		TLSHandshakeTimeout: config.Timeout,
+		MaxConnsPerHost:     config.Thread * 3 / 2,
+		IdleConnTimeout:     config.Timeout,
+		ReadBufferSize:      16384, // 16k
+	}
+	if config.Type == H3 {
+		if config.ProxyAddr != "" || config.ProxyPool != nil {
+			logs.Log.Warn("h3 client not support proxy, proxy will be ignored")
+		}
+		return newHTTP3Transport(config)
+	}
+	if config.HTTP2 == HTTP2Force {
+		return newHTTP2Transport(c)
+	}
+	if config.Browser != "" {
+		// 代理由dial函数处理, transport自带的代理会绕过DialTLSContext
+		transport.DialContext = func(_ context.Context, _, addr string) (net.Conn, error) {
+			return config.dialFunc()(addr)
+		}
+		transport.DialTLSContext = func(ctx context.Context, _, addr string) (net.Conn, error) {
+			return c.dialUTLS(ctx, addr, "http/1.1")
+		}
+		return transport
+	}
+	transport.ForceAttemptHTTP2 = config.HTTP2 == HTTP2Auto
+	if config.ProxyPool != nil {
+		transport.Proxy = func(req *http.Request) (*url.URL, error) {
+			return config.ProxyPool.Next(req.URL.Hostname()), nil
+		}
+	} else if config.ProxyAddr != "" {
+		transport.Proxy = func(_ *http.Request) (*url.URL, error) {
+			return url.Parse(config.ProxyAddr)
+		}
+	}
+	return transport
+}
+
 type ClientConfig struct {
 	Type      int
 	Timeout   time.Duration
 	Thread    int
 	ProxyAddr string
+	Signer    Signer
+	TLS       *TLSOptions
+	ProxyPool *ProxyPool
+	HTTP2     string
+	Browser   string // --impersonate模拟的浏览器tls指纹
+	Auth      *HTTPAuth
+	Redirects int // 跟随重定向的最大次数, 0为不跟随
+}
+
+func (config *ClientConfig) dialFunc() fasthttp.DialFunc {
+	if config.ProxyPool != nil {
+		return proxyPoolDialFunc(config.ProxyPool, config.Timeout)
+	}
+	return customDialFunc(config.ProxyAddr, config.Timeout)
 }
 
 type Client struct {
 	fastClient     *fasthttp.Client
 	standardClient *http.Client
+	tlsStates      sync.Map // fasthttp不暴露连接的tls状态, 在握手时按server name记录
 	*ClientConfig
 }
 
@@ -118,12 +184,138 @@ func (c *Client) StandardDo(req *http.Request) (*http.Response, error) {
 }
 
 func (c *Client) Do(req *Request) (*Response, error) {
+	resp, err := c.signDo(req)
+	if err != nil || c.Redirects <= 0 {
+		return resp, err
+	}
+	return c.Follow(req, resp, c.Redirects, c.signDo)
+}
+
+// Follow 跟随重定向直到非3xx或达到次数上限, 记录每一跳的状态码与Location, 返回最后一跳的响应
+// 传入的resp会被释放, 每一跳通过do发送, 调用者可以在do中加上限速与缓存等处理
+func (c *Client) Follow(req *Request, resp *Response, max int, do func(*Request) (*Response, error)) (*Response, error) {
+	var chain []*Redirect
+	var err error
+	origin := req
+	for len(chain) < max {
+		status, location := resp.StatusCode(), resp.GetHeader("Location")
+		if status < 300 || status >= 400 || location == "" {
+			break
+		}
+		next, perr := req.Redirect(location, status)
+		if perr != nil {
+			break
+		}
+		chain = append(chain, &Redirect{URL: req.URI(), Status: status, Location: location})
+		if resp.FastResponse != nil {
+			fasthttp.ReleaseResponse(resp.FastResponse)
+		} else if resp.StandardResponse != nil {
+			resp.StandardResponse.Body.Close()
+		}
+		if req != origin && req.FastRequest != nil {
+			// 原始请求由调用者释放
+			fasthttp.ReleaseRequest(req.FastRequest)
+		}
+		req = next
+		if resp, err = do(req); err != nil {
+			break
+		}
+	}
+	if req != origin && req.FastRequest != nil {
+		fasthttp.ReleaseRequest(req.FastRequest)
+	}
+	resp.Redirects = chain
+	return resp, err
+}
+
+func (c *Client) signDo(req *Request) (*Response, error) {
+	if c.Signer == nil {
+		return c.do(req)
+	}
+	if err := c.Signer.Sign(req); err != nil {
+		return NewEmptyResponse(req.ClientType), err
+	}
+	resp, err := c.do(req)
+	if err != nil || resp.StatusCode() != 401 {
+		return resp, err
+	}
+	// 凭证失效时刷新后重发一次
+	if r, ok := c.Signer.(Refresher); ok && r.Refresh(req) {
+		rewind(req, resp)
+		if err := c.Signer.Sign(req); err != nil {
+			return NewEmptyResponse(req.ClientType), err
+		}
+		return c.do(req)
+	}
+	return resp, err
+}
+
+func (c *Client) tlsState(scheme, name string) *tls.ConnectionState {
+	if scheme != "https" {
+		return nil
+	}
+	if c.TLS != nil && c.TLS.SNI != "" {
+		name = c.TLS.SNI
+	} else if host, _, err := net.SplitHostPort(name); err == nil {
+		name = host
+	}
+	if state, ok := c.tlsStates.Load(name); ok {
+		return state.(*tls.ConnectionState)
+	}
+	return nil
+}
+
+// wrapErr mTLS握手失败时给出明确的提示
+func (c *Client) wrapErr(err error) error {
+	if !IsClientCertError(err) {
+		return err
+	}
+	if c.TLS == nil || len(c.TLS.Certs) == 0 {
+		return fmt.Errorf("server require client certificate, use --cert/--key, %w", err)
+	}
+	return fmt.Errorf("client certificate rejected by server, %w", err)
+}
+
+// rewind 释放需要重发的请求的响应, 并重置standard request的body
+func rewind(req *Request, resp *Response) {
+	if resp.FastResponse != nil {
+		fasthttp.ReleaseResponse(resp.FastResponse)
+	} else if resp.StandardResponse != nil {
+		resp.StandardResponse.Body.Close()
+		if req.StandardRequest.GetBody != nil {
+			req.StandardRequest.Body, _ = req.StandardRequest.GetBody()
+		}
+	}
+}
+
+func (c *Client) do(req *Request) (*Response, error) {
+	if c.Auth == nil {
+		return c.send(req)
+	}
+	c.Auth.Authorize(req)
+	resp, err := c.send(req)
+	if err != nil || !c.Auth.Challenge(req, resp) {
+		return resp, err
+	}
+	// 获取到新的digest nonce后携带认证信息重发一次
+	rewind(req, resp)
+	c.Auth.Authorize(req)
+	return c.send(req)
+}
+
+func (c *Client) send(req *Request) (*Response, error) {
 	if c.fastClient != nil {
 		resp, err := c.FastDo(req.FastRequest)
-		return &Response{FastResponse: resp, ClientType: FAST}, err
+		uri := req.FastRequest.URI()
+		return &Response{FastResponse: resp, ClientType: FAST, TLS: c.tlsState(string(uri.Scheme()), string(uri.Host()))}, c.wrapErr(err)
 	} else if c.standardClient != nil {
 		resp, err := c.StandardDo(req.StandardRequest)
-		return &Response{StandardResponse: resp, ClientType: STANDARD}, err
+		r := &Response{StandardResponse: resp, ClientType: STANDARD}
+		if c.Browser != "" {
+			// utls的连接不是*tls.Conn, http.Response.TLS为空
+			r.TLS = c.tlsState(req.StandardRequest.URL.Scheme, req.StandardRequest.URL.Host)
+		}
+		return r, c.wrapErr(err)
 	} else {
 		return nil, fmt.Errorf("not found client")
 	}
@@ -140,37 +332,82 @@ func customDialFunc(proxyAddr string, timeout time.Duration) fasthttp.DialFunc {
 		logs.Log.Error(err.Error())
 		return nil
 	}
-	if strings.ToLower(u.Scheme) == "socks5" {
-		return func(addr string) (net.Conn, error) {
-			var auth *proxy.Auth
-			username := u.User.Username()
-			password, ok := u.User.Password()
-			if ok {
-				auth = &proxy.Auth{
-					User:     username,
-					Password: password,
-				}
-			}
-			dialer, err := proxy.SOCKS5("tcp", u.Host, auth, proxy.Direct)
-			if err != nil {
-				return nil, err
-			}
+	return func(addr string) (net.Conn, error) {
+		return dialProxy(u, addr, timeout)
+	}
+}
 
-			// Set up a connection with a timeout
-			conn, err := dialer.Dial("tcp", addr)
-			if err != nil {
-				return nil, err
-			}
+// proxyPoolDialFunc fasthttp的连接会被复用, 因此轮换发生在建立连接时
+func proxyPoolDialFunc(pool *ProxyPool, timeout time.Duration) fasthttp.DialFunc {
+	return func(addr string) (net.Conn, error) {
+		host, _, _ := net.SplitHostPort(addr)
+		return dialProxy(pool.Next(host), addr, timeout)
+	}
+}
 
-			// Set deadlines for the connection
-			deadline := time.Now().Add(timeout)
-			if err := conn.SetDeadline(deadline); err != nil {
-				conn.Close()
-				return nil, err
+func dialProxy(u *url.URL, addr string, timeout time.Duration) (net.Conn, error) {
+	switch strings.ToLower(u.Scheme) {
+	case "socks5", "socks5h":
+		var auth *proxy.Auth
+		if password, ok := u.User.Password(); ok {
+			auth = &proxy.Auth{
+				User:     u.User.Username(),
+				Password: password,
 			}
-			return conn, nil
 		}
+		dialer, err := proxy.SOCKS5("tcp", u.Host, auth, &net.Dialer{Timeout: timeout})
+		if err != nil {
+			return nil, err
+		}
+		return dialer.Dial("tcp", addr)
+	default:
+		return dialHTTPProxy(u, addr, timeout)
+	}
+}
+
+// dialHTTPProxy 通过http/https代理的CONNECT方法建立隧道, 代理认证信息从url中读取
+func dialHTTPProxy(u *url.URL, addr string, timeout time.Duration) (net.Conn, error) {
+	host := u.Host
+	if u.Port() == "" {
+		if strings.ToLower(u.Scheme) == "https" {
+			host += ":443"
+		} else {
+			host += ":80"
+		}
+	}
+	dialer := &net.Dialer{Timeout: timeout}
+	var conn net.Conn
+	var err error
+	if strings.ToLower(u.Scheme) == "https" {
+		conn, err = tls.DialWithDialer(dialer, "tcp", host, &tls.Config{InsecureSkipVerify: true})
 	} else {
-		return fasthttpproxy.FasthttpHTTPDialerTimeout(u.Host, timeout)
+		conn, err = dialer.Dial("tcp", host)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	req := "CONNECT " + addr + " HTTP/1.1\r\nHost: " + addr + "\r\n"
+	if u.User != nil {
+		password, _ := u.User.Password()
+		req += "Proxy-Authorization: Basic " + base64.StdEncoding.EncodeToString([]byte(u.User.Username()+":"+password)) + "\r\n"
+	}
+	req += "\r\n"
+	conn.SetDeadline(time.Now().Add(timeout))
+	if _, err = conn.Write([]byte(req)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("proxy %s connect %s failed, %s", u.Host, addr, resp.Status)
 	}
+	conn.SetDeadline(time.Time{})
+	return conn, nil
 }
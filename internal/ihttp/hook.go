@@ -0,0 +1,173 @@
+package ihttp
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm"
+)
+
+func hmacHex(h func() hash.Hash, key, data string) string {
+	m := hmac.New(h, []byte(key))
+	m.Write([]byte(data))
+	return hex.EncodeToString(m.Sum(nil))
+}
+
+func hashHex(h hash.Hash, data string) string {
+	h.Write([]byte(data))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// HookFuncs --on-request/--on-response中可用的签名辅助函数, 返回值都是字符串, 方便直接拼接
+var HookFuncs = map[string]interface{}{
+	"hmac_sha256": func(key, data string) string { return hmacHex(sha256.New, key, data) },
+	"hmac_sha1":   func(key, data string) string { return hmacHex(sha1.New, key, data) },
+	"sha256":      func(data string) string { return hashHex(sha256.New(), data) },
+	"sha1":        func(data string) string { return hashHex(sha1.New(), data) },
+	"md5":         func(data string) string { return hashHex(md5.New(), data) },
+	"base64":      func(data string) string { return base64.StdEncoding.EncodeToString([]byte(data)) },
+	"timestamp":   func() string { return strconv.FormatInt(time.Now().Unix(), 10) },
+	"timestamp_ms": func() string {
+		return strconv.FormatInt(time.Now().UnixMilli(), 10)
+	},
+	"nonce": func() string { return randomHex(16) },
+	"uuid": func() string {
+		b := make([]byte, 16)
+		rand.Read(b)
+		b[6] = b[6]&0x0f | 0x40
+		b[8] = b[8]&0x3f | 0x80
+		return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+	},
+}
+
+// HookVars request与response hook共享的变量, e.g. 从登录响应中提取token后在之后的请求中使用
+type HookVars struct {
+	vars sync.Map
+}
+
+// Funcs var(name)读取变量, 不存在时返回空字符串, set_var(name, value)写入变量
+func (v *HookVars) Funcs(env map[string]interface{}) {
+	env["var"] = func(name string) string {
+		if value, ok := v.vars.Load(name); ok {
+			return value.(string)
+		}
+		return ""
+	}
+	env["set_var"] = func(name, value string) bool {
+		v.vars.Store(name, value)
+		return true
+	}
+}
+
+// CompileHooks 依次编译hook表达式
+func CompileHooks(scripts []string) ([]*vm.Program, error) {
+	var programs []*vm.Program
+	for _, script := range scripts {
+		program, err := expr.Compile(script)
+		if err != nil {
+			return nil, fmt.Errorf("compile hook %s, %w", script, err)
+		}
+		programs = append(programs, program)
+	}
+	return programs, nil
+}
+
+// HookRequest 传入--on-request的request, 修改需要通过set_header等函数
+type HookRequest struct {
+	Method string
+	URL    string
+	Path   string
+	Query  string
+	Host   string
+	Body   string
+	Header map[string]string
+}
+
+// RequestHook --on-request, 在请求发送之前依次执行表达式, 可以通过以下函数修改请求, 均返回true, 可以使用&&连接:
+//
+//	set_header(key, value), del_header(key), set_query(key, value), set_body(body)
+//
+// e.g.: let ts = timestamp(); set_header("X-Ts", ts) && set_header("X-Sign", hmac_sha256("secret", request.Path + ts))
+type RequestHook struct {
+	Programs []*vm.Program
+	Vars     *HookVars
+}
+
+func NewRequestHook(scripts []string, vars *HookVars) (*RequestHook, error) {
+	programs, err := CompileHooks(scripts)
+	if err != nil {
+		return nil, err
+	}
+	return &RequestHook{Programs: programs, Vars: vars}, nil
+}
+
+func (h *RequestHook) env(req *Request) map[string]interface{} {
+	hr := &HookRequest{Method: req.Method(), URL: req.URI(), Host: req.Host(), Body: string(req.Body()), Header: req.Headers()}
+	if u, err := url.Parse(hr.URL); err == nil {
+		hr.Path, hr.Query = u.Path, u.RawQuery
+		if hr.Host == "" {
+			hr.Host = u.Host
+		}
+	}
+	env := map[string]interface{}{
+		"request": hr,
+		"set_header": func(key, value string) bool {
+			req.SetHeader(key, value)
+			hr.Header[http.CanonicalHeaderKey(key)] = value
+			return true
+		},
+		"del_header": func(key string) bool {
+			req.DelHeader(key)
+			delete(hr.Header, http.CanonicalHeaderKey(key))
+			return true
+		},
+		"set_query": func(key, value string) bool {
+			req.SetQuery(key, value)
+			hr.URL = req.URI()
+			if u, err := url.Parse(hr.URL); err == nil {
+				hr.Query = u.RawQuery
+			}
+			return true
+		},
+		"set_body": func(body string) bool {
+			req.SetBody([]byte(body))
+			hr.Body = body
+			return true
+		},
+	}
+	for name, fn := range HookFuncs {
+		env[name] = fn
+	}
+	h.Vars.Funcs(env)
+	return env
+}
+
+// Sign 作为Signer在--sign等签名之前执行
+func (h *RequestHook) Sign(req *Request) error {
+	env := h.env(req)
+	for _, program := range h.Programs {
+		if _, err := expr.Run(program, env); err != nil {
+			return fmt.Errorf("--on-request %s, %w", program.Source().String(), err)
+		}
+	}
+	return nil
+}
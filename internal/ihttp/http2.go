@@ -0,0 +1,45 @@
+package ihttp
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+
+	"golang.org/x/net/http2"
+)
+
+const (
+	HTTP2Auto  = "auto"  // 通过ALPN协商, 服务端不支持时回落到http/1.1
+	HTTP2Force = "force" // 只使用h2, 协商失败视为请求失败
+)
+
+// newHTTP2Transport 强制h2的transport, 连接经过fasthttp同样的dial函数, 因此代理与代理池依然生效
+func newHTTP2Transport(client *Client) http.RoundTripper {
+	config := client.ClientConfig
+	dial := config.dialFunc()
+	return &http2.Transport{
+		TLSClientConfig: config.TLS.Config(tls.RenegotiateNever),
+		ReadIdleTimeout: config.Timeout,
+		DialTLSContext: func(ctx context.Context, network, addr string, cfg *tls.Config) (net.Conn, error) {
+			if config.Browser != "" {
+				return client.dialUTLS(ctx, addr, http2.NextProtoTLS)
+			}
+			conn, err := dial(addr)
+			if err != nil {
+				return nil, err
+			}
+			tlsConn := tls.Client(conn, cfg)
+			if err := tlsConn.HandshakeContext(ctx); err != nil {
+				conn.Close()
+				return nil, err
+			}
+			if proto := tlsConn.ConnectionState().NegotiatedProtocol; proto != http2.NextProtoTLS {
+				conn.Close()
+				return nil, fmt.Errorf("%s not support h2, negotiated protocol: %q", addr, proto)
+			}
+			return tlsConn, nil
+		},
+	}
+}
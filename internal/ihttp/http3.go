@@ -0,0 +1,20 @@
+package ihttp
+
+import (
+	"crypto/tls"
+	"net/http"
+
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+)
+
+// newHTTP3Transport 基于quic的http/3 transport, quic使用udp, 代理不会生效
+func newHTTP3Transport(config *ClientConfig) http.RoundTripper {
+	return &http3.RoundTripper{
+		TLSClientConfig: config.TLS.Config(tls.RenegotiateNever),
+		QUICConfig: &quic.Config{
+			HandshakeIdleTimeout: config.Timeout,
+			MaxIdleTimeout:       config.Timeout,
+		},
+	}
+}
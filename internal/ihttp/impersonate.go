@@ -0,0 +1,88 @@
+package ihttp
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strings"
+
+	utls "github.com/refraction-networking/utls"
+)
+
+var helloIDs = map[string]utls.ClientHelloID{
+	"chrome":  utls.HelloChrome_Auto,
+	"firefox": utls.HelloFirefox_Auto,
+	"safari":  utls.HelloSafari_Auto,
+	"edge":    utls.HelloEdge_Auto,
+	"ios":     utls.HelloIOS_Auto,
+}
+
+func ParseImpersonate(name string) (utls.ClientHelloID, error) {
+	if id, ok := helloIDs[strings.ToLower(name)]; ok {
+		return id, nil
+	}
+	return utls.ClientHelloID{}, fmt.Errorf("unknown impersonate %s, support chrome, firefox, safari, edge, ios", name)
+}
+
+// dialUTLS 使用utls模拟浏览器的client hello, 避免被识别go默认tls指纹的waf/cdn拦截.
+// 指纹固定后--tls-min, --tls-max与--tls-ciphers不再生效, alpn会被替换为实际使用的协议
+func (c *Client) dialUTLS(ctx context.Context, addr string, alpn ...string) (net.Conn, error) {
+	id, err := ParseImpersonate(c.Browser)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := c.dialFunc()(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	std := c.TLS.Config(tls.RenegotiateNever)
+	config := &utls.Config{
+		ServerName:            std.ServerName,
+		InsecureSkipVerify:    std.InsecureSkipVerify,
+		VerifyPeerCertificate: std.VerifyPeerCertificate,
+	}
+	if config.ServerName == "" {
+		config.ServerName, _, _ = net.SplitHostPort(addr)
+	}
+	for _, cert := range std.Certificates {
+		config.Certificates = append(config.Certificates, utls.Certificate{
+			Certificate: cert.Certificate,
+			PrivateKey:  cert.PrivateKey,
+			Leaf:        cert.Leaf,
+		})
+	}
+
+	// 每个连接都需要新的spec, 扩展是有状态的
+	spec, err := utls.UTLSIdToSpec(id)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	for _, ext := range spec.Extensions {
+		if alpnExt, ok := ext.(*utls.ALPNExtension); ok {
+			alpnExt.AlpnProtocols = alpn
+		}
+	}
+	uconn := utls.UClient(conn, config, utls.HelloCustom)
+	if err := uconn.ApplyPreset(&spec); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := uconn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	state := uconn.ConnectionState()
+	c.tlsStates.Store(config.ServerName, &tls.ConnectionState{
+		Version:            state.Version,
+		HandshakeComplete:  state.HandshakeComplete,
+		CipherSuite:        state.CipherSuite,
+		NegotiatedProtocol: state.NegotiatedProtocol,
+		ServerName:         state.ServerName,
+		PeerCertificates:   state.PeerCertificates,
+	})
+	return uconn, nil
+}
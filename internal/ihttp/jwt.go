@@ -0,0 +1,152 @@
+package ihttp
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var jwtHashes = map[string]crypto.Hash{
+	"256": crypto.SHA256,
+	"384": crypto.SHA384,
+	"512": crypto.SHA512,
+}
+
+// JWTSigner 每个请求签发一个新的jwt, claims模板支持以下变量:
+//
+//	{{now}} {{nbf}} 当前时间戳, {{exp}} 当前时间戳+ttl, {{jti}} 随机id, {{path}} 请求路径, {{word}} 路径最后一段
+type JWTSigner struct {
+	Alg    string
+	Claims string
+	Header string
+	TTL    time.Duration
+	hash   crypto.Hash
+	secret []byte
+	rsaKey *rsa.PrivateKey
+}
+
+// NewJWTSigner key为HS*的secret, 或RS*的pem私钥文件, 存在同名文件时读取文件内容
+func NewJWTSigner(alg, key, claims, header string) (*JWTSigner, error) {
+	alg = strings.ToUpper(alg)
+	if len(alg) != 5 {
+		return nil, fmt.Errorf("unsupported jwt alg %s", alg)
+	}
+	hash, ok := jwtHashes[alg[2:]]
+	if !ok {
+		return nil, fmt.Errorf("unsupported jwt alg %s", alg)
+	}
+	if header == "" {
+		header = "Authorization"
+	}
+	s := &JWTSigner{Alg: alg, Claims: claims, Header: header, TTL: 5 * time.Minute, hash: hash}
+
+	keyContent := []byte(key)
+	if content, err := os.ReadFile(key); err == nil {
+		keyContent = content
+	}
+	switch alg[:2] {
+	case "HS":
+		s.secret = keyContent
+	case "RS":
+		block, _ := pem.Decode(keyContent)
+		if block == nil {
+			return nil, fmt.Errorf("jwt %s need pem private key", alg)
+		}
+		if k, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+			s.rsaKey = k
+		} else if k, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+			rk, ok := k.(*rsa.PrivateKey)
+			if !ok {
+				return nil, fmt.Errorf("jwt %s need rsa private key", alg)
+			}
+			s.rsaKey = rk
+		} else {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported jwt alg %s", alg)
+	}
+
+	// 预先渲染一次, 提前发现模板错误
+	if _, err := s.render("/"); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *JWTSigner) render(p string) ([]byte, error) {
+	now := time.Now()
+	jti := make([]byte, 8)
+	rand.Read(jti)
+	claims := strings.NewReplacer(
+		"{{now}}", strconv.FormatInt(now.Unix(), 10),
+		"{{nbf}}", strconv.FormatInt(now.Unix(), 10),
+		"{{exp}}", strconv.FormatInt(now.Add(s.TTL).Unix(), 10),
+		"{{jti}}", hex.EncodeToString(jti),
+		"{{path}}", jsonEscape(p),
+		"{{word}}", jsonEscape(path.Base(p)),
+	).Replace(s.Claims)
+	if !json.Valid([]byte(claims)) {
+		return nil, fmt.Errorf("jwt claims is not valid json, %s", claims)
+	}
+	return []byte(claims), nil
+}
+
+func (s *JWTSigner) Mint(p string) (string, error) {
+	claims, err := s.render(p)
+	if err != nil {
+		return "", err
+	}
+	header, _ := json.Marshal(map[string]string{"alg": s.Alg, "typ": "JWT"})
+	enc := base64.RawURLEncoding
+	signing := enc.EncodeToString(header) + "." + enc.EncodeToString(claims)
+
+	var sig []byte
+	if s.rsaKey != nil {
+		h := s.hash.New()
+		h.Write([]byte(signing))
+		sig, err = rsa.SignPKCS1v15(rand.Reader, s.rsaKey, s.hash, h.Sum(nil))
+		if err != nil {
+			return "", err
+		}
+	} else {
+		h := hmac.New(s.hash.New, s.secret)
+		h.Write([]byte(signing))
+		sig = h.Sum(nil)
+	}
+	return signing + "." + enc.EncodeToString(sig), nil
+}
+
+func (s *JWTSigner) Sign(req *Request) error {
+	p := "/"
+	if u, err := url.Parse(req.URI()); err == nil && u.Path != "" {
+		p = u.Path
+	}
+	token, err := s.Mint(p)
+	if err != nil {
+		return err
+	}
+	if strings.EqualFold(s.Header, "Authorization") {
+		token = "Bearer " + token
+	}
+	req.SetHeader(s.Header, token)
+	return nil
+}
+
+func jsonEscape(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b[1 : len(b)-1])
+}
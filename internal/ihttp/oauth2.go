@@ -0,0 +1,154 @@
+package ihttp
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Refresher 签名器在收到401时刷新凭证, req为收到401的请求, 返回true表示已经有比req使用的更新的凭证, 请求可以重发
+type Refresher interface {
+	Refresh(req *Request) bool
+}
+
+// MultiSigner 按顺序依次执行多个签名器, 例如先注入oauth2 token再进行hmac签名
+type MultiSigner []Signer
+
+func (ms MultiSigner) Sign(req *Request) error {
+	for _, s := range ms {
+		if err := s.Sign(req); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ms MultiSigner) Refresh(req *Request) bool {
+	var refreshed bool
+	for _, s := range ms {
+		if r, ok := s.(Refresher); ok && r.Refresh(req) {
+			refreshed = true
+		}
+	}
+	return refreshed
+}
+
+// OAuth2Signer client credentials模式获取access token并缓存, 以Bearer header注入, 过期或收到401时自动刷新
+type OAuth2Signer struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scope        string
+	client       *http.Client
+	locker       sync.Mutex
+	token        string
+	expire       time.Time
+}
+
+// NewOAuth2Signer token请求与扫描流量使用相同的--tls-verify, tls版本, 客户端证书与代理配置, 避免client secret发往未校验的地址
+func NewOAuth2Signer(tokenURL, clientID, clientSecret, scope string, config *ClientConfig) *OAuth2Signer {
+	tlsConfig := config.TLS.Config(tls.RenegotiateNever)
+	// --sni只针对扫描目标, token地址使用自身的域名
+	tlsConfig.ServerName = ""
+	transport := &http.Transport{TLSClientConfig: tlsConfig}
+	if config.ProxyPool != nil {
+		transport.Proxy = func(req *http.Request) (*url.URL, error) {
+			return config.ProxyPool.Next(req.URL.Hostname()), nil
+		}
+	} else if config.ProxyAddr != "" {
+		transport.Proxy = func(_ *http.Request) (*url.URL, error) {
+			return url.Parse(config.ProxyAddr)
+		}
+	}
+	return &OAuth2Signer{
+		TokenURL:     tokenURL,
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		Scope:        scope,
+		client:       &http.Client{Transport: transport, Timeout: 10 * time.Second},
+	}
+}
+
+func (s *OAuth2Signer) Sign(req *Request) error {
+	token, err := s.Token()
+	if err != nil {
+		return err
+	}
+	req.SetHeader("Authorization", "Bearer "+token)
+	return nil
+}
+
+// Token 返回缓存的token, 过期前30秒重新获取
+func (s *OAuth2Signer) Token() (string, error) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	if s.token != "" && time.Now().Before(s.expire) {
+		return s.token, nil
+	}
+	return s.fetch()
+}
+
+// Refresh 只有失败的请求使用的仍然是当前的token时才重新获取, 并发请求同时收到401时只刷新一次,
+// 获取失败或得到相同的token时返回false, 避免使用无效的token重复重发
+func (s *OAuth2Signer) Refresh(req *Request) bool {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	used := strings.TrimPrefix(req.GetHeader("Authorization"), "Bearer ")
+	if s.token != "" && used != s.token {
+		// 其他请求已经刷新过token
+		return true
+	}
+	old := s.token
+	token, err := s.fetch()
+	return err == nil && token != old
+}
+
+func (s *OAuth2Signer) fetch() (string, error) {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if s.Scope != "" {
+		form.Set("scope", s.Scope)
+	}
+	req, err := http.NewRequest("POST", s.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(s.ClientID), url.QueryEscape(s.ClientSecret))
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("oauth2 token request failed, %w", err)
+	}
+	defer resp.Body.Close()
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != 200 {
+		return "", fmt.Errorf("oauth2 token request failed, status %d, %s", resp.StatusCode, content)
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.Unmarshal(content, &token); err != nil {
+		return "", err
+	}
+	if token.AccessToken == "" {
+		return "", fmt.Errorf("oauth2 token response without access_token, %s", content)
+	}
+	if token.ExpiresIn == 0 {
+		token.ExpiresIn = 3600
+	}
+	s.token = token.AccessToken
+	s.expire = time.Now().Add(time.Duration(token.ExpiresIn)*time.Second - 30*time.Second)
+	return s.token, nil
+}
@@ -0,0 +1,69 @@
+package ihttp
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"net/url"
+	"strings"
+	"sync/atomic"
+)
+
+const (
+	RoundRobin = "round-robin"
+	Random     = "random"
+	Sticky     = "sticky"
+)
+
+// ProxyPool 多个代理的轮换策略: round-robin依次轮换, random随机选择, sticky同一host固定使用同一个代理
+type ProxyPool struct {
+	Proxies  []*url.URL
+	Strategy string
+	counter  uint64
+}
+
+func NewProxyPool(proxies []string, strategy string) (*ProxyPool, error) {
+	pool := &ProxyPool{Strategy: strategy}
+	switch strategy {
+	case RoundRobin, Random, Sticky:
+	case "":
+		pool.Strategy = RoundRobin
+	default:
+		return nil, fmt.Errorf("unknown proxy strategy %s, support round-robin, random, sticky", strategy)
+	}
+	for _, p := range proxies {
+		p = strings.TrimSpace(p)
+		if p == "" || strings.HasPrefix(p, "#") {
+			continue
+		}
+		if !strings.Contains(p, "://") {
+			p = "http://" + p
+		}
+		u, err := url.Parse(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy %s, %w", p, err)
+		}
+		pool.Proxies = append(pool.Proxies, u)
+	}
+	if len(pool.Proxies) == 0 {
+		return nil, fmt.Errorf("proxy pool is empty")
+	}
+	return pool, nil
+}
+
+func (pool *ProxyPool) Next(host string) *url.URL {
+	if len(pool.Proxies) == 1 {
+		return pool.Proxies[0]
+	}
+	switch pool.Strategy {
+	case Random:
+		return pool.Proxies[rand.Intn(len(pool.Proxies))]
+	case Sticky:
+		h := fnv.New32a()
+		h.Write([]byte(host))
+		return pool.Proxies[int(h.Sum32())%len(pool.Proxies)]
+	default:
+		i := atomic.AddUint64(&pool.counter, 1)
+		return pool.Proxies[int(i%uint64(len(pool.Proxies)))]
+	}
+}
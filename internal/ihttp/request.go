@@ -1,9 +1,14 @@
 package ihttp
 
 import (
+	"bytes"
 	"context"
+	"fmt"
 	"github.com/valyala/fasthttp"
+	"io"
 	"net/http"
+	"net/url"
+	"strings"
 )
 
 func BuildRequest(ctx context.Context, clientType int, base, path, host, method string) (*Request, error) {
@@ -50,6 +55,60 @@ func (r *Request) SetHeader(key, value string) {
 	}
 }
 
+func (r *Request) DelHeader(key string) {
+	if r.StandardRequest != nil {
+		r.StandardRequest.Header.Del(key)
+	} else if r.FastRequest != nil {
+		r.FastRequest.Header.Del(key)
+	}
+}
+
+// Headers 所有header, 多个值以", "连接
+func (r *Request) Headers() map[string]string {
+	header := make(map[string]string)
+	if r.StandardRequest != nil {
+		for k, v := range r.StandardRequest.Header {
+			header[k] = strings.Join(v, ", ")
+		}
+	} else if r.FastRequest != nil {
+		r.FastRequest.Header.VisitAll(func(k, v []byte) {
+			header[string(k)] = string(v)
+		})
+	}
+	return header
+}
+
+func (r *Request) SetQuery(key, value string) {
+	if r.StandardRequest != nil {
+		query := r.StandardRequest.URL.Query()
+		query.Set(key, value)
+		r.StandardRequest.URL.RawQuery = query.Encode()
+	} else if r.FastRequest != nil {
+		r.FastRequest.URI().QueryArgs().Set(key, value)
+	}
+}
+
+func (r *Request) SetBody(body []byte) {
+	if r.StandardRequest != nil {
+		r.StandardRequest.Body = io.NopCloser(bytes.NewReader(body))
+		r.StandardRequest.ContentLength = int64(len(body))
+		r.StandardRequest.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(body)), nil
+		}
+	} else if r.FastRequest != nil {
+		r.FastRequest.SetBody(body)
+	}
+}
+
+func (r *Request) GetHeader(key string) string {
+	if r.StandardRequest != nil {
+		return r.StandardRequest.Header.Get(key)
+	} else if r.FastRequest != nil {
+		return string(r.FastRequest.Header.Peek(key))
+	}
+	return ""
+}
+
 func (r *Request) URI() string {
 	if r.FastRequest != nil {
 		return r.FastRequest.URI().String()
@@ -69,3 +128,68 @@ func (r *Request) Host() string {
 		return ""
 	}
 }
+
+// CacheKey 以method+url+headers作为响应缓存的key, 带body的请求不缓存, 返回空
+func (r *Request) CacheKey() string {
+	if r.FastRequest != nil {
+		if len(r.FastRequest.Body()) > 0 {
+			return ""
+		}
+		return string(r.FastRequest.Header.Host()) + "\n" + r.FastRequest.Header.String()
+	} else if r.StandardRequest != nil {
+		if r.StandardRequest.Body != nil && r.StandardRequest.ContentLength != 0 {
+			return ""
+		}
+		var buf bytes.Buffer
+		buf.WriteString(r.StandardRequest.Method + " " + r.StandardRequest.URL.String() + " " + r.StandardRequest.Host + "\n")
+		r.StandardRequest.Header.Write(&buf)
+		return buf.String()
+	}
+	return ""
+}
+
+// Redirect 根据Location构造下一跳请求, 307/308保留method与body, 其他状态码改为不带body的GET
+func (r *Request) Redirect(location string, status int) (*Request, error) {
+	u, err := url.Parse(r.URI())
+	if err != nil {
+		return nil, err
+	}
+	target, err := u.Parse(location)
+	if err != nil {
+		return nil, err
+	}
+	keep := status == http.StatusTemporaryRedirect || status == http.StatusPermanentRedirect
+	if r.FastRequest != nil {
+		req := fasthttp.AcquireRequest()
+		r.FastRequest.Header.CopyTo(&req.Header)
+		req.SetRequestURI(target.String())
+		if target.Host != u.Host {
+			req.SetHost(target.Host)
+		}
+		if keep {
+			req.SetBody(r.FastRequest.Body())
+		} else if method := string(req.Header.Method()); method != http.MethodGet && method != http.MethodHead {
+			req.Header.SetMethod(http.MethodGet)
+			req.Header.Del("Content-Type")
+			req.Header.SetContentLength(0)
+		}
+		return &Request{FastRequest: req, ClientType: r.ClientType}, nil
+	} else if r.StandardRequest != nil {
+		req := r.StandardRequest.Clone(r.StandardRequest.Context())
+		req.URL = target
+		if target.Host != u.Host {
+			req.Host = ""
+		}
+		if keep && r.StandardRequest.GetBody != nil {
+			req.Body, _ = r.StandardRequest.GetBody()
+		} else if !keep {
+			if req.Method != http.MethodHead {
+				req.Method = http.MethodGet
+			}
+			req.Body, req.GetBody, req.ContentLength = nil, nil, 0
+			req.Header.Del("Content-Type")
+		}
+		return &Request{StandardRequest: req, ClientType: r.ClientType}, nil
+	}
+	return nil, fmt.Errorf("empty request")
+}
@@ -1,6 +1,7 @@
 package ihttp
 
 import (
+	"crypto/tls"
 	"github.com/chainreactors/logs"
 	"github.com/chainreactors/utils/httputils"
 	"github.com/valyala/fasthttp"
@@ -13,6 +14,24 @@ type Response struct {
 	StandardResponse *http.Response
 	FastResponse     *fasthttp.Response
 	ClientType       int
+	TLS              *tls.ConnectionState // fasthttp的tls状态由client记录
+	Redirects        []*Redirect          // --follow-redirects跟随的每一跳
+}
+
+// NewEmptyResponse 请求没有发出(限速等待被取消, 签名失败)时返回的空响应,
+// fast client的调用者总是会释放FastResponse, 因此同样需要分配, 避免调用者处理nil
+func NewEmptyResponse(clientType int) *Response {
+	if clientType == FAST {
+		return &Response{FastResponse: fasthttp.AcquireResponse(), ClientType: FAST}
+	}
+	return &Response{ClientType: clientType}
+}
+
+// Redirect 重定向链中的一跳
+type Redirect struct {
+	URL      string `json:"url"`
+	Status   int    `json:"status"`
+	Location string `json:"location"`
 }
 
 func (r *Response) StatusCode() int {
@@ -99,9 +118,58 @@ func (r *Response) Header() []byte {
 	}
 }
 
+// TLSState https响应的tls连接状态, 非https或未记录时返回nil
+func (r *Response) TLSState() *tls.ConnectionState {
+	if r.TLS != nil {
+		return r.TLS
+	} else if r.StandardResponse != nil {
+		return r.StandardResponse.TLS
+	}
+	return nil
+}
+
+// Proto 实际使用的协议版本, e.g.: HTTP/1.1, HTTP/2.0
+func (r *Response) Proto() string {
+	if r.FastResponse != nil {
+		return string(r.FastResponse.Header.Protocol())
+	} else if r.StandardResponse != nil {
+		return r.StandardResponse.Proto
+	}
+	return ""
+}
+
+// Cookies 返回Set-Cookie中的name与value, 同名cookie以最后一个为准
+func (r *Response) Cookies() map[string]string {
+	cookies := make(map[string]string)
+	if r.FastResponse != nil {
+		r.FastResponse.Header.VisitAllCookie(func(key, value []byte) {
+			c := fasthttp.AcquireCookie()
+			defer fasthttp.ReleaseCookie(c)
+			if err := c.ParseBytes(value); err == nil {
+				cookies[string(c.Key())] = string(c.Value())
+			}
+		})
+	} else if r.StandardResponse != nil {
+		for _, c := range r.StandardResponse.Cookies() {
+			cookies[c.Name] = c.Value
+		}
+	}
+	return cookies
+}
+
 func (r *Response) GetHeader(key string) string {
 	if r.FastResponse != nil {
-		return string(r.FastResponse.Header.Peek(key))
+		if value := r.FastResponse.Header.Peek(key); len(value) > 0 {
+			return string(value)
+		}
+		// client关闭了header名规范化, Peek大小写敏感
+		var value string
+		r.FastResponse.Header.VisitAll(func(k, v []byte) {
+			if value == "" && strings.EqualFold(string(k), key) {
+				value = string(v)
+			}
+		})
+		return value
 	} else if r.StandardResponse != nil {
 		return r.StandardResponse.Header.Get(key)
 	} else {
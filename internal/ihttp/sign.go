@@ -0,0 +1,259 @@
+package ihttp
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Signer 在请求构造完成, 发送之前对请求进行签名
+type Signer interface {
+	Sign(req *Request) error
+}
+
+// NewSigner 解析签名配置
+//
+//	aws:<region>:<service>[:<access_key>:<secret_key>[:<session_token>]], 未指定密钥时读取AWS_ACCESS_KEY_ID等环境变量
+//	hmac:<header>:<secret>[:sha1|sha256|sha512]
+//	exec:<command>
+func NewSigner(spec string) (Signer, error) {
+	typ, args, _ := strings.Cut(spec, ":")
+	switch typ {
+	case "aws":
+		parts := strings.Split(args, ":")
+		if len(parts) < 2 {
+			return nil, fmt.Errorf("aws sign need region and service, e.g.: aws:us-east-1:execute-api")
+		}
+		s := &AWSSigner{Region: parts[0], Service: parts[1]}
+		if len(parts) >= 4 {
+			s.AccessKey, s.SecretKey = parts[2], parts[3]
+			if len(parts) >= 5 {
+				s.SessionToken = parts[4]
+			}
+		} else {
+			s.AccessKey = os.Getenv("AWS_ACCESS_KEY_ID")
+			s.SecretKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+			s.SessionToken = os.Getenv("AWS_SESSION_TOKEN")
+		}
+		if s.AccessKey == "" || s.SecretKey == "" {
+			return nil, fmt.Errorf("aws sign need access key and secret key")
+		}
+		return s, nil
+	case "hmac":
+		parts := strings.Split(args, ":")
+		if len(parts) < 2 {
+			return nil, fmt.Errorf("hmac sign need header and secret, e.g.: hmac:X-Signature:secret")
+		}
+		s := &HMACSigner{Header: parts[0], Secret: []byte(parts[1]), Hash: sha256.New}
+		if len(parts) >= 3 {
+			switch parts[2] {
+			case "sha1":
+				s.Hash = sha1.New
+			case "sha256":
+			case "sha512":
+				s.Hash = sha512.New
+			default:
+				return nil, fmt.Errorf("unknown hmac hash %s", parts[2])
+			}
+		}
+		return s, nil
+	case "exec":
+		if args == "" {
+			return nil, fmt.Errorf("exec sign need command")
+		}
+		return &ExecSigner{Command: strings.Fields(args)}, nil
+	default:
+		return nil, fmt.Errorf("unknown sign type %s", typ)
+	}
+}
+
+func (r *Request) Method() string {
+	if r.FastRequest != nil {
+		return string(r.FastRequest.Header.Method())
+	} else if r.StandardRequest != nil {
+		return r.StandardRequest.Method
+	}
+	return ""
+}
+
+func (r *Request) Body() []byte {
+	if r.FastRequest != nil {
+		return r.FastRequest.Body()
+	} else if r.StandardRequest != nil && r.StandardRequest.GetBody != nil {
+		body, err := r.StandardRequest.GetBody()
+		if err != nil {
+			return nil
+		}
+		content, _ := io.ReadAll(body)
+		return content
+	}
+	return nil
+}
+
+func signHost(req *Request, u *url.URL) string {
+	if host := req.Host(); host != "" {
+		return host
+	}
+	return u.Host
+}
+
+func sha256Hex(b []byte) string {
+	h := sha256.Sum256(b)
+	return hex.EncodeToString(h[:])
+}
+
+func hmacSum(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// AWSSigner AWS Signature Version 4
+type AWSSigner struct {
+	Region       string
+	Service      string
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+}
+
+func (s *AWSSigner) Sign(req *Request) error {
+	u, err := url.Parse(req.URI())
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(req.Body())
+
+	headers := map[string]string{
+		"host":                 signHost(req, u),
+		"x-amz-content-sha256": payloadHash,
+		"x-amz-date":           amzDate,
+	}
+	if s.SessionToken != "" {
+		headers["x-amz-security-token"] = s.SessionToken
+	}
+	var names []string
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + strings.TrimSpace(headers[k]) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	query := u.Query()
+	var keys []string
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var pairs []string
+	for _, k := range keys {
+		vs := query[k]
+		sort.Strings(vs)
+		for _, v := range vs {
+			pairs = append(pairs, awsEscape(k)+"="+awsEscape(v))
+		}
+	}
+
+	canonical := strings.Join([]string{req.Method(), path, strings.Join(pairs, "&"), canonicalHeaders.String(), signedHeaders, payloadHash}, "\n")
+	scope := strings.Join([]string{date, s.Region, s.Service, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex([]byte(canonical))}, "\n")
+
+	key := hmacSum([]byte("AWS4"+s.SecretKey), date)
+	key = hmacSum(key, s.Region)
+	key = hmacSum(key, s.Service)
+	key = hmacSum(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSum(key, stringToSign))
+
+	req.SetHeader("X-Amz-Date", amzDate)
+	req.SetHeader("X-Amz-Content-Sha256", payloadHash)
+	if s.SessionToken != "" {
+		req.SetHeader("X-Amz-Security-Token", s.SessionToken)
+	}
+	req.SetHeader("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.AccessKey, scope, signedHeaders, signature))
+	return nil
+}
+
+func awsEscape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+// HMACSigner 通用的hmac header签名, 签名内容为 method\nrequest_uri\ntimestamp\nsha256(body), 时间戳通过X-Timestamp传递
+type HMACSigner struct {
+	Header string
+	Secret []byte
+	Hash   func() hash.Hash
+}
+
+func (s *HMACSigner) Sign(req *Request) error {
+	u, err := url.Parse(req.URI())
+	if err != nil {
+		return err
+	}
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	h := hmac.New(s.Hash, s.Secret)
+	h.Write([]byte(strings.Join([]string{req.Method(), u.RequestURI(), ts, sha256Hex(req.Body())}, "\n")))
+	req.SetHeader("X-Timestamp", ts)
+	req.SetHeader(s.Header, hex.EncodeToString(h.Sum(nil)))
+	return nil
+}
+
+// ExecSigner 调用外部命令签名, stdin传入{"method","url","host","body"}的json, stdout每行输出一个需要设置的header, e.g.: Authorization: xxx
+type ExecSigner struct {
+	Command []string
+}
+
+func (s *ExecSigner) Sign(req *Request) error {
+	u, err := url.Parse(req.URI())
+	if err != nil {
+		return err
+	}
+	input, err := json.Marshal(map[string]string{
+		"method": req.Method(),
+		"url":    req.URI(),
+		"host":   signHost(req, u),
+		"body":   string(req.Body()),
+	})
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(s.Command[0], s.Command[1:]...)
+	cmd.Stdin = bytes.NewReader(input)
+	output, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("exec sign %s, %w", s.Command[0], err)
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		if k, v, ok := strings.Cut(scanner.Text(), ":"); ok {
+			req.SetHeader(strings.TrimSpace(k), strings.TrimSpace(v))
+		}
+	}
+	return nil
+}
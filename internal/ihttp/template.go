@@ -0,0 +1,164 @@
+package ihttp
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// PayloadSep 多个关键字的word拼接为一个word在worder中传递, 构造请求时再拆分
+const PayloadSep = "\x1f"
+
+// RequestTemplate 请求模板, 来自原始http请求(例如burp保存的请求)或带关键字的url, path, header与body中的关键字会被替换为当前word
+type RequestTemplate struct {
+	Method   string
+	Scheme   string
+	Host     string
+	Path     string
+	Headers  [][2]string // 保留原始顺序
+	Body     []byte
+	Keywords []string
+}
+
+// ParseRequestTemplate 解析原始请求, Content-Length会在替换后重新计算, 因此被忽略
+func ParseRequestTemplate(raw []byte, scheme string, keywords []string) (*RequestTemplate, error) {
+	raw = bytes.ReplaceAll(raw, []byte("\r\n"), []byte("\n"))
+	head, body, _ := bytes.Cut(raw, []byte("\n\n"))
+	lines := strings.Split(string(head), "\n")
+	parts := strings.Fields(lines[0])
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("invalid request line: %s", lines[0])
+	}
+	t := &RequestTemplate{
+		Method:   parts[0],
+		Scheme:   scheme,
+		Path:     parts[1],
+		Body:     bytes.TrimRight(body, "\n"),
+		Keywords: keywords,
+	}
+	// 请求行中可能是完整的url
+	if i := strings.Index(t.Path, "://"); i != -1 {
+		t.Scheme = t.Path[:i]
+		t.Host, t.Path, _ = strings.Cut(t.Path[i+3:], "/")
+		t.Path = "/" + t.Path
+	}
+	for _, line := range lines[1:] {
+		k, v, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		k, v = strings.TrimSpace(k), strings.TrimSpace(v)
+		switch strings.ToLower(k) {
+		case "host":
+			if t.Host == "" {
+				t.Host = v
+			}
+		case "content-length":
+		default:
+			t.Headers = append(t.Headers, [2]string{k, v})
+		}
+	}
+	if t.Host == "" {
+		return nil, fmt.Errorf("request without host")
+	}
+	return t, t.check()
+}
+
+// NewRequestTemplate 从带关键字的url, header与body构造模板, e.g.: http://example.com/api/FUZZ?id=FUZ2Z
+func NewRequestTemplate(method, rawURL string, headers map[string]string, body []byte, keywords []string) (*RequestTemplate, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	t := &RequestTemplate{
+		Method:   method,
+		Scheme:   u.Scheme,
+		Host:     u.Host,
+		Path:     "/",
+		Body:     body,
+		Keywords: keywords,
+	}
+	// 保留原始的path, 避免关键字被转义
+	if i := strings.Index(rawURL[len(u.Scheme)+3:], "/"); i != -1 {
+		t.Path = rawURL[len(u.Scheme)+3+i:]
+	}
+	t.SetHeaders(headers)
+	return t, t.check()
+}
+
+func (t *RequestTemplate) check() error {
+	for _, k := range t.Keywords {
+		if strings.Contains(t.Host, k) {
+			return fmt.Errorf("keyword %s in host is not supported", k)
+		}
+		if !t.HasKeyword(k) {
+			return fmt.Errorf("keyword %s not found in request", k)
+		}
+	}
+	return nil
+}
+
+func (t *RequestTemplate) HasKeyword(keyword string) bool {
+	if strings.Contains(t.Path, keyword) || bytes.Contains(t.Body, []byte(keyword)) {
+		return true
+	}
+	for _, h := range t.Headers {
+		if strings.Contains(h[0], keyword) || strings.Contains(h[1], keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetHeaders 覆盖模板中的同名header, 不存在时追加
+func (t *RequestTemplate) SetHeaders(headers map[string]string) {
+	for k, v := range headers {
+		found := false
+		for i, h := range t.Headers {
+			if strings.EqualFold(h[0], k) {
+				t.Headers[i][1] = v
+				found = true
+			}
+		}
+		if !found {
+			t.Headers = append(t.Headers, [2]string{k, v})
+		}
+	}
+}
+
+func (t *RequestTemplate) BaseURL() string {
+	return t.Scheme + "://" + t.Host
+}
+
+// Build 将payload按PayloadSep拆分后依次替换各个关键字, 数量不足时使用最后一个值, 例如单个word或随机路径
+func (t *RequestTemplate) Build(ctx context.Context, clientType int, payload string) (*Request, error) {
+	values := strings.Split(payload, PayloadSep)
+	// NewReplacer在同一位置按参数顺序匹配, 较长的关键字优先, 避免FUZ2Z被FUZ抢先替换
+	order := make([]int, len(t.Keywords))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return len(t.Keywords[order[a]]) > len(t.Keywords[order[b]])
+	})
+	var pairs []string
+	for _, i := range order {
+		pairs = append(pairs, t.Keywords[i], values[min(i, len(values)-1)])
+	}
+	replacer := strings.NewReplacer(pairs...)
+
+	req, err := BuildRequest(ctx, clientType, t.BaseURL(), replacer.Replace(t.Path), "", t.Method)
+	if err != nil {
+		return nil, err
+	}
+	for _, h := range t.Headers {
+		req.SetHeader(replacer.Replace(h[0]), replacer.Replace(h[1]))
+	}
+	if len(t.Body) > 0 {
+		req.SetBody([]byte(replacer.Replace(string(t.Body))))
+	}
+	return req, nil
+}
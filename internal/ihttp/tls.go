@@ -0,0 +1,195 @@
+package ihttp
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// TLSOptions 自定义tls版本与加密套件, 用于兼容只支持TLS1.0的老旧设备或只支持TLS1.3的服务
+type TLSOptions struct {
+	MinVersion uint16
+	MaxVersion uint16
+	Ciphers    []uint16
+	Strict     bool              // 严格校验证书链与域名
+	Pins       []string          // 证书或公钥的sha256, 用于发现中间人设备
+	Certs      []tls.Certificate // mTLS的客户端证书
+	SNI        string            // 覆盖client hello中的server name
+}
+
+// NewTLSOptions verify支持 strict, insecure(默认), pin:<sha256>[,<sha256>]
+func NewTLSOptions(min, max, ciphers, verify string) (*TLSOptions, error) {
+	opt := &TLSOptions{}
+	var err error
+	switch {
+	case verify == "" || verify == "insecure":
+	case verify == "strict":
+		opt.Strict = true
+	case strings.HasPrefix(verify, "pin:"):
+		for _, pin := range strings.Split(strings.TrimPrefix(verify, "pin:"), ",") {
+			pin = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(pin), ":", ""))
+			if len(pin) != sha256.Size*2 {
+				return nil, fmt.Errorf("invalid sha256 pin %s", pin)
+			}
+			opt.Pins = append(opt.Pins, pin)
+		}
+	default:
+		return nil, fmt.Errorf("unknown tls verify mode %s, support strict, insecure, pin:<sha256>", verify)
+	}
+	if min != "" {
+		if opt.MinVersion, err = ParseTLSVersion(min); err != nil {
+			return nil, err
+		}
+	}
+	if max != "" {
+		if opt.MaxVersion, err = ParseTLSVersion(max); err != nil {
+			return nil, err
+		}
+	}
+	if opt.MinVersion != 0 && opt.MaxVersion != 0 && opt.MinVersion > opt.MaxVersion {
+		return nil, fmt.Errorf("tls min version %s greater than max version %s", min, max)
+	}
+	if ciphers != "" {
+		if opt.Ciphers, err = ParseCiphers(ciphers); err != nil {
+			return nil, err
+		}
+	}
+	return opt, nil
+}
+
+// LoadClientCert 加载mTLS客户端证书, key为空时从cert文件中读取私钥
+func (opt *TLSOptions) LoadClientCert(certFile, keyFile string) error {
+	if keyFile == "" {
+		keyFile = certFile
+	}
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return fmt.Errorf("load client certificate %s, %w", certFile, err)
+	}
+	opt.Certs = append(opt.Certs, cert)
+	return nil
+}
+
+// ParseTLSVersion 支持 1.2, tls1.2, tls12 等写法
+func ParseTLSVersion(s string) (uint16, error) {
+	v := strings.TrimPrefix(strings.ToLower(s), "tls")
+	v = strings.TrimPrefix(v, "v")
+	if len(v) == 2 && !strings.Contains(v, ".") {
+		v = v[:1] + "." + v[1:]
+	}
+	if version, ok := tlsVersions[v]; ok {
+		return version, nil
+	}
+	return 0, fmt.Errorf("unknown tls version %s", s)
+}
+
+// ParseCiphers 逗号分割的加密套件, 支持go中的套件名与0x开头的id, 包括不安全的套件. TLS1.3的套件不可配置
+func ParseCiphers(s string) ([]uint16, error) {
+	suites := make(map[string]uint16)
+	for _, c := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		suites[c.Name] = c.ID
+	}
+	var ids []uint16
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if id, ok := suites[strings.ToUpper(name)]; ok {
+			ids = append(ids, id)
+		} else if id, err := strconv.ParseUint(name, 0, 16); err == nil {
+			ids = append(ids, uint16(id))
+		} else {
+			return nil, fmt.Errorf("unknown cipher suite %s", name)
+		}
+	}
+	return ids, nil
+}
+
+func (opt *TLSOptions) Config(renegotiation tls.RenegotiationSupport) *tls.Config {
+	config := &tls.Config{
+		Renegotiation:      renegotiation,
+		InsecureSkipVerify: true,
+	}
+	if opt == nil {
+		return config
+	}
+	config.MinVersion = opt.MinVersion
+	config.MaxVersion = opt.MaxVersion
+	config.CipherSuites = opt.Ciphers
+	config.Certificates = opt.Certs
+	config.ServerName = opt.SNI
+	if opt.Strict {
+		config.InsecureSkipVerify = false
+	} else if len(opt.Pins) > 0 {
+		config.VerifyPeerCertificate = opt.verifyPin
+	}
+	return config
+}
+
+// verifyPin 叶子证书的sha256或其公钥(SPKI)的sha256与任意pin相同即通过
+func (opt *TLSOptions) verifyPin(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+	if len(rawCerts) == 0 {
+		return errors.New("tls: no peer certificate for pinning")
+	}
+	certSum := sha256.Sum256(rawCerts[0])
+	fingerprints := []string{hex.EncodeToString(certSum[:])}
+	if cert, err := x509.ParseCertificate(rawCerts[0]); err == nil {
+		spkiSum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+		fingerprints = append(fingerprints, hex.EncodeToString(spkiSum[:]))
+	}
+	for _, pin := range opt.Pins {
+		for _, fp := range fingerprints {
+			if pin == fp {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("tls: certificate pin mismatch, got %s, maybe intercepted by middlebox", fingerprints[0])
+}
+
+// TLSVersionName e.g.: TLS 1.2
+func TLSVersionName(version uint16) string {
+	return tls.VersionName(version)
+}
+
+// IsClientCertError 服务端要求客户端证书或拒绝了提供的证书
+func IsClientCertError(err error) bool {
+	if err == nil {
+		return false
+	}
+	s := err.Error()
+	return strings.Contains(s, "certificate required") || strings.Contains(s, "bad certificate") ||
+		strings.Contains(s, "unknown certificate authority") || strings.Contains(s, "certificate unknown")
+}
+
+// IsTLSError 判断是否为tls握手阶段的错误, 用于在统计中与普通请求错误区分
+func IsTLSError(err error) bool {
+	if err == nil {
+		return false
+	}
+	var recordErr tls.RecordHeaderError
+	var alertErr tls.AlertError
+	var certErr *tls.CertificateVerificationError
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	if errors.As(err, &recordErr) || errors.As(err, &alertErr) || errors.As(err, &certErr) ||
+		errors.As(err, &unknownAuthority) || errors.As(err, &hostnameErr) {
+		return true
+	}
+	// fasthttp等库会丢失错误类型, 退化为字符串匹配
+	s := err.Error()
+	return strings.Contains(s, "tls: ") || strings.Contains(s, "handshake")
+}
@@ -0,0 +1,96 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os/exec"
+	"strings"
+
+	"github.com/chainreactors/logs"
+	"github.com/chainreactors/parsers"
+	"github.com/chainreactors/spray/pkg"
+)
+
+// NucleiResult nuclei -jsonl 输出中需要的字段
+type NucleiResult struct {
+	TemplateID string `json:"template-id"`
+	Info       struct {
+		Name     string `json:"name"`
+		Severity string `json:"severity"`
+	} `json:"info"`
+	Type      string `json:"type"`
+	Host      string `json:"host"`
+	MatchedAt string `json:"matched-at"`
+}
+
+// Baseline 将nuclei的发现转换为结果, 与其他结果一样经过输出文件, -o与--output
+func (n *NucleiResult) Baseline() *pkg.Baseline {
+	return &pkg.Baseline{
+		SprayResult: &parsers.SprayResult{
+			UrlString: n.MatchedAt,
+			Host:      n.Host,
+			IsValid:   true,
+			Title:     n.Info.Name,
+			Source:    pkg.NucleiSource,
+			Extracteds: parsers.Extracteds{
+				&parsers.Extracted{
+					Name:          n.Info.Severity,
+					ExtractResult: []string{n.TemplateID},
+				},
+			},
+		},
+	}
+}
+
+// writeNucleiTarget 以httpx兼容的格式(每行一个url)记录存活的有效结果, 用于交给nuclei等工具
+func (r *Runner) writeNucleiTarget(u string) {
+	if r.NucleiOutFile == nil {
+		return
+	}
+	r.nucleiLocker.Lock()
+	defer r.nucleiLocker.Unlock()
+	if _, ok := r.nucleiTargets[u]; ok {
+		return
+	}
+	r.nucleiTargets[u] = struct{}{}
+	r.NucleiOutFile.SafeWrite(u + "\n")
+	r.NucleiOutFile.SafeSync()
+}
+
+// RunNuclei 在每个任务结束后, 对该任务的有效结果调用nuclei, 发现送入outputCh与其他结果一同输出
+func (r *Runner) RunNuclei(urls []string) {
+	if r.NucleiTemplates == "" || len(urls) == 0 {
+		return
+	}
+	cmd := exec.Command(r.NucleiPath, "-silent", "-jsonl", "-no-color", "-t", r.NucleiTemplates)
+	cmd.Stdin = strings.NewReader(strings.Join(urls, "\n") + "\n")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		logs.Log.Error(err.Error())
+		return
+	}
+	logs.Log.Importantf("[nuclei] run %s on %d urls", r.NucleiTemplates, len(urls))
+	if err = cmd.Start(); err != nil {
+		logs.Log.Errorf("[nuclei] %s", err.Error())
+		return
+	}
+
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var result NucleiResult
+		if err := json.Unmarshal(scanner.Bytes(), &result); err != nil {
+			logs.Log.Debugf("[nuclei] %s", scanner.Text())
+			continue
+		}
+		r.outwg.Add(1)
+		r.outputCh <- result.Baseline()
+	}
+
+	if err = cmd.Wait(); err != nil {
+		logs.Log.Errorf("[nuclei] %s, %s", err.Error(), strings.TrimSpace(stderr.String()))
+	}
+}
@@ -0,0 +1,90 @@
+package pool
+
+import (
+	"github.com/chainreactors/parsers"
+	"github.com/chainreactors/spray/pkg"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	APIMaxVersion = 9
+	APISuffixes   = []string{"swagger.json", "openapi.json", "api-docs", "swagger-ui.html", "docs", "health", "status", "info", "version", "users", "user", "admin", "config"}
+	versionRegexp = regexp.MustCompile(`^v\d+(\.\d+)?$`)
+)
+
+// APIVersions 生成v1..vN以及以年份命名的版本号
+func APIVersions() []string {
+	var versions []string
+	for i := 1; i <= APIMaxVersion; i++ {
+		versions = append(versions, "v"+strconv.Itoa(i))
+	}
+	year := time.Now().Year()
+	for y := year - 2; y <= year; y++ {
+		versions = append(versions, "v"+strconv.Itoa(y))
+	}
+	return versions
+}
+
+// ExpandAPIPath 对/api与/v1风格的路径生成版本排列与常见api后缀, 不是api路径时返回nil
+func ExpandAPIPath(p string) []string {
+	segments := strings.Split(p, "/")
+	var expanded []string
+	for i, seg := range segments {
+		lower := strings.ToLower(seg)
+		if versionRegexp.MatchString(lower) {
+			// 替换版本号, 保留子路径
+			for _, v := range APIVersions() {
+				if v == lower {
+					continue
+				}
+				replaced := make([]string, len(segments))
+				copy(replaced, segments)
+				replaced[i] = v
+				expanded = append(expanded, strings.Join(replaced, "/"))
+			}
+		} else if lower == "api" && (i == len(segments)-1 || !versionRegexp.MatchString(strings.ToLower(segments[i+1]))) {
+			// api后没有版本号, 插入版本号
+			prefix := strings.Join(segments[:i+1], "/")
+			for _, v := range APIVersions() {
+				expanded = append(expanded, prefix+"/"+v+"/")
+			}
+		}
+	}
+	if expanded == nil {
+		return nil
+	}
+
+	dir := pkg.Dir(p)
+	for _, suffix := range APISuffixes {
+		expanded = append(expanded, dir+suffix)
+	}
+	return expanded
+}
+
+func (pool *BrutePool) doAPIExpand(bl *pkg.Baseline) {
+	if !pool.APIExpand || pool.Mod != PathSpray || bl.ReqDepth >= pool.MaxAppendDepth {
+		return
+	}
+	paths := ExpandAPIPath(bl.Path)
+	if len(paths) == 0 {
+		return
+	}
+
+	pool.wg.Add(1)
+	go func() {
+		defer pool.wg.Done()
+		for _, p := range paths {
+			pool.addAddition(&Unit{
+				path:   p,
+				parent: bl.Number,
+				host:   bl.Host,
+				source: parsers.AppendSource,
+				from:   bl.Source,
+				depth:  bl.ReqDepth + 1,
+			})
+		}
+	}()
+}
@@ -0,0 +1,90 @@
+package pool
+
+import (
+	"sync"
+	"time"
+
+	"github.com/chainreactors/logs"
+)
+
+var (
+	// ScaleWindow 统计错误率的窗口
+	ScaleWindow = 10 * time.Second
+	// ScaleMinSamples 窗口内请求数少于该值时不做判断, 避免少量请求的偶然错误触发调整
+	ScaleMinSamples = 20
+)
+
+// autoScaler 在错误率持续过高时将pool的线程数减半, 错误率恢复后再缓慢增加, 用于在触发BreakThreshold之前自救
+type autoScaler struct {
+	locker   sync.Mutex
+	start    time.Time
+	total    int
+	failed   int
+	max      int
+	current  int
+	errRate  float64
+	disabled bool
+}
+
+func newAutoScaler(thread int, errRate float64) *autoScaler {
+	return &autoScaler{
+		start:    time.Now(),
+		max:      thread,
+		current:  thread,
+		errRate:  errRate,
+		disabled: errRate <= 0,
+	}
+}
+
+// record 记录一次请求结果, 窗口结束时返回调整前后的线程数, 不需要调整时返回0
+func (s *autoScaler) record(failed bool) (int, int) {
+	if s.disabled {
+		return 0, 0
+	}
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	s.total++
+	if failed {
+		s.failed++
+	}
+	if time.Since(s.start) < ScaleWindow {
+		return 0, 0
+	}
+
+	var next int
+	if s.total >= ScaleMinSamples {
+		rate := float64(s.failed) / float64(s.total)
+		if rate > s.errRate && s.current > 1 {
+			next = max(1, s.current/2)
+		} else if rate < s.errRate/2 && s.current < s.max {
+			next = min(s.max, s.current+max(1, s.max/10))
+		}
+	}
+	s.start = time.Now()
+	s.total = 0
+	s.failed = 0
+	before := s.current
+	if next != 0 {
+		s.current = next
+	}
+	return before, next
+}
+
+// resize 线程数被手动修改(reload)后, 以新的线程数作为上限
+func (s *autoScaler) resize(thread int) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	s.max = thread
+	s.current = thread
+}
+
+func (pool *BrutePool) recordScale(failed bool) {
+	if before, next := pool.scaler.record(failed); next != 0 {
+		pool.reqPool.Tune(next)
+		if next < before {
+			logs.Log.Warnf("[scale] %s error rate exceeded %.0f%%, scale down threads %d -> %d", pool.BaseURL, pool.scaler.errRate*100, before, next)
+		} else {
+			logs.Log.Infof("[scale] %s error rate recovered, scale up threads %d -> %d", pool.BaseURL, before, next)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package pool
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/chainreactors/logs"
+	"github.com/chainreactors/parsers"
+	"github.com/chainreactors/spray/pkg"
+)
+
+// AutoWordSource --auto-word学习到的word复用append source, 同样不会再触发--append
+var AutoWordSource = parsers.AppendSource
+
+var (
+	AutoWordMinLength = 3
+	AutoWordMaxLength = 32
+
+	autoLinkRegexp  = regexp.MustCompile(`(?i)(?:href|src|action)\s*=\s*["']([^"'<>\s]+)["']|["'](/[^"'<>\s]+)["']`)
+	autoParamRegexp = regexp.MustCompile(`(?i)\bname\s*=\s*["']([A-Za-z0-9_\-\[\]\.]+)["']|"([A-Za-z_][A-Za-z0-9_\-]*)"\s*:`)
+	autoIdentRegexp = regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_\-]*[A-Za-z0-9]`)
+
+	// html, css与js中常见的关键字, 作为word没有意义
+	autoStopWords = map[string]struct{}{}
+)
+
+func init() {
+	for _, w := range strings.Fields(`html head body title meta link script style div span class href src alt img
+		input form button label option select table thead tbody tfoot td th tr ul ol li nav header footer section article
+		main iframe noscript svg path rel type name value content charset viewport width height text javascript css
+		function return var let const this new true false null undefined typeof else for while break continue
+		document window http https www com org net the and not you your with from that are was`) {
+		autoStopWords[w] = struct{}{}
+	}
+}
+
+// LearnWords 从响应中提取同域链接的路径片段与参数名, 表单字段与json键名, 以及正文中的标识符, 按此顺序返回并去重
+func LearnWords(bl *pkg.Baseline) []string {
+	if len(bl.Body) == 0 {
+		return nil
+	}
+	body := string(bl.Body)
+	var ws []string
+	for _, m := range autoLinkRegexp.FindAllStringSubmatch(body, -1) {
+		link := m[1] + m[2]
+		u, err := url.Parse(link)
+		if err != nil || (u.Host != "" && bl.Url != nil && u.Host != bl.Url.Host) {
+			// 其他域名的链接与当前目标无关
+			continue
+		}
+		ws = append(ws, strings.Split(u.Path, "/")...)
+		for k := range u.Query() {
+			ws = append(ws, k)
+		}
+	}
+	for _, m := range autoParamRegexp.FindAllStringSubmatch(body, -1) {
+		ws = append(ws, m[1]+m[2])
+	}
+	// 链接已经单独处理, 避免其他域名链接中的片段被当作标识符
+	body = autoLinkRegexp.ReplaceAllString(body, " ")
+	ws = append(ws, autoIdentRegexp.FindAllString(body, -1)...)
+
+	seen := make(map[string]struct{})
+	var learned []string
+	for _, w := range ws {
+		if !isLearnable(w) {
+			continue
+		}
+		if _, ok := seen[w]; !ok {
+			seen[w] = struct{}{}
+			learned = append(learned, w)
+		}
+	}
+	return learned
+}
+
+func isLearnable(w string) bool {
+	if len(w) < AutoWordMinLength || len(w) > AutoWordMaxLength || w == ".." {
+		return false
+	}
+	if _, ok := autoStopWords[strings.ToLower(w)]; ok {
+		return false
+	}
+	return strings.Trim(w, "0123456789") != ""
+}
+
+// autoWords 当前目标学习到的word, 在主字典结束后加入队列, 每个目标最多学习limit个
+// requested记录主字典请求过的unit, 与pool.urls分开, 不影响爬虫, 递归等来源的去重
+type autoWords struct {
+	limit     int
+	seen      map[string]struct{}
+	pending   []string
+	requested seenSet
+	locker    sync.Mutex
+}
+
+func newAutoWords(limit int, requested seenSet) *autoWords {
+	return &autoWords{limit: limit, seen: make(map[string]struct{}), requested: requested}
+}
+
+// full 达到limit后不再需要从响应中提取word
+func (a *autoWords) full() bool {
+	a.locker.Lock()
+	defer a.locker.Unlock()
+	return a.limit > 0 && len(a.seen) >= a.limit
+}
+
+// add 返回新加入的数量, 超过limit后不再学习
+func (a *autoWords) add(ws []string) int {
+	a.locker.Lock()
+	defer a.locker.Unlock()
+	var n int
+	for _, w := range ws {
+		if a.limit > 0 && len(a.seen) >= a.limit {
+			break
+		}
+		if _, ok := a.seen[w]; !ok {
+			a.seen[w] = struct{}{}
+			a.pending = append(a.pending, w)
+			n++
+		}
+	}
+	return n
+}
+
+func (a *autoWords) take() []string {
+	a.locker.Lock()
+	defer a.locker.Unlock()
+	ws := a.pending
+	a.pending = nil
+	return ws
+}
+
+// doAutoWord 学习2xx有效结果中的word
+func (pool *BrutePool) doAutoWord(bl *pkg.Baseline) {
+	if pool.learned == nil || bl.Status < 200 || bl.Status >= 300 || pool.learned.full() {
+		return
+	}
+	if n := pool.learned.add(LearnWords(bl)); n > 0 {
+		logs.Log.Debugf("[auto-word] learned %d words from %s", n, bl.UrlString)
+	}
+}
+
+// flushLearned 主字典结束后将学习到的word加入队列, 请求结果中学习到的新word会在下一轮继续加入, 没有新的word时返回false
+func (pool *BrutePool) flushLearned() bool {
+	if pool.learned == nil || pool.ctx.Err() != nil {
+		return false
+	}
+	ws := pool.learned.take()
+	if len(ws) == 0 {
+		return false
+	}
+
+	logs.Log.Logf(pkg.LogVerbose, "[auto-word] %s queue %d learned words", pool.BaseURL, len(ws))
+	for _, w := range ws {
+		var unit *Unit
+		if pool.Mod == ParamSpray {
+			unit = pool.paramUnit(w, AutoWordSource)
+		} else {
+			unit = &Unit{path: pool.safePath(w), source: AutoWordSource}
+		}
+		if pool.learned.requested.TestAndAdd(unit.key()) {
+			// 主字典中已经请求过
+			continue
+		}
+		pool.addAddition(unit)
+	}
+	return true
+}
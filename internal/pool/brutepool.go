@@ -4,11 +4,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"github.com/chainreactors/fingers/common"
 	"github.com/chainreactors/logs"
 	"github.com/chainreactors/parsers"
 	"github.com/chainreactors/spray/internal/ihttp"
 	"github.com/chainreactors/spray/pkg"
-	"github.com/chainreactors/utils/iutils"
 	"github.com/chainreactors/words/rule"
 	"github.com/panjf2000/ants/v2"
 	"github.com/valyala/fasthttp"
@@ -22,11 +22,7 @@ import (
 	"time"
 )
 
-var (
-	EnableAllFuzzy  = false
-	EnableAllUnique = false
-	//AllowHostModSource = []parsers.SpraySource{parsers.WordSource, parsers.CheckSource, parsers.InitIndexSource, parsers.InitRandomSource}
-)
+//var AllowHostModSource = []parsers.SpraySource{parsers.WordSource, parsers.CheckSource, parsers.InitIndexSource, parsers.InitRandomSource}
 
 func NewBrutePool(ctx context.Context, config *Config) (*BrutePool, error) {
 	var u *url.URL
@@ -34,7 +30,14 @@ func NewBrutePool(ctx context.Context, config *Config) (*BrutePool, error) {
 	if u, err = url.Parse(config.BaseURL); err != nil {
 		return nil, err
 	}
-	pctx, cancel := context.WithCancel(ctx)
+	var pctx context.Context
+	var cancel context.CancelFunc
+	if config.MaxTime > 0 {
+		// 单个任务的最大运行时间, 超时后与deadline一样中断并记录断点, 暂停期间不计时
+		pctx, cancel = config.Pauser.WithTimeout(ctx, config.MaxTime)
+	} else {
+		pctx, cancel = context.WithCancel(ctx)
+	}
 	pool := &BrutePool{
 		Baselines: NewBaselines(),
 		BasePool: &BasePool{
@@ -46,22 +49,40 @@ func NewBrutePool(ctx context.Context, config *Config) (*BrutePool, error) {
 				Type:      config.ClientType,
 				Timeout:   config.Timeout,
 				ProxyAddr: config.ProxyAddr,
+				Signer:    config.Signer,
+				TLS:       config.TLS,
+				ProxyPool: config.ProxyPool,
+				HTTP2:     config.HTTP2,
+				Browser:   config.Impersonate,
+				Auth:      config.Auth,
+				Redirects: config.FollowRedirect,
 			}),
 			additionCh: make(chan *Unit, config.Thread),
 			closeCh:    make(chan struct{}),
 			processCh:  make(chan *pkg.Baseline, config.Thread),
 			wg:         &sync.WaitGroup{},
+			hostSem:    hostSemaphore(u.Host, config.HostConcurrency),
+			cache:      getResponseCache(u.Host, config.CacheSize),
+			jar:        getCookieJar(u.Host, config.CookieJar),
+			agent:      pkg.RandomUA(),
+			replay:     newReplayClient(config),
 		},
 		base:  u.Scheme + "://" + u.Host,
 		isDir: strings.HasSuffix(u.Path, "/"),
 		url:   u,
 
-		scopeurls:   make(map[string]struct{}),
+		urls:        newSeenSet(config.BloomSize, config.BloomFP),
+		scopeurls:   newSeenSet(config.BloomSize, config.BloomFP),
 		uniques:     make(map[uint16]struct{}),
 		checkCh:     make(chan struct{}, config.Thread),
 		initwg:      sync.WaitGroup{},
-		limiter:     rate.NewLimiter(rate.Limit(config.RateLimit), 1),
+		limiter:     newLimiter(config.RateLimit),
 		failedCount: 1,
+		scaler:      newAutoScaler(config.Thread, config.ScaleErrorRate),
+	}
+	pool.throttler = newThrottler(pool.limiter, config.AutoThrottle)
+	if config.AutoWord && (config.Mod == PathSpray || config.Mod == ParamSpray) {
+		pool.learned = newAutoWords(config.AutoWordLimit, newSeenSet(config.BloomSize, config.BloomFP))
 	}
 	rand.Seed(time.Now().UnixNano())
 	// 格式化dir, 保证至少有一个"/"
@@ -73,8 +94,16 @@ func NewBrutePool(ctx context.Context, config *Config) (*BrutePool, error) {
 		pool.dir = pkg.Dir(pool.url.Path)
 	}
 
+	pool.verifyClient, pool.verifyType = newVerifyClient(config)
 	pool.reqPool, _ = ants.NewPoolWithFunc(config.Thread, pool.Invoke)
 	pool.scopePool, _ = ants.NewPoolWithFunc(config.Thread, pool.NoScopeInvoke)
+	if config.SpillLimit > 0 {
+		pool.spill = newSpillQueue(config.SpillLimit, unitCodec{}, false)
+		pool.spillExit = make(chan struct{})
+		go pool.pumpSpill()
+		pool.outSpill = newSpillQueue(config.SpillLimit, baselineCodec{}, true)
+		go pool.pumpOutput()
+	}
 
 	// 挂起一个异步的处理结果线程, 不干扰主线程的请求并发
 	go pool.Handler()
@@ -88,26 +117,54 @@ type BrutePool struct {
 	isDir bool
 	url   *url.URL
 
-	reqPool     *ants.PoolWithFunc
-	scopePool   *ants.PoolWithFunc
-	checkCh     chan struct{} // 独立的check管道， 防止与redirect/crawl冲突
-	closed      bool
-	wordOffset  int
-	failedCount int32
-	IsFailed    bool
-	urls        sync.Map
-	scopeurls   map[string]struct{}
-	uniques     map[uint16]struct{}
-	analyzeDone bool
-	limiter     *rate.Limiter
-	locker      sync.Mutex
-	scopeLocker sync.Mutex
-	initwg      sync.WaitGroup // 初始化用, 之后改成锁
+	reqPool      *ants.PoolWithFunc
+	scopePool    *ants.PoolWithFunc
+	checkCh      chan struct{} // 独立的check管道， 防止与redirect/crawl冲突
+	closed       bool
+	wordOffset   int
+	failedCount  int32
+	IsFailed     bool
+	urls         seenSet
+	scopeurls    seenSet
+	uniques      map[uint16]struct{}
+	analyzeDone  bool
+	limiter      *rate.Limiter
+	locker       sync.Mutex
+	scopeLocker  sync.Mutex
+	initwg       sync.WaitGroup // 初始化用, 之后改成锁
+	deferred     []*Unit        // 返回502/503/504的请求, 在任务结束前重新请求
+	deferLocker  sync.Mutex
+	foundLocker  sync.Mutex // ValidURLs与Frameworks, 复核通过的结果在复核的goroutine中记录
+	reloadLocker sync.RWMutex
+	scaler       *autoScaler
+	throttler    *throttler
+	ValidURLs    []string // 有效结果的url, 任务结束后交给nuclei等外部工具
+	verifyClient *ihttp.Client
+	linked       map[string]struct{} // 轻量爬虫可达的路径, --hidden-only时使用
+	calibrations []*calibration      // --auto-calibrate根据随机路径推导的过滤条件
+	verifyType   int
+	exprIndex    *pkg.ExprBaseline // index与random的expr环境, 由exprBaselines按需构造
+	exprRandom   *pkg.ExprBaseline
+	exprLocker   sync.Mutex
+	// 子目录的random baseline, 由prepareDirRandom在请求的goroutine中建立
+	dirBaselines map[string]*dirBaseline
+	dirLocker    sync.Mutex
+	// --methods中每个方法的random baseline, 由prepareMethodRandom建立, 与dirBaselines共用dirLocker
+	methodBaselines map[string]*dirBaseline
+	// -m param下所有参数使用的随机值
+	paramValue string
+	// --auto-word从响应中学习到的word
+	learned *autoWords
 }
 
 func (pool *BrutePool) Init() error {
 	pool.initwg.Add(2)
-	if pool.Index != "/" {
+	if pool.Mod == ParamSpray {
+		// 不带参数的请求作为index, 随机参数名的请求作为random baseline
+		pool.paramValue = strings.ToLower(pkg.RandPath()[:8])
+		pool.reqPool.Invoke(pool.paramUnit("", parsers.InitIndexSource))
+		pool.reqPool.Invoke(pool.paramUnit(strings.ToLower(pkg.RandPath()[:10]), parsers.InitRandomSource))
+	} else if pool.Index != "/" {
 		logs.Log.Logf(pkg.LogVerbose, "custom index url: %s", pkg.BaseURL(pool.url)+pkg.FormatURL(pkg.BaseURL(pool.url), pool.Index))
 		pool.reqPool.Invoke(&Unit{path: pool.Index, source: parsers.InitIndexSource})
 		//pool.urls[dir(pool.Index)] = struct{}{}
@@ -123,7 +180,7 @@ func (pool *BrutePool) Init() error {
 		} else {
 			pool.reqPool.Invoke(&Unit{host: pool.Random, source: parsers.InitRandomSource})
 		}
-	} else {
+	} else if pool.Mod != ParamSpray {
 		if pool.Mod == PathSpray {
 			pool.reqPool.Invoke(&Unit{path: pool.safePath(pkg.RandPath()), source: parsers.InitRandomSource})
 		} else {
@@ -147,6 +204,17 @@ func (pool *BrutePool) Init() error {
 	}
 	logs.Log.Logf(pkg.LogVerbose, "[baseline.random] "+pool.random.Format([]string{"status", "length", "spend", "title", "frame", "redirect"}))
 
+	if err := pool.precheck(); err != nil {
+		return err
+	}
+	pool.initLinked()
+	// 首页通常包含最多的链接与参数
+	pool.doAutoWord(pool.index)
+	pool.calibrate()
+	pool.vhostWildcard()
+	pool.detectWaf()
+	pool.fetchFavicon()
+
 	// 某些网站http会重定向到https, 如果发现随机目录出现这种情况, 则自定将baseurl升级为https
 	if pool.url.Scheme == "http" {
 		if pool.index.RedirectURL != "" {
@@ -180,12 +248,21 @@ func (pool *BrutePool) Run(offset, limit int) {
 		go pool.doCommonFile()
 	}
 
+	if pool.GraphQL {
+		pool.wg.Add(1)
+		go pool.doGraphQLPaths()
+	}
+
 	var done bool
 	// 挂起一个监控goroutine, 每100ms判断一次done, 如果已经done, 则关闭closeCh, 然后通过Loop中的select case closeCh去break, 实现退出
 	go func() {
 		for {
 			if done {
 				pool.wg.Wait()
+				if pool.replayDeferred() || pool.flushLearned() {
+					// 重放的请求与学习到的word完成后再次判断是否结束
+					continue
+				}
 				close(pool.closeCh)
 				return
 			}
@@ -201,7 +278,8 @@ Loop:
 				done = true
 				continue
 			}
-			pool.Statistor.End++
+			pkg.WaitMemory()
+			end := atomic.AddInt64(&pool.Statistor.End, 1)
 			if w == "" {
 				pool.Statistor.Skipped++
 				pool.Bar.Done()
@@ -212,7 +290,7 @@ Loop:
 				continue
 			}
 
-			if pool.Statistor.End > limit {
+			if end > int64(limit) {
 				done = true
 				continue
 			}
@@ -221,8 +299,19 @@ Loop:
 			if pool.Mod == HostSpray {
 				pool.reqPool.Invoke(&Unit{host: w, source: parsers.WordSource, number: pool.wordOffset})
 			} else {
-				// 原样的目录拼接, 输入了几个"/"就是几个, 适配/有语义的中间件
-				pool.reqPool.Invoke(&Unit{path: pool.safePath(w), source: parsers.WordSource, number: pool.wordOffset})
+				var unit *Unit
+				if pool.Mod == ParamSpray {
+					unit = pool.paramUnit(w, parsers.WordSource)
+					unit.number = pool.wordOffset
+				} else {
+					// 原样的目录拼接, 输入了几个"/"就是几个, 适配/有语义的中间件
+					unit = &Unit{path: pool.safePath(w), source: parsers.WordSource, number: pool.wordOffset}
+				}
+				if pool.learned != nil {
+					// 记录主字典请求过的路径, 学习到相同的word时不再重复请求
+					pool.learned.requested.TestAndAdd(unit.key())
+				}
+				pool.reqPool.Invoke(unit)
 			}
 
 		case <-pool.checkCh:
@@ -236,17 +325,20 @@ Loop:
 			if !ok || pool.closed {
 				continue
 			}
-			if _, ok := pool.urls.Load(unit.path); ok {
-				logs.Log.Debugf("[%s] duplicate path: %s, skipped", unit.source.Name(), pool.base+unit.path)
+			if pool.urls.TestAndAdd(unit.key()) {
+				logs.Log.Debugf("[%s] duplicate path: %s, skipped", pkg.SourceName(unit.source), pool.base+unit.path)
 				pool.wg.Done()
 			} else {
-				pool.urls.Store(unit.path, nil)
 				unit.number = pool.wordOffset
 				pool.reqPool.Invoke(unit)
 			}
 		case <-pool.closeCh:
 			break Loop
 		case <-pool.ctx.Done():
+			if pool.MaxTime > 0 && errors.Is(pool.ctx.Err(), context.DeadlineExceeded) {
+				logs.Log.Warnf("[pool] %s exceeded max time per host %s, abort at %d", pool.BaseURL, pool.MaxTime, pool.Statistor.End)
+				pool.Statistor.Error = pkg.ErrMaxTimeExceeded.Error()
+			}
 			break Loop
 		}
 	}
@@ -255,9 +347,9 @@ Loop:
 }
 
 func (pool *BrutePool) Invoke(v interface{}) {
-	if pool.RateLimit != 0 {
-		pool.limiter.Wait(pool.ctx)
-	}
+	pool.Pauser.Wait(pool.ctx)
+	pool.throttler.wait(pool.ctx)
+	pool.limiter.Wait(pool.ctx)
 
 	atomic.AddInt32(&pool.Statistor.ReqTotal, 1)
 	unit := v.(*Unit)
@@ -265,26 +357,42 @@ func (pool *BrutePool) Invoke(v interface{}) {
 	var req *ihttp.Request
 	var err error
 
-	req, err = ihttp.BuildRequest(pool.ctx, pool.ClientType, pool.base, unit.path, unit.host, pool.Method)
+	method := pool.Method
+	if unit.method != "" {
+		method = unit.method
+	}
+	if pool.RequestTemplate != nil {
+		req, err = pool.RequestTemplate.Build(pool.ctx, pool.ClientType, pool.relaWord(unit.path))
+	} else {
+		req, err = ihttp.BuildRequest(pool.ctx, pool.ClientType, pool.base, unit.path, unit.host, method)
+	}
 	if err != nil {
 		logs.Log.Error(err.Error())
 		return
 	}
 
-	req.SetHeaders(pool.Headers)
+	if pool.RequestTemplate == nil {
+		// 使用模板时header与body已经包含在模板中
+		req.SetHeaders(pool.Headers)
+		pool.setBody(req, unit.path)
+		pool.setParam(req, unit.param)
+	}
 	if pool.RandomUserAgent {
-		req.SetHeader("User-Agent", pkg.RandomUA())
+		req.SetHeader("User-Agent", pool.userAgent())
 	}
+	req.SetHeaders(unit.headers)
 
 	start := time.Now()
-	resp, reqerr := pool.client.Do(req)
+	resp, reqerr := pool.do(req)
 	if pool.ClientType == ihttp.FAST {
-		defer fasthttp.ReleaseResponse(resp.FastResponse)
+		// --redirect-rule跟随重定向时resp会被替换
+		defer func() { fasthttp.ReleaseResponse(resp.FastResponse) }()
 		defer fasthttp.ReleaseRequest(req.FastRequest)
 	}
 
 	// compare与各种错误处理
 	var bl *pkg.Baseline
+	pool.recordScale(reqerr != nil && !errors.Is(reqerr, fasthttp.ErrBodyTooLarge))
 	if reqerr != nil && !errors.Is(reqerr, fasthttp.ErrBodyTooLarge) {
 		atomic.AddInt32(&pool.failedCount, 1)
 		atomic.AddInt32(&pool.Statistor.FailedNumber, 1)
@@ -295,38 +403,85 @@ func (pool *BrutePool) Invoke(v interface{}) {
 				Reason:    pkg.ErrRequestFailed.Error(),
 			},
 		}
+		if ihttp.IsTLSError(reqerr) {
+			atomic.AddInt32(&pool.Statistor.TLSFailed, 1)
+			bl.Reason = pkg.ErrTLSHandshake.Error()
+		}
 		pool.FailedBaselines = append(pool.FailedBaselines, bl)
 		// 自动重放失败请求
 		pool.doRetry(bl)
 	} else { // 特定场景优化
+		pool.recordThrottle(resp)
 		if unit.source <= 3 || unit.source == parsers.CrawlSource || unit.source == parsers.CommonFileSource {
 			// 一些高优先级的source, 将跳过PreCompare
 			bl = pkg.NewBaseline(req.URI(), req.Host(), resp)
-		} else if pool.MatchExpr != nil {
+		} else if match, _ := pool.exprs(); match != nil || pool.Quick {
 			// 如果自定义了match函数, 则所有数据送入tempch中
 			bl = pkg.NewBaseline(req.URI(), req.Host(), resp)
-		} else if err = pool.PreCompare(resp); err == nil {
+		} else if err = pool.PreCompare(unit.path, resp); err == nil {
 			// 通过预对比跳过一些无用数据, 减少性能消耗
 			bl = pkg.NewBaseline(req.URI(), req.Host(), resp)
 		} else {
 			bl = pkg.NewInvalidBaseline(req.URI(), req.Host(), resp, err.Error())
+			if err == pkg.ErrRedirect {
+				pool.explain(bl, "location %s", bl.RedirectURL)
+			} else {
+				pool.explain(bl, "status %d", bl.Status)
+			}
 		}
 	}
 
 	// 手动处理重定向
-	if bl.IsValid && unit.source != parsers.CheckSource && bl.RedirectURL != "" {
-		bl.SameRedirectDomain = pool.checkHost(bl.RedirectURL)
-		pool.doRedirect(bl, unit.depth)
+	if unit.source != parsers.CheckSource && bl.RedirectURL != "" {
+		switch pool.redirectAction(bl) {
+		case RedirectFollow:
+			if resp, err = pool.client.Follow(req, resp, pool.FollowLimit, pool.do); err == nil {
+				bl = pkg.NewBaseline(req.URI(), req.Host(), resp)
+			} else {
+				logs.Log.Debugf("[redirect] follow %s failed, %s", bl.UrlString, err.Error())
+			}
+		case RedirectQueue:
+			bl.SameRedirectDomain = pool.checkHost(bl.RedirectURL)
+			pool.doRedirect(bl, unit.depth)
+		}
 	}
 
 	if !ihttp.CheckBodySize(int64(bl.BodyLength)) {
 		bl.ExceedLength = true
 	}
 	unit.Update(bl)
+	bl.Method = method
 	bl.Spended = time.Since(start).Milliseconds()
+	if unit.param != "" {
+		bl.Extracteds = append(bl.Extracteds, &parsers.Extracted{
+			Name:          "param",
+			ExtractResult: []string{unit.param},
+		})
+	}
+	if unit.source == pkg.MethodSource {
+		bl.Extracteds = append(bl.Extracteds, &parsers.Extracted{
+			Name:          "method",
+			ExtractResult: []string{method},
+		})
+	}
+	if reqerr == nil {
+		pool.doMethods(unit, resp.GetHeader("Allow"))
+	}
+	if unit.bypass != "" {
+		bl.Extracteds = append(bl.Extracteds, &parsers.Extracted{
+			Name:          "bypass",
+			ExtractResult: []string{unit.bypass},
+		})
+	}
+	if pool.shouldDefer(unit, bl) {
+		pool.addDeferred(unit)
+		return
+	}
+
 	switch unit.source {
 	case parsers.InitRandomSource:
 		defer pool.initwg.Done()
+		pool.jar.Capture(resp)
 		pool.locker.Lock()
 		pool.random = bl
 		if !bl.IsValid {
@@ -338,6 +493,7 @@ func (pool *BrutePool) Invoke(v interface{}) {
 
 	case parsers.InitIndexSource:
 		defer pool.initwg.Done()
+		pool.jar.Capture(resp)
 		pool.locker.Lock()
 		pool.index = bl
 		pool.locker.Unlock()
@@ -345,6 +501,8 @@ func (pool *BrutePool) Invoke(v interface{}) {
 			return
 		}
 		bl.Collect()
+		pool.Statistor.AddFrameworks(bl.Frameworks)
+		pool.addTechs(bl)
 		pool.doCrawl(bl)
 		pool.doAppend(bl)
 		pool.putToOutput(bl)
@@ -368,6 +526,7 @@ func (pool *BrutePool) Invoke(v interface{}) {
 
 	case parsers.WordSource:
 		// 异步进行性能消耗较大的深度对比
+		pool.prepareDirRandom(bl)
 		pool.processCh <- bl
 		if int(pool.Statistor.ReqTotal)%pool.CheckPeriod == 0 {
 			// 间歇插入check waf的探针
@@ -380,8 +539,13 @@ func (pool *BrutePool) Invoke(v interface{}) {
 		pool.Bar.Done()
 	case parsers.RedirectSource:
 		bl.FrontURL = unit.frontUrl
+		pool.prepareDirRandom(bl)
+		pool.processCh <- bl
+	case pkg.MethodSource:
+		pool.prepareMethodRandom(bl)
 		pool.processCh <- bl
 	default:
+		pool.prepareDirRandom(bl)
 		pool.processCh <- bl
 	}
 }
@@ -395,8 +559,8 @@ func (pool *BrutePool) NoScopeInvoke(v interface{}) {
 		return
 	}
 	req.SetHeaders(pool.Headers)
-	req.SetHeader("User-Agent", pkg.RandomUA())
-	resp, reqerr := pool.client.Do(req)
+	req.SetHeader("User-Agent", pool.userAgent())
+	resp, reqerr := pool.do(req)
 	if pool.ClientType == ihttp.FAST {
 		defer fasthttp.ReleaseResponse(resp.FastResponse)
 		defer fasthttp.ReleaseRequest(req.FastRequest)
@@ -434,13 +598,31 @@ func (pool *BrutePool) Handler() {
 			pool.Statistor.Sources[bl.Source] = 1
 		}
 
-		var params map[string]interface{}
-		if pool.MatchExpr != nil || pool.FilterExpr != nil || pool.RecuExpr != nil {
-			params = map[string]interface{}{
-				"index":   pool.index,
-				"random":  pool.random,
-				"current": bl,
+		if bl.Source == pkg.BypassSource {
+			// bypass的结果不参与常规的对比, 只判断是否绕过成功
+			if pool.checkBypass(bl) {
+				atomic.AddInt64(&pool.Statistor.FoundNumber, 1)
+			} else {
+				bl.IsValid = false
+				if bl.Reason == "" {
+					bl.Reason = pkg.ErrBypassFailed.Error()
+				}
+			}
+			if !pool.closed {
+				pool.putToOutput(bl)
 			}
+			pool.wg.Done()
+			continue
+		}
+
+		// --on-response在对比之前执行, 可以提取数据或丢弃结果
+		exprIndex, exprRandom := pool.exprBaselines()
+		dropped := pool.ResponseHook != nil && pool.ResponseHook.Run(bl, exprIndex, exprRandom)
+
+		var params map[string]interface{}
+		matchExpr, filterExpr := pool.exprs()
+		if matchExpr != nil || filterExpr != nil || pool.RecuExpr != nil {
+			params = pkg.NewExprEnvWith(bl, exprIndex, exprRandom)
 			//for _, ok := range FuzzyStatus {
 			//	if bl, ok := pool.baselines[ok]; ok {
 			//		params["bl"+strconv.Itoa(ok)] = bl
@@ -451,31 +633,68 @@ func (pool *BrutePool) Handler() {
 		}
 
 		var ok bool
-		if pool.MatchExpr != nil {
-			if pkg.CompareWithExpr(pool.MatchExpr, params) {
+		if dropped {
+			pool.Statistor.FilteredNumber++
+			bl.Reason = pkg.ErrHookDropped.Error()
+			pool.explain(bl, "drop() called by --on-response")
+		} else if matchExpr != nil {
+			ok, _ = matchExpr.Eval(params)
+		} else if pool.ResponseFilter.HasMatch() {
+			ok = true
+		} else if c := pool.calibrated(bl); c != nil {
+			pool.Statistor.FilteredNumber++
+			bl.Reason = pkg.ErrCalibrated.Error()
+			pool.explain(bl, "%s", c.String())
+		} else if pool.Mod == ParamSpray {
+			ok = pool.paramCompare(bl)
+		} else if pool.listed(bl) {
+			// 不同目录的列表页面结构相同, simhash相近, 不参与模糊对比
+			ok = true
+		} else if check := pool.quickCheck(bl); check != nil {
+			// quick模式下每个路径使用独立的匹配逻辑
+			if check.Match(bl) {
 				ok = true
+			} else {
+				bl.Reason = pkg.ErrQuickNotMatch.Error()
 			}
 		} else {
 			ok = pool.BaseCompare(bl)
 		}
 
+		// --match-size/words/lines/regex/time与--match同时存在时需要同时满足
+		if ok && pool.ResponseFilter.HasMatch() && !pool.ResponseFilter.Match(bl) {
+			ok = false
+			bl.Reason = pkg.ErrResponseNotMatch.Error()
+			pool.explain(bl, "size %d, words %d, lines %d, spend %dms", bl.BodyLength, bl.Words, bl.Lines, bl.Spended)
+		}
+
 		if ok {
-			// unique判断
-			if EnableAllUnique || iutils.IntsContains(pkg.UniqueStatus, bl.Status) {
+			// unique判断, param模式下每个参数名都是独立的结果
+			if pool.Mod != ParamSpray && pkg.UniqueStatus.Contains(bl.Status) {
 				if _, ok := pool.uniques[bl.Unique]; ok {
 					bl.IsValid = false
 					bl.IsFuzzy = true
 					bl.Reason = pkg.ErrFuzzyNotUnique.Error()
+					pool.explain(bl, "unique hash %d already seen", bl.Unique)
 				} else {
 					pool.uniques[bl.Unique] = struct{}{}
 				}
 			}
 
 			// 对通过所有对比的有效数据进行再次filter
-			if bl.IsValid && pool.FilterExpr != nil && pkg.CompareWithExpr(pool.FilterExpr, params) {
+			if bl.IsValid && filterExpr != nil {
+				if hit, name := filterExpr.Eval(params); hit {
+					pool.Statistor.FilteredNumber++
+					bl.Reason = pkg.ErrCustomFilter.Error()
+					bl.IsValid = false
+					pool.explain(bl, "--filter %s matched", name)
+				}
+			}
+			if bl.IsValid && pool.ResponseFilter.Filter(bl) {
 				pool.Statistor.FilteredNumber++
 				bl.Reason = pkg.ErrCustomFilter.Error()
 				bl.IsValid = false
+				pool.explain(bl, "size %d, words %d, lines %d, spend %dms hit --filter-*", bl.BodyLength, bl.Words, bl.Lines, bl.Spended)
 			}
 		} else {
 			bl.IsValid = false
@@ -486,17 +705,20 @@ func (pool *BrutePool) Handler() {
 			pool.doAppend(bl)
 		}
 
-		// 如果要进行递归判断, 要满足 bl有效, mod为path-spray, 当前深度小于最大递归深度
-		if bl.IsValid {
-			pool.Statistor.FoundNumber++
-			if bl.RecuDepth < pool.MaxRecursionDepth {
-				if pkg.CompareWithExpr(pool.RecuExpr, params) {
-					bl.Recu = true
-				}
-			}
+		if bl.IsValid && pool.isLinked(bl) {
+			// 通过爬虫可达的路径不输出也不计数, 但仍然参与递归
+			bl.IsValid = false
+			bl.Reason = pkg.ErrLinkedPath.Error()
+			bl.Recu = pool.recursive(bl, params)
 		}
 
-		if !pool.closed {
+		if bl.IsValid && pool.verifyClient != nil {
+			// 复核通过后再计数, 输出与触发插件
+			pool.wg.Add(1)
+			go pool.doVerify(bl, params)
+		} else if bl.IsValid {
+			pool.found(bl, params)
+		} else if !pool.closed {
 			// 如果任务被取消, 所有还没处理的请求结果都会被丢弃
 			pool.putToOutput(bl)
 		}
@@ -506,6 +728,60 @@ func (pool *BrutePool) Handler() {
 	pool.analyzeDone = true
 }
 
+// exprBaselines index与random的expr环境只在baseline变化(例如Upgrade重新初始化)时重新构造, 构造后只读, 可以在多个goroutine中共享
+func (pool *BrutePool) exprBaselines() (*pkg.ExprBaseline, *pkg.ExprBaseline) {
+	pool.exprLocker.Lock()
+	defer pool.exprLocker.Unlock()
+	if pool.exprIndex == nil || pool.exprIndex.Baseline != pool.index {
+		pool.exprIndex = pkg.NewExprBaseline(pool.index)
+	}
+	if pool.exprRandom == nil || pool.exprRandom.Baseline != pool.random {
+		pool.exprRandom = pkg.NewExprBaseline(pool.random)
+	}
+	return pool.exprIndex, pool.exprRandom
+}
+
+// found 记录最终确认有效的结果, 在复核与--hidden-only判断之后调用, 触发listing, bypass等插件, 目录检测完成后判断是否递归并输出
+func (pool *BrutePool) found(bl *pkg.Baseline, params map[string]interface{}) {
+	atomic.AddInt64(&pool.Statistor.FoundNumber, 1)
+	pool.foundLocker.Lock()
+	pool.ValidURLs = append(pool.ValidURLs, bl.UrlString)
+	pool.Statistor.AddFrameworks(bl.Frameworks)
+	pool.foundLocker.Unlock()
+	pool.doListing(bl)
+	pool.doBypass(bl)
+	pool.doGraphQL(bl)
+	pool.doAPIExpand(bl)
+	pool.doAutoWord(bl)
+	if pool.needCheckDir(bl) {
+		// 目录检测需要额外的请求, 异步进行, 完成后再输出
+		pool.wg.Add(1)
+		go func() {
+			defer pool.wg.Done()
+			pool.checkDir(bl)
+			pool.emit(bl, params)
+		}()
+		return
+	}
+	pool.emit(bl, params)
+}
+
+// emit 判断有效结果是否递归后输出, 开启--cors时先进行cors/jsonp探测
+func (pool *BrutePool) emit(bl *pkg.Baseline, params map[string]interface{}) {
+	bl.Recu = pool.recursive(bl, params)
+	if pool.CORS && pool.Mod == PathSpray {
+		pool.wg.Add(1)
+		go pool.doCORS(bl)
+	} else if !pool.closed {
+		pool.putToOutput(bl)
+	}
+}
+
+// recursive 如果要进行递归判断, 要满足 bl有效, mod为path-spray, 当前深度小于最大递归深度
+func (pool *BrutePool) recursive(bl *pkg.Baseline, params map[string]interface{}) bool {
+	return bl.RecuDepth < pool.MaxRecursionDepth && pool.Mod != ParamSpray && pkg.CompareWithExpr(pool.RecuExpr, params)
+}
+
 func (pool *BrutePool) checkRedirect(redirectURL string) bool {
 	if pool.random.RedirectURL == "" {
 		// 如果random的redirectURL为空, 忽略
@@ -537,9 +813,10 @@ func (pool *BrutePool) Upgrade(bl *pkg.Baseline) error {
 	return nil
 }
 
-func (pool *BrutePool) PreCompare(resp *ihttp.Response) error {
+func (pool *BrutePool) PreCompare(path string, resp *ihttp.Response) error {
 	status := resp.StatusCode()
-	if iutils.IntsContains(pkg.WhiteStatus, status) {
+	white, black := pkg.StatusOfPath(path)
+	if white.Contains(status) {
 		// 如果为白名单状态码则直接返回
 		return nil
 	}
@@ -547,11 +824,11 @@ func (pool *BrutePool) PreCompare(resp *ihttp.Response) error {
 	//	return pkg.ErrSameStatus
 	//}
 
-	if iutils.IntsContains(pkg.BlackStatus, status) {
+	if black.Contains(status) {
 		return pkg.ErrBadStatus
 	}
 
-	if iutils.IntsContains(pkg.WAFStatus, status) {
+	if pkg.WAFStatus.Contains(status) {
 		return pkg.ErrWaf
 	}
 
@@ -588,6 +865,7 @@ func (pool *BrutePool) BaseCompare(bl *pkg.Baseline) bool {
 	if bl.RedirectURL != "" {
 		if bl.SameRedirectDomain && strings.HasSuffix(bl.RedirectURL, bl.Url.Path+"/") {
 			bl.Reason = pkg.ErrFuzzyRedirect.Error()
+			pool.explain(bl, "redirect to %s", bl.RedirectURL)
 			return false
 		}
 	}
@@ -596,6 +874,14 @@ func (pool *BrutePool) BaseCompare(bl *pkg.Baseline) bool {
 	base, ok := pool.baselines[bl.Status] // 挑选对应状态码的baseline进行compare
 	if bl.IsBaseline {
 		ok = false
+	} else if random := pool.methodRandom(bl); random != nil && random.Status == bl.Status {
+		// 方法变体优先与同一方法的random baseline对比
+		ok = true
+		base = random
+	} else if random := pool.dirRandom(bl); random != pool.random && random.Status == bl.Status {
+		// 子目录中的结果优先与该目录的random baseline对比
+		ok = true
+		base = random
 	}
 	if !ok {
 		if pool.random.Status == bl.Status {
@@ -612,6 +898,7 @@ func (pool *BrutePool) BaseCompare(bl *pkg.Baseline) bool {
 	if ok {
 		if status = base.Compare(bl); status == 1 {
 			bl.Reason = pkg.ErrCompareFailed.Error()
+			pool.explain(bl, "status %d length %d same as baseline %s", bl.Status, bl.BodyLength, base.UrlString)
 			return false
 		}
 	}
@@ -632,6 +919,10 @@ func (pool *BrutePool) BaseCompare(bl *pkg.Baseline) bool {
 	if ok && status == 0 && base.FuzzyCompare(bl) {
 		pool.Statistor.FuzzyNumber++
 		bl.Reason = pkg.ErrFuzzyCompareFailed.Error()
+		if pool.Explain {
+			// SimilarityDetail需要重新计算相似度, 只在--explain时调用
+			pool.explain(bl, "%s to baseline %s", base.SimilarityDetail(bl), base.UrlString)
+		}
 		pool.putToFuzzy(bl)
 		return false
 	}
@@ -640,7 +931,7 @@ func (pool *BrutePool) BaseCompare(bl *pkg.Baseline) bool {
 }
 
 func (pool *BrutePool) addFuzzyBaseline(bl *pkg.Baseline) {
-	if _, ok := pool.baselines[bl.Status]; !ok && (EnableAllFuzzy || iutils.IntsContains(pkg.FuzzyStatus, bl.Status)) {
+	if _, ok := pool.baselines[bl.Status]; !ok && pkg.FuzzyStatus.Contains(bl.Status) {
 		bl.IsBaseline = true
 		bl.Collect()
 		pool.doCrawl(bl) // 非有效页面也可能存在一些特殊的url可以用来爬取
@@ -664,6 +955,11 @@ func (pool *BrutePool) Close() {
 		// 等待缓存的待处理任务完成
 		time.Sleep(time.Duration(100) * time.Millisecond)
 	}
+	if pool.spill != nil {
+		pool.spill.Close()
+		<-pool.spillExit
+		pool.outSpill.Close()
+	}
 	close(pool.additionCh) // 关闭addition管道
 	//close(pool.checkCh)    // 关闭check管道
 	pool.Statistor.EndTime = time.Now().Unix()
@@ -705,6 +1001,41 @@ func (pool *BrutePool) doCheck() {
 	}
 }
 
+// 暂时性的错误状态码先不做判断, 等任务结束前重新请求
+func (pool *BrutePool) shouldDefer(unit *Unit, bl *pkg.Baseline) bool {
+	if unit.deferred >= pool.DeferLimit || unit.source <= parsers.InitIndexSource || unit.source == parsers.CheckSource {
+		return false
+	}
+	return pkg.DeferStatus.Contains(bl.Status)
+}
+
+func (pool *BrutePool) addDeferred(unit *Unit) {
+	pool.deferLocker.Lock()
+	unit.deferred++
+	pool.deferred = append(pool.deferred, unit)
+	pool.deferLocker.Unlock()
+	pool.wg.Done() // 重放时会重新add
+}
+
+// replayDeferred 重放所有延迟的请求, 没有需要重放的请求时返回false
+func (pool *BrutePool) replayDeferred() bool {
+	pool.deferLocker.Lock()
+	units := pool.deferred
+	pool.deferred = nil
+	pool.deferLocker.Unlock()
+	if len(units) == 0 || pool.ctx.Err() != nil {
+		return false
+	}
+
+	logs.Log.Logf(pkg.LogVerbose, "[defer] %s replay %d deferred requests", pool.BaseURL, len(units))
+	time.Sleep(time.Second) // 给目标一点恢复时间
+	for _, unit := range units {
+		pool.wg.Add(1)
+		pool.reqPool.Invoke(unit)
+	}
+	return true
+}
+
 func (pool *BrutePool) doRedirect(bl *pkg.Baseline, depth int) {
 	if depth >= pool.MaxRedirect {
 		return
@@ -745,11 +1076,44 @@ func (pool *BrutePool) doCrawl(bl *pkg.Baseline) {
 	go func() {
 		defer pool.wg.Done()
 		for _, u := range bl.URLs {
+			// 其他域名的链接只交给scope crawl处理, 避免将其路径拼接到当前目标上
+			if v, err := url.Parse(u); err != nil || (v.Host != "" && v.Host != bl.Url.Host) {
+				continue
+			}
 			if u = pkg.FormatURL(bl.Url.Path, u); u == "" {
 				continue
 			}
+			// 链接所在的各级目录同样加入队列, 由urls去重
+			for _, p := range append(crawlDirs(u), u) {
+				pool.addAddition(&Unit{
+					path:   p,
+					parent: bl.Number,
+					host:   bl.Host,
+					source: parsers.CrawlSource,
+					from:   bl.Source,
+					depth:  bl.ReqDepth + 1,
+				})
+			}
+		}
+	}()
+
+}
+
+// doListing --listing, 将目录列表中的条目加入队列, 子目录的列表会继续被解析, 深度受--crawl-depth限制
+func (pool *BrutePool) doListing(bl *pkg.Baseline) {
+	if !pool.Listing || bl.ReqDepth >= pool.MaxCrawlDepth {
+		return
+	}
+	if !pool.listed(bl) || len(bl.ListingEntries) == 0 {
+		return
+	}
+
+	pool.wg.Add(1)
+	go func() {
+		defer pool.wg.Done()
+		for _, entry := range bl.ListingEntries {
 			pool.addAddition(&Unit{
-				path:   u,
+				path:   pkg.Dir(bl.Url.Path) + entry,
 				parent: bl.Number,
 				host:   bl.Host,
 				source: parsers.CrawlSource,
@@ -758,7 +1122,29 @@ func (pool *BrutePool) doCrawl(bl *pkg.Baseline) {
 			})
 		}
 	}()
+}
+
+// listed 判断结果是否为目录列表, 只在--listing下检测
+func (pool *BrutePool) listed(bl *pkg.Baseline) bool {
+	if !pool.Listing {
+		return false
+	}
+	bl.CollectListing()
+	return bl.Listing
+}
 
+// crawlDirs 返回路径的各级父目录, e.g.: /static/js/app.js => /static/, /static/js/
+func crawlDirs(p string) []string {
+	if i := strings.IndexAny(p, "?#"); i != -1 {
+		p = p[:i]
+	}
+	var dirs []string
+	for i := 1; i < len(p); i++ {
+		if p[i] == '/' && i != len(p)-1 {
+			dirs = append(dirs, p[:i+1])
+		}
+	}
+	return dirs
 }
 
 func (pool *BrutePool) doScopeCrawl(bl *pkg.Baseline) {
@@ -775,8 +1161,7 @@ func (pool *BrutePool) doScopeCrawl(bl *pkg.Baseline) {
 					continue
 				}
 				pool.scopeLocker.Lock()
-				if _, ok := pool.scopeurls[u]; !ok {
-					pool.urls.Store(u, nil)
+				if !pool.scopeurls.TestAndAdd(u) {
 					pool.wg.Add(1)
 					pool.scopePool.Invoke(&Unit{
 						path:   u,
@@ -794,15 +1179,16 @@ func (pool *BrutePool) doScopeCrawl(bl *pkg.Baseline) {
 
 func (pool *BrutePool) doBak() {
 	defer pool.wg.Done()
-	if pool.Mod == HostSpray {
+	if pool.Mod != PathSpray {
 		return
 	}
-	//for w := range NewBruteDSL(pool.Config, "{?0}.{?@bak_ext}", [][]string{pkg.BakGenerator(pool.url.Host)}).Output {
-	//	pool.addAddition(&Unit{
-	//		path:   pool.dir + w,
-	//		source: parsers.BakSource,
-	//	})
-	//}
+	// 以域名命名的整站备份, e.g.: example.com.zip, www_example_com.tar.gz
+	for w := range NewBruteDSL(pool.Config, "{?0}.{?@bak_ext}", [][]string{pkg.BakHostNames(pool.url.Host)}).Output {
+		pool.addAddition(&Unit{
+			path:   pool.dir + w,
+			source: parsers.BakSource,
+		})
+	}
 
 	for w := range NewBruteDSL(pool.Config, "{?@bak_name}.{?@bak_ext}", nil).Output {
 		pool.addAddition(&Unit{
@@ -812,10 +1198,36 @@ func (pool *BrutePool) doBak() {
 	}
 }
 
+// doBakDir 为发现的目录生成同名的压缩包, e.g.: /static/admin/ => /static/admin.zip, /static/admin.tar.gz
+// 文件的备份(.bak, ~, .swp等)由filebak规则生成
+func (pool *BrutePool) doBakDir(bl *pkg.Baseline) {
+	if !pool.Bak || !bl.IsValid || !bl.IsDir() || bl.Path == "/" || bl.Source == parsers.BakSource {
+		pool.wg.Done()
+		return
+	}
+
+	go func() {
+		defer pool.wg.Done()
+		dir := strings.TrimSuffix(bl.Path, "/")
+		parent, name := pkg.Dir(dir), path.Base(dir)
+		for w := range NewBruteDSL(pool.Config, "{?0}.{?@bak_ext}", [][]string{{name}}).Output {
+			pool.addAddition(&Unit{
+				path:   parent + w,
+				parent: bl.Number,
+				host:   bl.Host,
+				source: parsers.BakSource,
+				from:   bl.Source,
+				depth:  bl.ReqDepth + 1,
+			})
+		}
+	}()
+}
+
 func (pool *BrutePool) doAppend(bl *pkg.Baseline) {
-	pool.wg.Add(2)
+	pool.wg.Add(3)
 	pool.doAppendWords(bl)
 	pool.doAppendRule(bl)
+	pool.doBakDir(bl)
 }
 
 func (pool *BrutePool) doAppendRule(bl *pkg.Baseline) {
@@ -864,7 +1276,7 @@ func (pool *BrutePool) doAppendWords(bl *pkg.Baseline) {
 
 func (pool *BrutePool) doActive() {
 	defer pool.wg.Done()
-	if pool.Mod == HostSpray {
+	if pool.Mod != PathSpray {
 		return
 	}
 	for _, u := range pkg.ActivePath {
@@ -877,7 +1289,7 @@ func (pool *BrutePool) doActive() {
 
 func (pool *BrutePool) doCommonFile() {
 	defer pool.wg.Done()
-	if pool.Mod == HostSpray {
+	if pool.Mod != PathSpray {
 		return
 	}
 	for u := range NewBruteWords(pool.Config, append(pkg.Dicts["common"], pkg.Dicts["log"]...)).Output {
@@ -887,3 +1299,17 @@ func (pool *BrutePool) doCommonFile() {
 		})
 	}
 }
+
+func (pool *BrutePool) quickCheck(bl *pkg.Baseline) *pkg.QuickCheck {
+	if !pool.Quick || bl.Source != parsers.WordSource {
+		return nil
+	}
+	return pkg.FindQuickCheck(bl.Path)
+}
+
+// addTechs 将外部输入(例如httpx的tech字段)中已知的指纹合并到index中
+func (pool *BrutePool) addTechs(bl *pkg.Baseline) {
+	for _, tech := range pool.Techs {
+		bl.Frameworks.Add(common.NewFramework(tech, common.FrameFromWappalyzer))
+	}
+}
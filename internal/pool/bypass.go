@@ -0,0 +1,115 @@
+package pool
+
+import (
+	"github.com/chainreactors/spray/pkg"
+	"github.com/chainreactors/utils/iutils"
+	"strings"
+)
+
+var BypassStatus = []int{401, 403}
+
+type bypassMutation struct {
+	name   string
+	mutate func(u *Unit)
+}
+
+func withHeader(key, value string) func(u *Unit) {
+	return func(u *Unit) {
+		u.headers = map[string]string{key: value}
+	}
+}
+
+func withOriginalPath(key string) func(u *Unit) {
+	return func(u *Unit) {
+		u.headers = map[string]string{key: u.path}
+		u.path = "/"
+	}
+}
+
+// withLastSegment 只改写路径的最后一段, 大小写不敏感的后端(iis, windows)仍然可以命中原路径
+func withLastSegment(fn func(string) string) func(u *Unit) {
+	return func(u *Unit) {
+		p := strings.TrimSuffix(u.path, "/")
+		i := strings.LastIndex(p, "/") + 1
+		u.path = p[:i] + fn(p[i:]) + u.path[len(p):]
+	}
+}
+
+var bypassMutations = []bypassMutation{
+	// verb
+	{"method-post", func(u *Unit) { u.method = "POST" }},
+	{"method-put", func(u *Unit) { u.method = "PUT" }},
+	{"method-override", func(u *Unit) {
+		u.method = "POST"
+		u.headers = map[string]string{"X-HTTP-Method-Override": "GET"}
+	}},
+
+	// path trick
+	{"trailing-slash", func(u *Unit) { u.path = strings.TrimSuffix(u.path, "/") + "/" }},
+	{"trailing-dot", func(u *Unit) { u.path = strings.TrimSuffix(u.path, "/") + "/." }},
+	{"double-slash", func(u *Unit) { u.path = "/" + u.path + "/" }},
+	{"dot-segment", func(u *Unit) { u.path = "/." + u.path + "/./" }},
+	{"encoded-dot", func(u *Unit) { u.path = "/%2e" + u.path }},
+	{"semicolon", func(u *Unit) { u.path = strings.TrimSuffix(u.path, "/") + "..;/" }},
+	{"trailing-semicolon", func(u *Unit) { u.path = strings.TrimSuffix(u.path, "/") + ";/" }},
+
+	// path case
+	{"upper-case", withLastSegment(strings.ToUpper)},
+	{"title-case", withLastSegment(func(s string) string {
+		if s == "" {
+			return s
+		}
+		return strings.ToUpper(s[:1]) + s[1:]
+	})},
+
+	// trailing characters
+	{"trailing-space", func(u *Unit) { u.path += "%20" }},
+	{"trailing-tab", func(u *Unit) { u.path += "%09" }},
+	{"trailing-question", func(u *Unit) { u.path += "?" }},
+	{"trailing-json", func(u *Unit) { u.path = strings.TrimSuffix(u.path, "/") + ".json" }},
+
+	// rewrite header
+	{"x-original-url", withOriginalPath("X-Original-URL")},
+	{"x-rewrite-url", withOriginalPath("X-Rewrite-URL")},
+	{"x-forwarded-for", withHeader("X-Forwarded-For", "127.0.0.1")},
+	{"x-custom-ip-authorization", withHeader("X-Custom-IP-Authorization", "127.0.0.1")},
+}
+
+// 对401/403的有效结果, 尝试一组已知的绕过手法
+func (pool *BrutePool) doBypass(bl *pkg.Baseline) {
+	if !pool.Bypass || pool.Mod != PathSpray || bl.Source == pkg.BypassSource || !iutils.IntsContains(BypassStatus, bl.Status) {
+		return
+	}
+
+	pool.wg.Add(1)
+	go func() {
+		defer pool.wg.Done()
+		for _, m := range bypassMutations {
+			u := &Unit{
+				path:   bl.Path,
+				parent: bl.Number,
+				host:   bl.Host,
+				source: pkg.BypassSource,
+				from:   bl.Source,
+				depth:  bl.ReqDepth + 1,
+				bypass: m.name,
+			}
+			m.mutate(u)
+			pool.addAddition(u)
+		}
+	}()
+}
+
+// 只有返回2xx, 且与index/random不相同的变体才认为绕过成功
+func (pool *BrutePool) checkBypass(bl *pkg.Baseline) bool {
+	if !bl.IsValid || bl.Status < 200 || bl.Status >= 300 {
+		return false
+	}
+	if pool.index != nil && pool.index.Compare(bl) != -1 {
+		return false
+	}
+	if pool.random != nil && pool.random.Compare(bl) != -1 {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,108 @@
+package pool
+
+import (
+	"bytes"
+	"container/list"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/chainreactors/spray/internal/ihttp"
+	"github.com/valyala/fasthttp"
+)
+
+// responseCaches 同一origin的所有pool共享响应缓存, 递归, 备份文件, quick等阶段重复请求同一url时直接返回缓存
+var responseCaches sync.Map
+
+func getResponseCache(host string, size int) *responseCache {
+	if size <= 0 {
+		return nil
+	}
+	cache, _ := responseCaches.LoadOrStore(host, &responseCache{
+		size:  size,
+		items: make(map[string]*list.Element),
+		lru:   list.New(),
+	})
+	return cache.(*responseCache)
+}
+
+type cachedResponse struct {
+	key    string
+	fast   *fasthttp.Response
+	status int
+	proto  string
+	header http.Header
+	body   []byte
+	chain  []*ihttp.Redirect
+}
+
+// responseCache 固定大小的lru缓存, 只缓存不带body的请求
+type responseCache struct {
+	locker sync.Mutex
+	size   int
+	items  map[string]*list.Element
+	lru    *list.List
+}
+
+func (c *responseCache) Get(key string) (*ihttp.Response, bool) {
+	c.locker.Lock()
+	defer c.locker.Unlock()
+	e, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	c.lru.MoveToFront(e)
+	cached := e.Value.(*cachedResponse)
+	if cached.fast != nil {
+		resp := fasthttp.AcquireResponse()
+		cached.fast.CopyTo(resp)
+		return &ihttp.Response{FastResponse: resp, ClientType: ihttp.FAST, Redirects: cached.chain}, true
+	}
+	return &ihttp.Response{StandardResponse: &http.Response{
+		StatusCode:    cached.status,
+		Proto:         cached.proto,
+		Header:        cached.header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(cached.body)),
+		ContentLength: int64(len(cached.body)),
+	}, ClientType: ihttp.STANDARD, Redirects: cached.chain}, true
+}
+
+// Put 缓存响应, 标准库的响应body会被读取, 并替换为可重复读取的reader
+func (c *responseCache) Put(key string, resp *ihttp.Response) {
+	cached := &cachedResponse{key: key, chain: resp.Redirects}
+	if resp.FastResponse != nil {
+		cached.fast = fasthttp.AcquireResponse()
+		resp.FastResponse.CopyTo(cached.fast)
+	} else if resp.StandardResponse != nil {
+		body := resp.Body()
+		resp.StandardResponse.Body = io.NopCloser(bytes.NewReader(body))
+		resp.StandardResponse.ContentLength = int64(len(body))
+		cached.status = resp.StandardResponse.StatusCode
+		cached.proto = resp.StandardResponse.Proto
+		cached.header = resp.StandardResponse.Header.Clone()
+		cached.body = body
+	} else {
+		return
+	}
+
+	c.locker.Lock()
+	defer c.locker.Unlock()
+	if _, ok := c.items[key]; ok {
+		c.release(cached)
+		return
+	}
+	c.items[key] = c.lru.PushFront(cached)
+	for c.lru.Len() > c.size {
+		e := c.lru.Back()
+		c.lru.Remove(e)
+		old := e.Value.(*cachedResponse)
+		delete(c.items, old.key)
+		c.release(old)
+	}
+}
+
+func (c *responseCache) release(cached *cachedResponse) {
+	if cached.fast != nil {
+		fasthttp.ReleaseResponse(cached.fast)
+	}
+}
@@ -0,0 +1,142 @@
+package pool
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/chainreactors/logs"
+	"github.com/chainreactors/spray/pkg"
+)
+
+const (
+	calibrateSize         = "size"
+	calibrateStrippedSize = "stripped"
+	calibrateWords        = "words"
+	calibrateLines        = "lines"
+)
+
+// calibrateProbes 自动校准使用的随机路径模板, {r}会被替换为16位随机字符串, {s}为4位
+// 覆盖不同长度, 常见后缀, 目录与多级目录, 以及隐藏文件, 尽可能触发服务端不同的404处理逻辑
+var calibrateProbes = []string{
+	"{r}",
+	"{s}",
+	"{r}{r}{r}",
+	"{r}/",
+	"{r}/{r}",
+	".{r}",
+	"{r}.php",
+	"{r}.html",
+	"{r}.aspx",
+	"{r}.jsp",
+	"{r}.js",
+	"{r}.json",
+	"admin{r}",
+}
+
+// calibration 一类随机路径的响应特征, 相同状态码下选取所有样本都一致的属性作为过滤条件
+type calibration struct {
+	status int
+	attr   string
+	value  int
+}
+
+func (c *calibration) String() string {
+	return fmt.Sprintf("status %d %s %d", c.status, c.attr, c.value)
+}
+
+// calibrateToken 路径的最后一段, 即字典中的word, 用于去除body中的反射
+func calibrateToken(p string) string {
+	return path.Base(strings.TrimSuffix(p, "/"))
+}
+
+func calibrateValue(bl *pkg.Baseline, attr, token string) int {
+	switch attr {
+	case calibrateSize:
+		return bl.BodyLength
+	case calibrateStrippedSize:
+		// 部分404页面会将请求的路径反射到body中, 去掉路径后的长度依然一致
+		if token == "" {
+			return bl.BodyLength
+		}
+		return len(bytes.ReplaceAll(bl.Body, []byte(token), nil))
+	case calibrateWords:
+		return bl.Words
+	case calibrateLines:
+		return bl.Lines
+	}
+	return -1
+}
+
+// calibrate --auto-calibrate, 请求多个随机的不存在路径并按状态码聚类, 为每一类推导出过滤条件
+// 比单个random baseline的simhash对比更能适应路径反射, 后缀路由等导致的404页面变化
+func (pool *BrutePool) calibrate() {
+	if !pool.AutoCalibrate || pool.Mod != PathSpray {
+		return
+	}
+	var samples []calibrateSample
+	for _, probe := range calibrateProbes {
+		r := pkg.RandPath()
+		p := pool.safePath(strings.NewReplacer("{r}", r, "{s}", r[:4]).Replace(probe))
+		if bl := pool.fetchPath(p); bl != nil && bl.IsValid {
+			samples = append(samples, calibrateSample{bl, calibrateToken(p)})
+		}
+	}
+	pool.learnCalibrations("calibrate", samples)
+}
+
+type calibrateSample struct {
+	bl    *pkg.Baseline
+	token string
+}
+
+// learnCalibrations 将样本按状态码聚类, 每一类选取所有样本都一致的属性作为过滤条件
+func (pool *BrutePool) learnCalibrations(tag string, samples []calibrateSample) {
+	clusters := make(map[int][]calibrateSample)
+	var statuses []int
+	for _, s := range samples {
+		if _, ok := clusters[s.bl.Status]; !ok {
+			statuses = append(statuses, s.bl.Status)
+		}
+		clusters[s.bl.Status] = append(clusters[s.bl.Status], s)
+	}
+
+	for _, status := range statuses {
+		samples := clusters[status]
+		for _, attr := range []string{calibrateSize, calibrateStrippedSize, calibrateWords, calibrateLines} {
+			value := calibrateValue(samples[0].bl, attr, samples[0].token)
+			same := true
+			for _, s := range samples[1:] {
+				if calibrateValue(s.bl, attr, s.token) != value {
+					same = false
+					break
+				}
+			}
+			if same {
+				c := &calibration{status: status, attr: attr, value: value}
+				pool.calibrations = append(pool.calibrations, c)
+				logs.Log.Logf(pkg.LogVerbose, "[%s] %s %s, %d samples", tag, pool.BaseURL, c.String(), len(samples))
+				break
+			}
+		}
+	}
+}
+
+// calibrated 返回结果命中的自动校准过滤条件, 未命中返回nil
+func (pool *BrutePool) calibrated(bl *pkg.Baseline) *calibration {
+	if len(pool.calibrations) == 0 || bl.IsBaseline {
+		return nil
+	}
+	token := calibrateToken(bl.Path)
+	if pool.Mod == HostSpray {
+		// host模式下反射到body中的是请求的host
+		token = bl.Host
+	}
+	for _, c := range pool.calibrations {
+		if c.status == bl.Status && calibrateValue(bl, c.attr, token) == c.value {
+			return c
+		}
+	}
+	return nil
+}
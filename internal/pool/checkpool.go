@@ -16,7 +16,9 @@ import (
 // 类似httpx的无状态, 无scope, 无并发池的检测模式
 func NewCheckPool(ctx context.Context, config *Config) (*CheckPool, error) {
 	pctx, cancel := context.WithCancel(ctx)
-	config.ClientType = ihttp.STANDARD
+	if config.ClientType != ihttp.H3 {
+		config.ClientType = ihttp.STANDARD
+	}
 	pool := &CheckPool{
 		BasePool: &BasePool{
 			Config:    config,
@@ -28,11 +30,19 @@ func NewCheckPool(ctx context.Context, config *Config) (*CheckPool, error) {
 				Type:      config.ClientType,
 				Timeout:   config.Timeout,
 				ProxyAddr: config.ProxyAddr,
+				Signer:    config.Signer,
+				TLS:       config.TLS,
+				ProxyPool: config.ProxyPool,
+				HTTP2:     config.HTTP2,
+				Browser:   config.Impersonate,
+				Auth:      config.Auth,
+				Redirects: config.FollowRedirect,
 			}),
 			wg:         &sync.WaitGroup{},
 			additionCh: make(chan *Unit, 1024),
 			closeCh:    make(chan struct{}),
 			processCh:  make(chan *pkg.Baseline, config.Thread),
+			replay:     newReplayClient(config),
 		},
 	}
 	pool.Headers = map[string]string{"Connection": "close"}
@@ -128,6 +138,9 @@ func (pool *CheckPool) Invoke(v interface{}) {
 		return
 	}
 	req.SetHeaders(pool.Headers)
+	if pool.RandomUserAgent {
+		req.SetHeader("User-Agent", pool.userAgent())
+	}
 	start := time.Now()
 	var bl *pkg.Baseline
 	resp, reqerr := pool.client.Do(req)
@@ -142,6 +155,9 @@ func (pool *CheckPool) Invoke(v interface{}) {
 				ReqDepth:  unit.depth,
 			},
 		}
+		if ihttp.IsTLSError(reqerr) {
+			bl.Reason = pkg.ErrTLSHandshake.Error()
+		}
 		logs.Log.Debugf("%s, %s", unit.path, reqerr.Error())
 		pool.doUpgrade(bl)
 	} else {
@@ -165,11 +181,11 @@ func (pool *CheckPool) Handler() {
 				pool.doRedirect(bl, bl.ReqDepth)
 				pool.putToOutput(bl)
 			} else {
-				params := map[string]interface{}{
-					"current": bl,
-				}
-				if pool.MatchExpr != nil && pkg.CompareWithExpr(pool.MatchExpr, params) {
-					bl.IsValid = true
+				params := pkg.NewExprEnv(bl, nil, nil)
+				if pool.MatchExpr != nil {
+					if ok, _ := pool.MatchExpr.Eval(params); ok {
+						bl.IsValid = true
+					}
 				}
 			}
 		}
@@ -2,10 +2,12 @@ package pool
 
 import (
 	"github.com/chainreactors/logs"
+	"github.com/chainreactors/spray/internal/ihttp"
 	"github.com/chainreactors/spray/pkg"
 	"github.com/chainreactors/words"
 	"github.com/chainreactors/words/rule"
 	"github.com/expr-lang/expr/vm"
+	"golang.org/x/time/rate"
 	"sync"
 	"time"
 )
@@ -13,7 +15,14 @@ import (
 type Config struct {
 	BaseURL           string
 	ProxyAddr         string
+	ProxyPool         *ihttp.ProxyPool
 	Thread            int
+	HostConcurrency   int
+	CacheSize         int
+	ScaleErrorRate    float64
+	SpillLimit        int
+	BloomSize         int
+	BloomFP           float64
 	Wordlist          []string
 	Timeout           time.Duration
 	ProcessCh         chan *pkg.Baseline
@@ -21,31 +30,74 @@ type Config struct {
 	FuzzyCh           chan *pkg.Baseline
 	Outwg             *sync.WaitGroup
 	RateLimit         int
+	GlobalLimiter     *rate.Limiter
+	Pauser            *Pauser
+	RatePerHost       int
+	AutoThrottle      bool
+	MaxTime           time.Duration
 	CheckPeriod       int
 	ErrPeriod         int32
 	BreakThreshold    int32
 	Method            string
+	Methods           []string // --methods, 每个word额外尝试的方法
+	Resolvers         []string // -m dns使用的dns服务器
+	DNSProbe          bool
+	Body              []byte
+	CookieJar         bool
+	RequestTemplate   *ihttp.RequestTemplate
 	Mod               SprayMod
 	Headers           map[string]string
 	ClientType        int
-	MatchExpr         *vm.Program
-	FilterExpr        *vm.Program
+	Signer            ihttp.Signer
+	ResponseHook      *pkg.ResponseHook
+	Auth              *ihttp.HTTPAuth
+	TLS               *ihttp.TLSOptions
+	HTTP2             string
+	Impersonate       string
+	VerifyWith        string
+	ReplayProxy       string
+	MatchExpr         *pkg.Matchers
+	FilterExpr        *pkg.Matchers
 	RecuExpr          *vm.Program
+	ResponseFilter    *pkg.ResponseFilter
+	PrecheckExpr      *vm.Program
 	AppendRule        *rule.Program
 	Fns               []words.WordFunc
 	AppendWords       []string
 	Fuzzy             bool
+	Explain           bool
 	IgnoreWaf         bool
+	WafDetect         bool
+	WafEvasion        bool // 识别到waf后切换到低速的规避配置
+	Favicon           bool
 	Crawl             bool
 	Scope             []string
 	Active            bool
 	Bak               bool
 	Common            bool
+	Bypass            bool
+	GraphQL           bool
+	APIExpand         bool
+	CORS              bool
+	DirCheck          bool
+	Listing           bool
+	AutoWord          bool
+	AutoWordLimit     int // 每个目标最多学习的word数量
+	Quick             bool
+	HiddenOnly        bool
+	AutoCalibrate     bool
+	Linked            *pkg.LinkedStore
+	Techs             []string
 	RetryLimit        int
+	DeferLimit        int
 	RandomUserAgent   bool
+	AgentRotate       string
 	Random            string
 	Index             string
 	MaxRedirect       int
+	FollowRedirect    int // client内跟随重定向的最大次数, 0为不跟随
+	FollowLimit       int // --redirect-rule决定跟随时的最大次数
+	RedirectRule      *vm.Program
 	MaxCrawlDepth     int
 	MaxRecursionDepth int
 	MaxAppendDepth    int
@@ -0,0 +1,74 @@
+package pool
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/chainreactors/spray/internal/ihttp"
+)
+
+// cookieJars 同一origin的所有pool共享cookie, 递归产生的pool不需要重新建立会话
+var cookieJars sync.Map
+
+func getCookieJar(host string, enable bool) *cookieJar {
+	if !enable {
+		return nil
+	}
+	jar, _ := cookieJars.LoadOrStore(host, &cookieJar{cookies: make(map[string]string)})
+	return jar.(*cookieJar)
+}
+
+// cookieJar --cookie-jar, 记录index/random等初始请求返回的Set-Cookie, 并在之后的每个请求中带上
+type cookieJar struct {
+	locker  sync.RWMutex
+	names   []string
+	cookies map[string]string
+}
+
+func (jar *cookieJar) Capture(resp *ihttp.Response) {
+	if jar == nil || resp == nil {
+		return
+	}
+	cookies := resp.Cookies()
+	if len(cookies) == 0 {
+		return
+	}
+	jar.locker.Lock()
+	defer jar.locker.Unlock()
+	for name, value := range cookies {
+		if _, ok := jar.cookies[name]; !ok {
+			jar.names = append(jar.names, name)
+		}
+		jar.cookies[name] = value
+	}
+}
+
+// Apply 追加到已有的Cookie header之后, --cookie 中已经指定的同名cookie优先
+func (jar *cookieJar) Apply(req *ihttp.Request) {
+	if jar == nil {
+		return
+	}
+	jar.locker.RLock()
+	defer jar.locker.RUnlock()
+	if len(jar.names) == 0 {
+		return
+	}
+	origin := req.GetHeader("Cookie")
+	exists := make(map[string]struct{})
+	for _, c := range strings.Split(origin, ";") {
+		if name, _, ok := strings.Cut(strings.TrimSpace(c), "="); ok {
+			exists[name] = struct{}{}
+		}
+	}
+	pairs := make([]string, 0, len(jar.names)+1)
+	if origin != "" {
+		pairs = append(pairs, origin)
+	}
+	for _, name := range jar.names {
+		if _, ok := exists[name]; ok {
+			continue
+		}
+		pairs = append(pairs, name+"="+jar.cookies[name])
+	}
+	req.SetHeader("Cookie", strings.Join(pairs, "; "))
+}
@@ -0,0 +1,76 @@
+package pool
+
+import (
+	"strings"
+	"sync/atomic"
+
+	"github.com/chainreactors/logs"
+	"github.com/chainreactors/parsers"
+	"github.com/chainreactors/spray/internal/ihttp"
+	"github.com/chainreactors/spray/pkg"
+	"github.com/valyala/fasthttp"
+)
+
+// ClassifyCORS 根据Access-Control-Allow-*响应头判断cors配置是否宽松, 非宽松配置返回空
+func ClassifyCORS(origin, allowOrigin, allowCredentials string) string {
+	var class string
+	switch allowOrigin {
+	case origin:
+		class = "reflect origin"
+	case "null":
+		class = "allow null origin"
+	case "*":
+		class = "wildcard origin"
+	default:
+		return ""
+	}
+	if strings.EqualFold(allowCredentials, "true") {
+		class += ", allow credentials"
+	}
+	return class
+}
+
+// doCORS 对有效结果追加一次携带Origin与callback参数的请求, 标注cors与jsonp行为后再输出
+func (pool *BrutePool) doCORS(bl *pkg.Baseline) {
+	defer pool.wg.Done()
+	defer func() {
+		if !pool.closed {
+			pool.putToOutput(bl)
+		}
+	}()
+	pool.limiter.Wait(pool.ctx)
+	atomic.AddInt32(&pool.Statistor.ReqTotal, 1)
+
+	origin := "https://" + pkg.RandHost() + ".com"
+	callback := "spray" + pkg.RandHost()
+	req, err := ihttp.BuildRequest(pool.ctx, pool.ClientType, pool.base, bl.Path+"?callback="+callback, "", pool.Method)
+	if err != nil {
+		logs.Log.Error(err.Error())
+		return
+	}
+	req.SetHeaders(pool.Headers)
+	req.SetHeader("Origin", origin)
+	resp, reqerr := pool.do(req)
+	if pool.ClientType == ihttp.FAST {
+		defer fasthttp.ReleaseResponse(resp.FastResponse)
+		defer fasthttp.ReleaseRequest(req.FastRequest)
+	}
+	if reqerr != nil {
+		logs.Log.Debugf("[cors] %s, %s", bl.UrlString, reqerr.Error())
+		return
+	}
+
+	if class := ClassifyCORS(origin, resp.GetHeader("Access-Control-Allow-Origin"), resp.GetHeader("Access-Control-Allow-Credentials")); class != "" {
+		bl.Extracteds = append(bl.Extracteds, &parsers.Extracted{
+			Name:          "cors",
+			ExtractResult: []string{class},
+		})
+	}
+
+	if strings.Contains(string(resp.Body()), callback+"(") {
+		bl.Extracteds = append(bl.Extracteds, &parsers.Extracted{
+			Name:          "jsonp",
+			ExtractResult: []string{"callback"},
+		})
+	}
+}
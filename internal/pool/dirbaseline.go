@@ -0,0 +1,89 @@
+package pool
+
+import (
+	"strings"
+
+	"github.com/chainreactors/logs"
+	"github.com/chainreactors/spray/pkg"
+)
+
+// MaxDirBaselines 单个pool最多为多少个子目录单独建立random baseline, 避免爬虫发现大量目录时请求膨胀
+var MaxDirBaselines = 32
+
+// dirBaseline 子目录的random baseline, ready关闭后random可用
+type dirBaseline struct {
+	ready  chan struct{}
+	random *pkg.Baseline
+}
+
+// baselineDir 结果所在的子目录, 根目录或不需要单独的baseline时返回空
+func (pool *BrutePool) baselineDir(bl *pkg.Baseline) string {
+	if pool.Mod != PathSpray || bl.IsBaseline || bl.Path == "" {
+		return ""
+	}
+	dir := pkg.Dir(strings.TrimSuffix(bl.Path, "/"))
+	if dir == pool.dir || !strings.HasPrefix(dir, pool.dir) {
+		return ""
+	}
+	return dir
+}
+
+// prepareDirRandom 在请求的goroutine中建立结果所在目录的random baseline, 放入processCh之前调用, 避免Handler同步等待请求,
+// 同一目录只请求一次, 其他goroutine等待请求完成
+// 很多应用在不同路径前缀下返回不同的通配页面, 追加字典, 爬虫等进入子目录的结果应当与该目录下的随机路径对比
+func (pool *BrutePool) prepareDirRandom(bl *pkg.Baseline) {
+	if !bl.IsValid {
+		return
+	}
+	dir := pool.baselineDir(bl)
+	if dir == "" {
+		return
+	}
+	pool.dirLocker.Lock()
+	if pool.dirBaselines == nil {
+		pool.dirBaselines = make(map[string]*dirBaseline)
+	}
+	if d, ok := pool.dirBaselines[dir]; ok {
+		pool.dirLocker.Unlock()
+		<-d.ready
+		return
+	}
+	if len(pool.dirBaselines) >= MaxDirBaselines {
+		pool.dirLocker.Unlock()
+		return
+	}
+	d := &dirBaseline{ready: make(chan struct{})}
+	pool.dirBaselines[dir] = d
+	pool.dirLocker.Unlock()
+
+	random := pool.fetchPath(pkg.SafePath(dir, pkg.RandPath()))
+	if random == nil || !random.IsValid {
+		random = pool.random
+	} else {
+		random.IsBaseline = true
+		random.Collect()
+		logs.Log.Logf(pkg.LogVerbose, "[baseline.%s] %s", dir, random.Format([]string{"status", "length", "spend", "title", "frame", "redirect"}))
+	}
+	d.random = random
+	close(d.ready)
+}
+
+// dirRandom 获取结果所在目录的random baseline, 根目录, 达到上限或尚未建立时使用pool的random baseline
+func (pool *BrutePool) dirRandom(bl *pkg.Baseline) *pkg.Baseline {
+	dir := pool.baselineDir(bl)
+	if dir == "" {
+		return pool.random
+	}
+	pool.dirLocker.Lock()
+	d, ok := pool.dirBaselines[dir]
+	pool.dirLocker.Unlock()
+	if !ok {
+		return pool.random
+	}
+	select {
+	case <-d.ready:
+		return d.random
+	default:
+		return pool.random
+	}
+}
@@ -0,0 +1,43 @@
+package pool
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/chainreactors/parsers"
+	"github.com/chainreactors/spray/pkg"
+	"github.com/chainreactors/utils/iutils"
+)
+
+var DirCheckStatus = []int{301, 302, 307, 308, 403}
+
+func (pool *BrutePool) needCheckDir(bl *pkg.Baseline) bool {
+	return pool.DirCheck && pool.Mod == PathSpray && bl.Source != parsers.CheckSource &&
+		iutils.IntsContains(DirCheckStatus, bl.Status) && strings.TrimSuffix(bl.Path, "/") != ""
+}
+
+// checkDir --dir-check, 对301/403的结果分别请求带与不带"/"的路径, 以及目录下的随机文件
+// 合并三次请求的结果判断是否为目录, 记录在bl.DirChecked中, 使--recursive的current.IsDir()更准确.
+// 结果已经参与过去重, 不修改路径, 递归时再补全"/"
+func (pool *BrutePool) checkDir(bl *pkg.Baseline) {
+	name := strings.TrimSuffix(bl.Path, "/")
+
+	noslash, slash := pool.fetchPath(name), pool.fetchPath(name+"/")
+	if noslash == nil || slash == nil {
+		return
+	}
+	var isDir bool
+	if loc := pkg.FormatURL(name, noslash.RedirectURL); noslash.RedirectURL != "" && strings.TrimSuffix(loc, "/") == name && strings.HasSuffix(loc, "/") {
+		// 不带"/"时跳转到带"/"的路径, 是web服务器对目录的标准处理
+		isDir = true
+	} else if slash.Status != 404 && slash.RedirectURL == "" {
+		// 带"/"可以访问时, 通过目录下的随机文件排除所有路径都返回相同响应的情况
+		index := pool.fetchPath(name + "/" + pkg.RandPath())
+		isDir = index == nil || index.Status != slash.Status || index.BodyLength != slash.BodyLength
+	}
+
+	detail := fmt.Sprintf("noslash %d, slash %d, dir %v", noslash.Status, slash.Status, isDir)
+	bl.Extracteds = append(bl.Extracteds, &parsers.Extracted{Name: "dir-check", ExtractResult: []string{detail}})
+	bl.Dir = isDir
+	bl.DirChecked = true
+}
@@ -0,0 +1,255 @@
+package pool
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/chainreactors/logs"
+	"github.com/chainreactors/parsers"
+	"github.com/chainreactors/spray/internal/ihttp"
+	"github.com/chainreactors/spray/pkg"
+	"github.com/panjf2000/ants/v2"
+)
+
+// NewDNSPool -m dns, 将word作为子域名拼接到目标域名上进行解析, 复用字典, 掩码与规则生成
+func NewDNSPool(ctx context.Context, config *Config) (*DNSPool, error) {
+	u, err := url.Parse(config.BaseURL)
+	if err != nil {
+		return nil, err
+	}
+	pctx, cancel := context.WithCancel(ctx)
+	if config.ClientType != ihttp.H3 {
+		config.ClientType = ihttp.STANDARD
+	}
+	pool := &DNSPool{
+		BasePool: &BasePool{
+			Config:    config,
+			Statistor: pkg.NewStatistor(config.BaseURL),
+			ctx:       pctx,
+			Cancel:    cancel,
+			client: ihttp.NewClient(&ihttp.ClientConfig{
+				Thread:    config.Thread,
+				Type:      config.ClientType,
+				Timeout:   config.Timeout,
+				ProxyAddr: config.ProxyAddr,
+				TLS:       config.TLS,
+				ProxyPool: config.ProxyPool,
+				HTTP2:     config.HTTP2,
+				Browser:   config.Impersonate,
+				Auth:      config.Auth,
+				Redirects: config.FollowRedirect,
+			}),
+			wg:        &sync.WaitGroup{},
+			closeCh:   make(chan struct{}),
+			processCh: make(chan *pkg.Baseline, config.Thread),
+		},
+		domain:   strings.TrimPrefix(u.Hostname(), "*."),
+		resolver: newDNSResolver(config.Resolvers, config.Timeout),
+		wildcard: make(map[string]struct{}),
+	}
+	pool.Pool, _ = ants.NewPoolWithFunc(config.Thread, pool.Invoke)
+	go pool.Handler()
+	return pool, nil
+}
+
+type DNSPool struct {
+	*BasePool
+	Pool     *ants.PoolWithFunc
+	domain   string
+	resolver *net.Resolver
+	wildcard map[string]struct{} // 泛解析返回的ip
+}
+
+// newDNSResolver 指定了--resolver时轮流使用其中的dns服务器, 否则使用系统配置
+func newDNSResolver(servers []string, timeout time.Duration) *net.Resolver {
+	if len(servers) == 0 {
+		return net.DefaultResolver
+	}
+	addrs := make([]string, len(servers))
+	for i, s := range servers {
+		if _, _, err := net.SplitHostPort(s); err != nil {
+			s = net.JoinHostPort(s, "53")
+		}
+		addrs[i] = s
+	}
+	var next uint32
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			d := net.Dialer{Timeout: timeout}
+			return d.DialContext(ctx, network, addrs[atomic.AddUint32(&next, 1)%uint32(len(addrs))])
+		},
+	}
+}
+
+func (pool *DNSPool) lookup(host string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(pool.ctx, pool.Timeout)
+	defer cancel()
+	ips, err := pool.resolver.LookupHost(ctx, host)
+	sort.Strings(ips)
+	return ips, err
+}
+
+// Init 解析多个随机子域名, 记录泛解析的ip, 解析结果全部落在其中的子域名将被过滤
+func (pool *DNSPool) Init() error {
+	if net.ParseIP(pool.domain) != nil {
+		return pkg.ErrUrlError
+	}
+	for i := 0; i < vhostProbes; i++ {
+		ips, err := pool.lookup(strings.ToLower(pkg.RandPath()[:6+i*3]) + "." + pool.domain)
+		if err != nil {
+			continue
+		}
+		for _, ip := range ips {
+			pool.wildcard[ip] = struct{}{}
+		}
+	}
+	if len(pool.wildcard) > 0 {
+		logs.Log.Importantf("[dns.wildcard] %s resolve to %d ips, matched subdomain will be filtered", pool.domain, len(pool.wildcard))
+	}
+	return nil
+}
+
+func (pool *DNSPool) isWildcard(ips []string) bool {
+	if len(pool.wildcard) == 0 {
+		return false
+	}
+	for _, ip := range ips {
+		if _, ok := pool.wildcard[ip]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func (pool *DNSPool) Run(offset, limit int) {
+	pool.Worder.Run()
+
+	var done bool
+	go func() {
+		for {
+			if done {
+				pool.wg.Wait()
+				close(pool.closeCh)
+				return
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+	}()
+
+Loop:
+	for {
+		select {
+		case w, ok := <-pool.Worder.Output:
+			if !ok {
+				done = true
+				continue
+			}
+			end := atomic.AddInt64(&pool.Statistor.End, 1)
+			if end <= int64(offset) {
+				continue
+			}
+			if end > int64(limit) {
+				done = true
+				continue
+			}
+			w = strings.Trim(strings.ToLower(w), ".")
+			if w == "" {
+				pool.Statistor.Skipped++
+				pool.Bar.Done()
+				continue
+			}
+			pool.wg.Add(1)
+			_ = pool.Pool.Invoke(&Unit{host: w + "." + pool.domain, source: parsers.WordSource, number: int(end)})
+		case <-pool.closeCh:
+			break Loop
+		case <-pool.ctx.Done():
+			break Loop
+		}
+	}
+	pool.Close()
+}
+
+func (pool *DNSPool) Close() {
+	pool.Bar.Close()
+	pool.Pool.Release()
+	pool.Statistor.EndTime = time.Now().Unix()
+}
+
+func (pool *DNSPool) Invoke(v interface{}) {
+	unit := v.(*Unit)
+	atomic.AddInt32(&pool.Statistor.ReqTotal, 1)
+	start := time.Now()
+	ips, err := pool.lookup(unit.host)
+	bl := &pkg.Baseline{
+		SprayResult: &parsers.SprayResult{
+			UrlString: unit.host,
+			Host:      unit.host,
+			Number:    unit.number,
+			Source:    unit.source,
+			Title:     strings.Join(ips, ","),
+			IsValid:   true,
+		},
+		Collected: true, // dns结果没有http响应, 不需要收集指纹
+	}
+	if err != nil {
+		bl.IsValid = false
+		bl.Reason = pkg.ErrDNSNotResolved.Error()
+		bl.ErrString = err.Error()
+	} else if pool.isWildcard(ips) {
+		bl.IsValid = false
+		bl.Reason = pkg.ErrDNSWildcard.Error()
+		pool.explain(bl, "%s all in wildcard ips", bl.Title)
+	} else if pool.DNSProbe {
+		// 解析成功的子域名继续进行http探测, 探测失败时保留dns结果
+		if probed := pool.probe(unit.host); probed != nil {
+			probed.Number, probed.Source = unit.number, unit.source
+			bl = probed
+		}
+	}
+	if len(ips) > 0 {
+		bl.Extracteds = append(bl.Extracteds, &parsers.Extracted{Name: "dns", ExtractResult: ips})
+	}
+	bl.Spended = time.Since(start).Milliseconds()
+	pool.processCh <- bl
+}
+
+// probe 依次尝试http与https
+func (pool *DNSPool) probe(host string) *pkg.Baseline {
+	for _, scheme := range []string{"http://", "https://"} {
+		req, err := ihttp.BuildRequest(pool.ctx, pool.ClientType, scheme+host, "", "", "GET")
+		if err != nil {
+			return nil
+		}
+		req.SetHeaders(pool.Headers)
+		resp, err := pool.client.Do(req)
+		if err != nil {
+			continue
+		}
+		bl := pkg.NewBaseline(req.URI(), req.Host(), resp)
+		bl.Collect()
+		return bl
+	}
+	return nil
+}
+
+func (pool *DNSPool) Handler() {
+	for bl := range pool.processCh {
+		if bl.IsValid && pool.MatchExpr != nil {
+			bl.IsValid, _ = pool.MatchExpr.Eval(pkg.NewExprEnv(bl, nil, nil))
+		}
+		if bl.IsValid {
+			atomic.AddInt64(&pool.Statistor.FoundNumber, 1)
+		}
+		pool.Statistor.Counts[bl.Status]++
+		pool.Bar.Done()
+		pool.putToOutput(bl)
+		pool.wg.Done()
+	}
+}
@@ -0,0 +1,35 @@
+package pool
+
+import (
+	"github.com/chainreactors/logs"
+	"github.com/chainreactors/parsers"
+	"github.com/chainreactors/spray/pkg"
+	"github.com/chainreactors/utils/encode"
+)
+
+const faviconPath = "/favicon.ico"
+
+// fetchFavicon --favicon, 每个目标请求一次/favicon.ico, 计算mmh3与md5并记录在stat中, 用于shodan/fofa关联
+func (pool *BrutePool) fetchFavicon() {
+	if !pool.Favicon || pool.Mod == ParamSpray {
+		return
+	}
+	bl := pool.fetchPath(faviconPath)
+	if bl == nil || bl.Status != 200 || len(bl.Body) == 0 || bl.ContentType == "html" {
+		logs.Log.Logf(pkg.LogVerbose, "[favicon] %s not found", pool.BaseURL)
+		return
+	}
+
+	pool.Statistor.FaviconMmh3 = encode.Mmh3Hash32(bl.Body)
+	pool.Statistor.FaviconMd5 = encode.Md5Hash(bl.Body)
+	bl.Source = parsers.FingerSource
+	bl.Collect()
+	bl.Extracteds = append(bl.Extracteds, &parsers.Extracted{
+		Name:          "favicon",
+		ExtractResult: []string{"mmh3:" + pool.Statistor.FaviconMmh3 + " md5:" + pool.Statistor.FaviconMd5},
+	})
+	pool.Statistor.AddFrameworks(bl.Frameworks)
+	logs.Log.Importantf("[favicon] %s mmh3: %s, md5: %s, shodan: http.favicon.hash:%s, fofa: icon_hash=\"%s\"",
+		bl.UrlString, pool.Statistor.FaviconMmh3, pool.Statistor.FaviconMd5, pool.Statistor.FaviconMmh3, pool.Statistor.FaviconMmh3)
+	pool.putToOutput(bl)
+}
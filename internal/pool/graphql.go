@@ -0,0 +1,114 @@
+package pool
+
+import (
+	"github.com/chainreactors/logs"
+	"github.com/chainreactors/parsers"
+	"github.com/chainreactors/spray/internal/ihttp"
+	"github.com/chainreactors/spray/pkg"
+	"github.com/valyala/fasthttp"
+	"strings"
+	"sync/atomic"
+)
+
+var (
+	GraphQLPaths   = []string{"graphql", "api/graphql", "v1/graphql", "v2/graphql", "graphql/v1", "graphiql", "query", "gql", "playground"}
+	graphqlKeyword = []string{"graphql", "graphiql", "gql"}
+	graphqlQuery   = []byte(`{"query":"query{__schema{queryType{name}}}"}`)
+)
+
+const (
+	GraphQLIntrospection = "introspection enabled"
+	GraphQLAuthRequired  = "auth required"
+	GraphQLDisabled      = "introspection disabled"
+)
+
+func isGraphQLPath(p string) bool {
+	p = strings.ToLower(p)
+	for _, k := range graphqlKeyword {
+		if strings.Contains(p, k) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClassifyGraphQL 根据introspection查询的响应判断graphql端点的状态, 不是graphql端点时返回空
+func ClassifyGraphQL(bl *pkg.Baseline) string {
+	body := strings.ToLower(string(bl.Body))
+	switch {
+	case strings.Contains(body, "__schema") || strings.Contains(body, "querytype"):
+		return GraphQLIntrospection
+	case bl.Status == 401 || bl.Status == 403:
+		return GraphQLAuthRequired
+	case strings.Contains(body, "introspection"):
+		return GraphQLDisabled
+	case strings.Contains(body, `"errors"`) && (strings.Contains(body, "graphql") || strings.Contains(body, "query")):
+		return GraphQLDisabled
+	default:
+		return ""
+	}
+}
+
+// 对疑似graphql的有效路径, 发送introspection查询进行确认
+func (pool *BrutePool) doGraphQL(bl *pkg.Baseline) {
+	if pool.Mod != PathSpray || !isGraphQLPath(bl.Path) {
+		return
+	}
+	pool.wg.Add(1)
+	go pool.probeGraphQL(bl.Path, bl.Number)
+}
+
+// 开启--graphql时主动探测常见的graphql路径
+func (pool *BrutePool) doGraphQLPaths() {
+	defer pool.wg.Done()
+	if pool.Mod == HostSpray {
+		return
+	}
+	for _, p := range GraphQLPaths {
+		pool.wg.Add(1)
+		pool.probeGraphQL(pool.dir+p, 0)
+	}
+}
+
+func (pool *BrutePool) probeGraphQL(p string, parent int) {
+	defer pool.wg.Done()
+	if pool.urls.TestAndAdd("POST " + p + "|graphql") {
+		return
+	}
+	pool.limiter.Wait(pool.ctx)
+	atomic.AddInt32(&pool.Statistor.ReqTotal, 1)
+
+	req, err := ihttp.BuildRequest(pool.ctx, pool.ClientType, pool.base, p, "", "POST")
+	if err != nil {
+		logs.Log.Error(err.Error())
+		return
+	}
+	req.SetHeaders(pool.Headers)
+	req.SetHeader("Content-Type", "application/json")
+	req.SetBody(graphqlQuery)
+	resp, reqerr := pool.do(req)
+	if pool.ClientType == ihttp.FAST {
+		defer fasthttp.ReleaseResponse(resp.FastResponse)
+		defer fasthttp.ReleaseRequest(req.FastRequest)
+	}
+	if reqerr != nil {
+		logs.Log.Debugf("[graphql] %s, %s", p, reqerr.Error())
+		return
+	}
+
+	bl := pkg.NewBaseline(req.URI(), req.Host(), resp)
+	bl.Source = parsers.FingerSource
+	bl.Parent = parent
+	bl.Path = p
+	class := ClassifyGraphQL(bl)
+	if class == "" {
+		logs.Log.Debugf("[graphql] %s not graphql endpoint", bl.UrlString)
+		return
+	}
+	bl.Collect()
+	bl.Extracteds = append(bl.Extracteds, &parsers.Extracted{
+		Name:          "graphql",
+		ExtractResult: []string{class},
+	})
+	pool.putToOutput(bl)
+}
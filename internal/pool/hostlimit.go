@@ -0,0 +1,66 @@
+package pool
+
+import (
+	"net/url"
+	"sync"
+
+	"github.com/chainreactors/spray/internal/ihttp"
+	"golang.org/x/time/rate"
+)
+
+// hostSemaphores 同一origin的所有pool(包括递归与不同路径产生的pool)共享同一个并发上限
+var hostSemaphores sync.Map
+
+// hostLimiters 同一host的所有pool共享同一个限速器
+var hostLimiters sync.Map
+
+func hostLimiter(host string, limit int) *rate.Limiter {
+	if limit <= 0 {
+		return nil
+	}
+	l, _ := hostLimiters.LoadOrStore(host, rate.NewLimiter(rate.Limit(limit), 1))
+	return l.(*rate.Limiter)
+}
+
+func hostSemaphore(host string, n int) chan struct{} {
+	if n <= 0 {
+		return nil
+	}
+	sem, _ := hostSemaphores.LoadOrStore(host, make(chan struct{}, n))
+	return sem.(chan struct{})
+}
+
+// do 发送请求, 设置了 --host-concurrency 时会等待该origin的并发槽位, 设置了 --cache 时重复的请求直接返回缓存
+// --rate 与 --rate-per-host 在真正发送前等待, 缓存命中的请求不占用配额, --cookie-jar 记录的cookie在这里带上
+func (pool *BasePool) do(req *ihttp.Request) (*ihttp.Response, error) {
+	pool.jar.Apply(req)
+	var key string
+	if pool.cache != nil {
+		if key = req.CacheKey(); key != "" {
+			if resp, ok := pool.cache.Get(key); ok {
+				return resp, nil
+			}
+		}
+	}
+	if pool.GlobalLimiter != nil {
+		if err := pool.GlobalLimiter.Wait(pool.ctx); err != nil {
+			return ihttp.NewEmptyResponse(req.ClientType), err
+		}
+	}
+	if pool.RatePerHost > 0 {
+		if u, err := url.Parse(req.URI()); err == nil {
+			if err := hostLimiter(u.Host, pool.RatePerHost).Wait(pool.ctx); err != nil {
+				return ihttp.NewEmptyResponse(req.ClientType), err
+			}
+		}
+	}
+	if pool.hostSem != nil {
+		pool.hostSem <- struct{}{}
+		defer func() { <-pool.hostSem }()
+	}
+	resp, err := pool.client.Do(req)
+	if err == nil && key != "" {
+		pool.cache.Put(key, resp)
+	}
+	return resp, err
+}
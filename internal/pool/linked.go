@@ -0,0 +1,103 @@
+package pool
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/chainreactors/logs"
+	"github.com/chainreactors/parsers"
+	"github.com/chainreactors/spray/internal/ihttp"
+	"github.com/chainreactors/spray/pkg"
+	"github.com/valyala/fasthttp"
+)
+
+var (
+	LightCrawlDepth = 2
+	LightCrawlLimit = 100
+)
+
+// initLinked 对目标进行一次轻量爬虫, 记录公开链接可达的路径. 已经保存过的目标直接复用
+func (pool *BrutePool) initLinked() {
+	if !pool.HiddenOnly {
+		return
+	}
+	if pool.Linked != nil {
+		if set, ok := pool.Linked.Get(pool.BaseURL); ok {
+			pool.linked = set
+			logs.Log.Logf(pkg.LogVerbose, "[linked] %s reuse %d linked path", pool.BaseURL, len(set))
+			return
+		}
+	}
+
+	pool.linked = map[string]struct{}{pool.index.Path: {}}
+	queue := []*pkg.Baseline{pool.index}
+	var fetched int
+	for depth := 0; depth < LightCrawlDepth && len(queue) > 0; depth++ {
+		var next []*pkg.Baseline
+		for _, bl := range queue {
+			bl.CollectURL()
+			for _, u := range bl.URLs {
+				if strings.HasPrefix(u, "http") || strings.HasPrefix(u, "//") {
+					if parsed, err := url.Parse(u); err != nil || parsed.Host != pool.url.Host {
+						continue
+					}
+				}
+				p := pkg.FormatURL(bl.Path, u)
+				if p == "" {
+					continue
+				}
+				if _, ok := pool.linked[p]; ok {
+					continue
+				}
+				pool.linked[p] = struct{}{}
+				if fetched >= LightCrawlLimit {
+					continue
+				}
+				fetched++
+				if child := pool.fetchPath(p); child != nil {
+					next = append(next, child)
+				}
+			}
+		}
+		queue = next
+	}
+	logs.Log.Logf(pkg.LogVerbose, "[linked] %s found %d linked path by light crawl", pool.BaseURL, len(pool.linked))
+	if pool.Linked != nil {
+		pool.Linked.Set(pool.BaseURL, pool.linked)
+	}
+}
+
+// fetchPath 在初始化阶段同步请求单个路径, 不经过reqPool与结果处理
+func (pool *BrutePool) fetchPath(p string) *pkg.Baseline {
+	return pool.fetchPathWith(p, pool.Method)
+}
+
+func (pool *BrutePool) fetchPathWith(p, method string) *pkg.Baseline {
+	pool.limiter.Wait(pool.ctx)
+	req, err := ihttp.BuildRequest(pool.ctx, pool.ClientType, pool.base, p, "", method)
+	if err != nil {
+		return nil
+	}
+	req.SetHeaders(pool.Headers)
+	resp, err := pool.do(req)
+	if pool.ClientType == ihttp.FAST {
+		defer fasthttp.ReleaseResponse(resp.FastResponse)
+		defer fasthttp.ReleaseRequest(req.FastRequest)
+	}
+	if err != nil {
+		return nil
+	}
+	return pkg.NewBaseline(req.URI(), req.Host(), resp)
+}
+
+// isLinked 判断结果是否可以通过爬虫到达, 爬虫来源的结果总是可达的
+func (pool *BrutePool) isLinked(bl *pkg.Baseline) bool {
+	if !pool.HiddenOnly {
+		return false
+	}
+	if bl.Source == parsers.CrawlSource {
+		return true
+	}
+	_, ok := pool.linked[bl.Path]
+	return ok
+}
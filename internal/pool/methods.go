@@ -0,0 +1,105 @@
+package pool
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/chainreactors/logs"
+	"github.com/chainreactors/parsers"
+	"github.com/chainreactors/spray/pkg"
+)
+
+// ParseMethods 解析逗号分隔的方法列表, e.g.: GET,post, PUT => GET, POST, PUT
+func ParseMethods(s string) []string {
+	var methods []string
+	for _, m := range strings.Split(s, ",") {
+		if m = strings.ToUpper(strings.TrimSpace(m)); m != "" {
+			methods = append(methods, m)
+		}
+	}
+	return methods
+}
+
+// doMethods --methods, 每个word额外使用其他方法请求, 发现只响应非GET方法的接口
+// OPTIONS的响应中如果有Allow头, 则继续尝试其中允许的方法
+func (pool *BrutePool) doMethods(unit *Unit, allow string) {
+	if len(pool.Methods) == 0 || pool.Mod != PathSpray || pool.RequestTemplate != nil {
+		return
+	}
+	var methods []string
+	if unit.source == parsers.WordSource {
+		methods = pool.Methods
+	} else if unit.source == pkg.MethodSource && unit.method == http.MethodOptions && allow != "" {
+		methods = ParseMethods(allow)
+	} else {
+		return
+	}
+
+	pool.wg.Add(1)
+	go func() {
+		defer pool.wg.Done()
+		for _, m := range methods {
+			if m == pool.Method || m == http.MethodHead || m == unit.method {
+				continue
+			}
+			pool.addAddition(&Unit{
+				path:   unit.path,
+				host:   unit.host,
+				method: m,
+				parent: unit.number,
+				source: pkg.MethodSource,
+				from:   unit.source,
+				depth:  unit.depth,
+			})
+		}
+	}()
+}
+
+// prepareMethodRandom 在请求的goroutine中使用方法变体的方法请求一次随机路径, 作为该方法的random baseline,
+// 很多服务对任意路径的PUT, DELETE等请求统一返回405或401, 不与同一方法的随机路径对比会产生大量误报
+func (pool *BrutePool) prepareMethodRandom(bl *pkg.Baseline) {
+	if !bl.IsValid || bl.Method == "" {
+		return
+	}
+	pool.dirLocker.Lock()
+	if pool.methodBaselines == nil {
+		pool.methodBaselines = make(map[string]*dirBaseline)
+	}
+	if d, ok := pool.methodBaselines[bl.Method]; ok {
+		pool.dirLocker.Unlock()
+		<-d.ready
+		return
+	}
+	d := &dirBaseline{ready: make(chan struct{})}
+	pool.methodBaselines[bl.Method] = d
+	pool.dirLocker.Unlock()
+
+	random := pool.fetchPathWith(pkg.SafePath(pool.dir, pkg.RandPath()), bl.Method)
+	if random != nil && random.IsValid {
+		random.IsBaseline = true
+		random.Method = bl.Method
+		random.Collect()
+		logs.Log.Logf(pkg.LogVerbose, "[baseline.%s] %s", bl.Method, random.Format([]string{"status", "length", "spend", "title", "frame", "redirect"}))
+		d.random = random
+	}
+	close(d.ready)
+}
+
+// methodRandom 方法变体对应方法的random baseline, 非方法变体或请求失败时返回nil
+func (pool *BrutePool) methodRandom(bl *pkg.Baseline) *pkg.Baseline {
+	if bl.Source != pkg.MethodSource {
+		return nil
+	}
+	pool.dirLocker.Lock()
+	d, ok := pool.methodBaselines[bl.Method]
+	pool.dirLocker.Unlock()
+	if !ok {
+		return nil
+	}
+	select {
+	case <-d.ready:
+		return d.random
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,70 @@
+package pool
+
+import (
+	"bytes"
+	"net/http"
+	"net/url"
+
+	"github.com/chainreactors/parsers"
+	"github.com/chainreactors/spray/internal/ihttp"
+	"github.com/chainreactors/spray/pkg"
+)
+
+// paramInBody 非GET/HEAD请求时参数放在表单body中
+func (pool *BrutePool) paramInBody() bool {
+	return pool.Method != http.MethodGet && pool.Method != http.MethodHead
+}
+
+// paramUnit -m param, word作为参数名, 值为pool内固定的随机字符串, 用于判断参数是否被反射
+// GET请求拼接到原有的query之后, 其他方法追加到表单body中, 路径保持不变
+func (pool *BrutePool) paramUnit(name string, source parsers.SpraySource) *Unit {
+	p := pool.url.Path
+	if p == "" {
+		p = "/"
+	}
+	query := pool.url.RawQuery
+	if name != "" && !pool.paramInBody() {
+		if query != "" {
+			query += "&"
+		}
+		query += url.QueryEscape(name) + "=" + pool.paramValue
+	}
+	if query != "" {
+		p += "?" + query
+	}
+	u := &Unit{path: p, source: source}
+	if pool.paramInBody() {
+		u.param = name
+	}
+	return u
+}
+
+// setParam 将参数写入表单body, 保留--data中原有的内容
+func (pool *BrutePool) setParam(req *ihttp.Request, name string) {
+	if name == "" {
+		return
+	}
+	body := url.QueryEscape(name) + "=" + pool.paramValue
+	if len(pool.Body) != 0 {
+		body = string(pool.Body) + "&" + body
+	}
+	req.SetBody([]byte(body))
+	if req.GetHeader("Content-Type") == "" {
+		req.SetHeader("Content-Type", "application/x-www-form-urlencoded")
+	}
+}
+
+// paramCompare 参数值被反射, 或响应与随机参数名的baseline不同时, 认为发现了有效参数
+// 如果随机参数名的响应同样反射了参数值, 说明服务端会回显所有参数, 只根据响应差异判断
+func (pool *BrutePool) paramCompare(bl *pkg.Baseline) bool {
+	value := []byte(pool.paramValue)
+	if bytes.Contains(bl.Body, value) && (pool.random == nil || !bytes.Contains(pool.random.Body, value)) {
+		bl.Extracteds = append(bl.Extracteds, &parsers.Extracted{
+			Name:          "reflected",
+			ExtractResult: []string{pool.paramValue},
+		})
+		pool.explain(bl, "value %s reflected", pool.paramValue)
+		return true
+	}
+	return pool.BaseCompare(bl)
+}
@@ -0,0 +1,133 @@
+package pool
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/chainreactors/spray/pkg"
+)
+
+// Pauser 键盘控制的暂停, 所有pool共享, 暂停时请求在发送之前等待恢复
+type Pauser struct {
+	paused atomic.Bool
+	locker sync.Mutex
+	pause  chan struct{} // 未暂停时有效, 暂停时关闭
+	resume chan struct{} // 暂停时有效, 恢复时关闭
+}
+
+func NewPauser() *Pauser {
+	return &Pauser{pause: make(chan struct{})}
+}
+
+// Toggle 切换暂停状态, 返回切换后是否处于暂停
+func (p *Pauser) Toggle() bool {
+	p.locker.Lock()
+	defer p.locker.Unlock()
+	if p.paused.Load() {
+		p.paused.Store(false)
+		p.pause = make(chan struct{})
+		close(p.resume)
+		return false
+	}
+	p.resume = make(chan struct{})
+	p.paused.Store(true)
+	close(p.pause)
+	return true
+}
+
+// state 返回当前是否暂停, 以及状态切换时关闭的channel
+func (p *Pauser) state() (bool, chan struct{}) {
+	p.locker.Lock()
+	defer p.locker.Unlock()
+	if p.paused.Load() {
+		return true, p.resume
+	}
+	return false, p.pause
+}
+
+func (p *Pauser) Paused() bool {
+	return p != nil && p.paused.Load()
+}
+
+// Wait 暂停时阻塞到恢复或ctx结束
+func (p *Pauser) Wait(ctx context.Context) {
+	if !p.Paused() {
+		return
+	}
+	p.locker.Lock()
+	ch := p.resume
+	p.locker.Unlock()
+	select {
+	case <-ch:
+	case <-ctx.Done():
+	}
+}
+
+// Tune 运行中调整线程数
+func (pool *BrutePool) Tune(thread int) {
+	if thread > 0 && thread != pool.reqPool.Cap() {
+		pool.reqPool.Tune(thread)
+		pool.scopePool.Tune(thread)
+		pool.scaler.resize(thread)
+	}
+}
+
+// Skip 跳过当前目标, 与超时相同记录已经完成的位置, 可以通过--resume继续
+func (pool *BrutePool) Skip() {
+	pool.Statistor.Error = pkg.ErrUserSkipped.Error()
+	pool.Cancel()
+}
+
+// pausableCtx 暂停期间不计时的超时, 超时后Err与context.WithTimeout一样返回DeadlineExceeded
+type pausableCtx struct {
+	context.Context
+	expired atomic.Bool
+}
+
+func (c *pausableCtx) Err() error {
+	if c.expired.Load() {
+		return context.DeadlineExceeded
+	}
+	return c.Context.Err()
+}
+
+// WithTimeout 与context.WithTimeout相同, 但暂停期间不计时, 用于--deadline与--max-time, 避免暂停恢复后立即超时
+func (p *Pauser) WithTimeout(parent context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if p == nil {
+		return context.WithTimeout(parent, timeout)
+	}
+	// 派生的context通过context.Cause可以区分超时与主动取消
+	ctx, cancel := context.WithCancelCause(parent)
+	c := &pausableCtx{Context: ctx}
+	go func() {
+		remain := timeout
+		for {
+			paused, ch := p.state()
+			if paused {
+				select {
+				case <-ch:
+					continue
+				case <-ctx.Done():
+					return
+				}
+			}
+			start := time.Now()
+			timer := time.NewTimer(remain)
+			select {
+			case <-timer.C:
+				c.expired.Store(true)
+				cancel(context.DeadlineExceeded)
+				return
+			case <-ch:
+				timer.Stop()
+				remain -= time.Since(start)
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			}
+		}
+	}()
+	return c, func() { cancel(nil) }
+}
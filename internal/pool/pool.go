@@ -1,11 +1,14 @@
 package pool
 
 import (
+	"bytes"
 	"context"
+	"fmt"
 	"github.com/chainreactors/parsers"
 	"github.com/chainreactors/spray/internal/ihttp"
 	"github.com/chainreactors/spray/pkg"
 	"github.com/chainreactors/words"
+	"strings"
 	"sync"
 	"sync/atomic"
 )
@@ -26,6 +29,42 @@ type BasePool struct {
 	closeCh     chan struct{}
 	wg          *sync.WaitGroup
 	isFallback  atomic.Bool
+	hostSem     chan struct{}  // 单个origin的并发上限, 与线程数无关
+	cache       *responseCache // 同一origin共享的响应缓存
+	jar         *cookieJar     // 同一origin共享的cookie
+	agent       string         // --agent-rotate target时整个pool使用的user-agent
+	spill       *spillQueue    // 待处理unit过多时落盘
+	spillExit   chan struct{}  // pumpSpill退出后关闭, 之后才能关闭additionCh
+	outSpill    *spillQueue    // 等待输出的结果过多时落盘, 关闭后仍会全部输出
+	replay      *ihttp.Client  // --replay-proxy转发有效结果
+}
+
+// explain --explain开启时, 在reason之后追加具体的过滤原因, 便于调整过滤规则
+func (pool *BasePool) explain(bl *pkg.Baseline, format string, a ...interface{}) {
+	if pool.Explain {
+		bl.Reason += ", " + fmt.Sprintf(format, a...)
+	}
+}
+
+// userAgent 按--agent-rotate返回随机user-agent, target模式下同一个pool固定使用一个
+func (pool *BasePool) userAgent() string {
+	if pool.AgentRotate == "target" && pool.agent != "" {
+		return pool.agent
+	}
+	return pkg.RandomUA()
+}
+
+// setBody 设置了--data时写入请求体, 其中的{word}替换为当前word
+func (pool *BasePool) setBody(req *ihttp.Request, path string) {
+	if len(pool.Body) == 0 {
+		return
+	}
+	req.SetBody(bytes.ReplaceAll(pool.Body, []byte("{word}"), []byte(pool.relaWord(path))))
+}
+
+// relaWord 当前路径相对于pool目录的部分, 即请求对应的word
+func (pool *BasePool) relaWord(path string) string {
+	return strings.TrimPrefix(strings.TrimPrefix(path, pool.dir), "/")
 }
 
 func (pool *BasePool) doRetry(bl *pkg.Baseline) {
@@ -49,6 +88,13 @@ func (pool *BasePool) doRetry(bl *pkg.Baseline) {
 func (pool *BasePool) addAddition(u *Unit) {
 	// 强行屏蔽报错, 防止goroutine泄露
 	pool.wg.Add(1)
+	if pool.spill != nil {
+		if !pool.spill.Push(u) {
+			// pool已经关闭, 丢弃
+			pool.wg.Done()
+		}
+		return
+	}
 	defer func() {
 		if err := recover(); err != nil {
 		}
@@ -60,7 +106,11 @@ func (pool *BasePool) putToOutput(bl *pkg.Baseline) {
 	if bl.IsValid || bl.IsFuzzy {
 		bl.Collect()
 	}
+	pool.doReplay(bl)
 	pool.Outwg.Add(1)
+	if pool.outSpill != nil && pool.outSpill.Push(bl) {
+		return
+	}
 	pool.OutputCh <- bl
 }
 
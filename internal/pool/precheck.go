@@ -0,0 +1,62 @@
+package pool
+
+import (
+	"fmt"
+
+	"github.com/chainreactors/logs"
+	"github.com/chainreactors/spray/pkg"
+	"github.com/expr-lang/expr"
+)
+
+const (
+	PrecheckProceed   = "proceed"
+	PrecheckSkip      = "skip"
+	PrecheckDowngrade = "downgrade"
+)
+
+// precheck 根据--precheck表达式判断是否继续该目标, 表达式可以返回bool或proceed/skip/downgrade
+func (pool *BrutePool) precheck() error {
+	if pool.PrecheckExpr == nil {
+		return nil
+	}
+	res, err := expr.Run(pool.PrecheckExpr, pkg.NewExprEnv(pool.index, pool.index, pool.random))
+	if err != nil {
+		logs.Log.Warn(err.Error())
+		return nil
+	}
+
+	action := PrecheckProceed
+	switch v := res.(type) {
+	case bool:
+		if !v {
+			action = PrecheckSkip
+		}
+	case string:
+		action = v
+	}
+
+	switch action {
+	case PrecheckSkip:
+		pool.Statistor.Precheck = PrecheckSkip
+		return fmt.Errorf("%w, %s", pkg.ErrPrecheckSkip, pool.index.Format([]string{"status", "length", "title"}))
+	case PrecheckDowngrade:
+		// 降级为只进行字典爆破, 关闭插件与递归
+		logs.Log.Importantf("[precheck] %s downgrade, disable plugins and recursion", pool.BaseURL)
+		pool.Statistor.Precheck = PrecheckDowngrade
+		pool.Crawl = false
+		pool.Active = false
+		pool.Bak = false
+		pool.Common = false
+		pool.Bypass = false
+		pool.GraphQL = false
+		pool.APIExpand = false
+		pool.CORS = false
+		pool.AppendRule = nil
+		pool.AppendWords = nil
+		pool.MaxRecursionDepth = 0
+	case PrecheckProceed:
+	default:
+		logs.Log.Warnf("[precheck] unknown action %s, proceed", action)
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package pool
+
+import (
+	"github.com/chainreactors/logs"
+	"github.com/chainreactors/spray/pkg"
+	"github.com/expr-lang/expr"
+)
+
+const (
+	RedirectFollow = "follow" // 在当前请求中跟随, 以最终响应作为结果
+	RedirectQueue  = "queue"  // 将Location作为新的任务加入队列
+	RedirectRecord = "record" // 只记录3xx结果
+)
+
+// redirectTarget --redirect-rule中的current, 额外提供原始的Location
+type redirectTarget struct {
+	*pkg.ExprBaseline
+	Location string
+}
+
+// redirectAction 根据--redirect-rule决定3xx响应的处理方式, 表达式可以返回bool(follow/record)或follow/queue/record
+// 未设置规则时保持原有行为, 有效的重定向加入队列
+func (pool *BrutePool) redirectAction(bl *pkg.Baseline) string {
+	if pool.RedirectRule == nil {
+		if bl.IsValid {
+			return RedirectQueue
+		}
+		return RedirectRecord
+	}
+	index, random := pool.exprBaselines()
+	env := pkg.NewExprEnvWith(nil, index, random)
+	env["current"] = &redirectTarget{ExprBaseline: pkg.NewExprBaseline(bl), Location: bl.RedirectURL}
+	res, err := expr.Run(pool.RedirectRule, env)
+	if err != nil {
+		logs.Log.Debugf("[redirect] %s", err.Error())
+		return RedirectRecord
+	}
+
+	switch v := res.(type) {
+	case bool:
+		if v {
+			return RedirectFollow
+		}
+		return RedirectRecord
+	case string:
+		switch v {
+		case RedirectFollow, RedirectQueue, RedirectRecord:
+			return v
+		}
+		logs.Log.Warnf("[redirect] unknown action %s, record", v)
+	}
+	return RedirectRecord
+}
@@ -0,0 +1,36 @@
+package pool
+
+import (
+	"github.com/chainreactors/spray/pkg"
+	"golang.org/x/time/rate"
+)
+
+func newLimiter(limit int) *rate.Limiter {
+	if limit <= 0 {
+		return rate.NewLimiter(rate.Inf, 1)
+	}
+	return rate.NewLimiter(rate.Limit(limit), 1)
+}
+
+// Reload 运行中更新部分配置, 由SIGHUP触发, rateLimit为0表示不限速, thread为0表示不修改
+func (pool *BrutePool) Reload(rateLimit, thread int, match, filter *pkg.Matchers) {
+	pool.reloadLocker.Lock()
+	pool.MatchExpr = match
+	pool.FilterExpr = filter
+	pool.reloadLocker.Unlock()
+
+	if rateLimit > 0 {
+		pool.limiter.SetLimit(rate.Limit(rateLimit))
+	} else {
+		pool.limiter.SetLimit(rate.Inf)
+	}
+	pool.throttler.reset(pool.limiter.Limit())
+	pool.Tune(thread)
+}
+
+// exprs 获取当前的match与filter表达式, 可能被Reload并发修改
+func (pool *BrutePool) exprs() (*pkg.Matchers, *pkg.Matchers) {
+	pool.reloadLocker.RLock()
+	defer pool.reloadLocker.RUnlock()
+	return pool.MatchExpr, pool.FilterExpr
+}
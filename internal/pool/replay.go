@@ -0,0 +1,58 @@
+package pool
+
+import (
+	"github.com/chainreactors/logs"
+	"github.com/chainreactors/spray/internal/ihttp"
+	"github.com/chainreactors/spray/pkg"
+)
+
+// newReplayClient --replay-proxy使用独立的standard client, 只转发有效结果, 爆破流量不经过该代理
+func newReplayClient(config *Config) *ihttp.Client {
+	if config.ReplayProxy == "" {
+		return nil
+	}
+	return ihttp.NewClient(&ihttp.ClientConfig{
+		Thread:    1,
+		Type:      ihttp.STANDARD,
+		Timeout:   config.Timeout,
+		ProxyAddr: config.ReplayProxy,
+		Signer:    config.Signer,
+		TLS:       config.TLS,
+		Auth:      config.Auth,
+	})
+}
+
+// doReplay 将有效结果按原请求重新发送到--replay-proxy, 例如burp/zap, 结果不影响输出
+func (pool *BasePool) doReplay(bl *pkg.Baseline) {
+	if pool.replay == nil || !bl.IsValid {
+		return
+	}
+	pool.wg.Add(1)
+	go func() {
+		defer pool.wg.Done()
+		var req *ihttp.Request
+		var err error
+		if pool.RequestTemplate != nil {
+			req, err = pool.RequestTemplate.Build(pool.ctx, ihttp.STANDARD, pool.relaWord(bl.Path))
+		} else {
+			req, err = ihttp.BuildRequest(pool.ctx, ihttp.STANDARD, bl.UrlString, "", bl.Host, pool.Method)
+		}
+		if err != nil {
+			logs.Log.Debugf("[replay] %s", err.Error())
+			return
+		}
+		if pool.RequestTemplate == nil {
+			req.SetHeaders(pool.Headers)
+			pool.setBody(req, bl.Path)
+		}
+		if pool.RandomUserAgent {
+			req.SetHeader("User-Agent", pool.userAgent())
+		}
+		resp, err := pool.replay.Do(req)
+		if err != nil {
+			logs.Log.Debugf("[replay] %s failed, %s", bl.UrlString, err.Error())
+			return
+		}
+		resp.StandardResponse.Body.Close()
+	}()
+}
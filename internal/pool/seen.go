@@ -0,0 +1,29 @@
+package pool
+
+import (
+	"sync"
+
+	"github.com/chainreactors/spray/pkg"
+)
+
+// seenSet 记录已经请求过的url, 默认为精确去重, 开启 --bloom 后使用bloom filter限制内存占用
+type seenSet interface {
+	// TestAndAdd 返回key是否已经存在, 不存在时加入
+	TestAndAdd(key string) bool
+}
+
+type exactSeen struct {
+	m sync.Map
+}
+
+func (s *exactSeen) TestAndAdd(key string) bool {
+	_, ok := s.m.LoadOrStore(key, nil)
+	return ok
+}
+
+func newSeenSet(bloom int, fp float64) seenSet {
+	if bloom > 0 {
+		return pkg.NewBloomFilter(bloom, fp)
+	}
+	return &exactSeen{}
+}
@@ -0,0 +1,300 @@
+package pool
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/url"
+	"os"
+	"sync"
+
+	"github.com/chainreactors/logs"
+	"github.com/chainreactors/parsers"
+	"github.com/chainreactors/spray/pkg"
+)
+
+// spillUnit Unit落盘时的格式
+type spillUnit struct {
+	Number   int                 `json:"n,omitempty"`
+	Parent   int                 `json:"p,omitempty"`
+	Host     string              `json:"h,omitempty"`
+	Path     string              `json:"u,omitempty"`
+	Method   string              `json:"m,omitempty"`
+	Headers  map[string]string   `json:"hd,omitempty"`
+	From     parsers.SpraySource `json:"f,omitempty"`
+	Source   parsers.SpraySource `json:"s,omitempty"`
+	Retry    int                 `json:"r,omitempty"`
+	Deferred int                 `json:"d,omitempty"`
+	FrontUrl string              `json:"fu,omitempty"`
+	Depth    int                 `json:"dp,omitempty"`
+	Bypass   string              `json:"b,omitempty"`
+	Param    string              `json:"pa,omitempty"`
+}
+
+func (u *Unit) spill() *spillUnit {
+	return &spillUnit{u.number, u.parent, u.host, u.path, u.method, u.headers, u.from, u.source, u.retry, u.deferred, u.frontUrl, u.depth, u.bypass, u.param}
+}
+
+func (s *spillUnit) unit() *Unit {
+	return &Unit{s.Number, s.Parent, s.Host, s.Path, s.Method, s.Headers, s.From, s.Source, s.Retry, s.Deferred, s.FrontUrl, s.Depth, s.Bypass, s.Param}
+}
+
+// spillBaseline 结果落盘时的格式, 保留输出与递归需要的字段, 不保留http.Response与相似度特征
+type spillBaseline struct {
+	Result             *parsers.SprayResult `json:"r"`
+	ExceedLength       bool                 `json:"el,omitempty"`
+	Dir                bool                 `json:"dir,omitempty"`
+	DirChecked         bool                 `json:"dc,omitempty"`
+	Chunked            bool                 `json:"ck,omitempty"`
+	Body               []byte               `json:"b,omitempty"`
+	Header             []byte               `json:"hd,omitempty"`
+	Raw                []byte               `json:"raw,omitempty"`
+	Recu               bool                 `json:"rc,omitempty"`
+	RecuDepth          int                  `json:"rd,omitempty"`
+	URLs               []string             `json:"urls,omitempty"`
+	Collected          bool                 `json:"cl,omitempty"`
+	Retry              int                  `json:"rt,omitempty"`
+	SameRedirectDomain bool                 `json:"srd,omitempty"`
+	IsBaseline         bool                 `json:"ib,omitempty"`
+	Protocol           string               `json:"pt,omitempty"`
+	TLSVersion         string               `json:"tv,omitempty"`
+	TLSCipher          string               `json:"tc,omitempty"`
+	CertSubject        string               `json:"cs,omitempty"`
+	CertIssuer         string               `json:"ci,omitempty"`
+	CertSANs           []string             `json:"san,omitempty"`
+	CertExpire         int64                `json:"ce,omitempty"`
+	Redirects          pkg.RedirectChain    `json:"rs,omitempty"`
+	Words              int                  `json:"w,omitempty"`
+	Lines              int                  `json:"l,omitempty"`
+	Listing            bool                 `json:"ls,omitempty"`
+	ListingEntries     []string             `json:"le,omitempty"`
+	Method             string               `json:"m,omitempty"`
+}
+
+func newSpillBaseline(bl *pkg.Baseline) *spillBaseline {
+	return &spillBaseline{
+		bl.SprayResult, bl.ExceedLength, bl.Dir, bl.DirChecked, bl.Chunked, bl.Body, bl.Header, bl.Raw, bl.Recu, bl.RecuDepth,
+		bl.URLs, bl.Collected, bl.Retry, bl.SameRedirectDomain, bl.IsBaseline, bl.Protocol, bl.TLSVersion, bl.TLSCipher,
+		bl.CertSubject, bl.CertIssuer, bl.CertSANs, bl.CertExpire, bl.Redirects, bl.Words, bl.Lines, bl.Listing, bl.ListingEntries, bl.Method,
+	}
+}
+
+func (s *spillBaseline) baseline() *pkg.Baseline {
+	bl := &pkg.Baseline{
+		SprayResult: s.Result, Dir: s.Dir, DirChecked: s.DirChecked, Chunked: s.Chunked, Body: s.Body, Header: s.Header, Raw: s.Raw,
+		Recu: s.Recu, RecuDepth: s.RecuDepth, URLs: s.URLs, Collected: s.Collected, Retry: s.Retry, SameRedirectDomain: s.SameRedirectDomain,
+		IsBaseline: s.IsBaseline, Protocol: s.Protocol, TLSVersion: s.TLSVersion, TLSCipher: s.TLSCipher, CertSubject: s.CertSubject,
+		CertIssuer: s.CertIssuer, CertSANs: s.CertSANs, CertExpire: s.CertExpire, Redirects: s.Redirects, Words: s.Words, Lines: s.Lines,
+		Listing: s.Listing, ListingEntries: s.ListingEntries, Method: s.Method,
+	}
+	if bl.SprayResult == nil {
+		bl.SprayResult = &parsers.SprayResult{}
+	}
+	bl.ExceedLength = s.ExceedLength
+	bl.Url, _ = url.Parse(bl.UrlString)
+	return bl
+}
+
+// spillCodec 落盘记录的编解码, unit与结果各自实现
+type spillCodec interface {
+	encode(v interface{}) ([]byte, error)
+	decode(line []byte) (interface{}, error)
+}
+
+type unitCodec struct{}
+
+func (unitCodec) encode(v interface{}) ([]byte, error) {
+	return json.Marshal(v.(*Unit).spill())
+}
+
+func (unitCodec) decode(line []byte) (interface{}, error) {
+	var s spillUnit
+	if err := json.Unmarshal(line, &s); err != nil {
+		return nil, err
+	}
+	return s.unit(), nil
+}
+
+type baselineCodec struct{}
+
+func (baselineCodec) encode(v interface{}) ([]byte, error) {
+	return json.Marshal(newSpillBaseline(v.(*pkg.Baseline)))
+}
+
+func (baselineCodec) decode(line []byte) (interface{}, error) {
+	var s spillBaseline
+	if err := json.Unmarshal(line, &s); err != nil {
+		return nil, err
+	}
+	return s.baseline(), nil
+}
+
+// spillQueue 递归/爬虫产生的待处理unit与等待输出的结果超过内存上限后写入临时文件, 按写入顺序回放, 保证深度扫描时内存稳定
+// drain为true时关闭后仍然回放剩余的记录, 用于不能丢弃的结果
+type spillQueue struct {
+	locker  sync.Mutex
+	cond    *sync.Cond
+	mem     []interface{}
+	limit   int
+	codec   spillCodec
+	drain   bool
+	writer  *os.File
+	file    *os.File
+	reader  *bufio.Reader
+	spilled int
+	closed  bool
+	done    chan struct{}
+}
+
+func newSpillQueue(limit int, codec spillCodec, drain bool) *spillQueue {
+	q := &spillQueue{limit: limit, codec: codec, drain: drain, done: make(chan struct{})}
+	q.cond = sync.NewCond(&q.locker)
+	return q
+}
+
+// Push 队列关闭后返回false, 由调用者处理
+func (q *spillQueue) Push(v interface{}) bool {
+	q.locker.Lock()
+	defer q.locker.Unlock()
+	if q.closed {
+		return false
+	}
+	// 一旦开始落盘, 后续的记录也写入磁盘, 保证先进先出
+	if q.spilled == 0 && len(q.mem) < q.limit {
+		q.mem = append(q.mem, v)
+	} else if err := q.write(v); err != nil {
+		logs.Log.Warnf("[spill] %s, keep in memory", err.Error())
+		q.mem = append(q.mem, v)
+	}
+	q.cond.Signal()
+	return true
+}
+
+func (q *spillQueue) write(v interface{}) error {
+	if q.writer == nil {
+		f, err := os.CreateTemp("", "spray-spill-*.jsonl")
+		if err != nil {
+			return err
+		}
+		reader, err := os.Open(f.Name())
+		if err != nil {
+			f.Close()
+			return err
+		}
+		q.writer = f
+		q.file = reader
+		q.reader = bufio.NewReader(reader)
+		logs.Log.Debugf("[spill] pending items exceed %d, spill to %s", q.limit, f.Name())
+	}
+	content, err := q.codec.encode(v)
+	if err != nil {
+		return err
+	}
+	if _, err = q.writer.Write(append(content, '\n')); err != nil {
+		return err
+	}
+	q.spilled++
+	return nil
+}
+
+// Pop 阻塞直到有新的记录, 队列关闭(drain时为关闭且回放完毕)后ok为false, 读取损坏的记录时返回nil
+func (q *spillQueue) Pop() (interface{}, bool) {
+	q.locker.Lock()
+	defer q.locker.Unlock()
+	for len(q.mem) == 0 && q.spilled == 0 && !q.closed {
+		q.cond.Wait()
+	}
+	if q.closed && (!q.drain || len(q.mem) == 0 && q.spilled == 0) {
+		q.release()
+		return nil, false
+	}
+	if len(q.mem) > 0 {
+		v := q.mem[0]
+		q.mem[0] = nil
+		q.mem = q.mem[1:]
+		return v, true
+	}
+
+	line, err := q.reader.ReadBytes('\n')
+	q.spilled--
+	if err != nil && err != io.EOF {
+		logs.Log.Warnf("[spill] %s", err.Error())
+		return nil, true
+	}
+	v, err := q.codec.decode(line)
+	if err != nil {
+		logs.Log.Warnf("[spill] %s", err.Error())
+		return nil, true
+	}
+	return v, true
+}
+
+// Done 队列关闭时关闭, 消费者阻塞在发送时用于退出
+func (q *spillQueue) Done() <-chan struct{} {
+	return q.done
+}
+
+func (q *spillQueue) Close() {
+	q.locker.Lock()
+	defer q.locker.Unlock()
+	if q.closed {
+		return
+	}
+	q.closed = true
+	close(q.done)
+	q.cond.Broadcast()
+	if !q.drain {
+		q.release()
+	}
+}
+
+// release 删除临时文件, 需要持有locker
+func (q *spillQueue) release() {
+	if q.writer != nil {
+		q.writer.Close()
+		q.file.Close()
+		os.Remove(q.writer.Name())
+		q.writer = nil
+	}
+	q.mem = nil
+	q.spilled = 0
+}
+
+// pumpSpill 将spill队列中的unit按顺序送入additionCh, pool关闭后丢弃剩余的unit
+func (pool *BasePool) pumpSpill() {
+	defer close(pool.spillExit)
+	for {
+		v, ok := pool.spill.Pop()
+		if !ok {
+			return
+		}
+		u, _ := v.(*Unit)
+		if u == nil {
+			// 损坏的记录, 对应的wg需要释放
+			pool.wg.Done()
+			continue
+		}
+		select {
+		case pool.additionCh <- u:
+		case <-pool.spill.Done():
+			pool.wg.Done()
+			return
+		}
+	}
+}
+
+// pumpOutput 将落盘的结果按顺序送入OutputCh, pool关闭后输出完剩余的结果再退出
+func (pool *BasePool) pumpOutput() {
+	for {
+		v, ok := pool.outSpill.Pop()
+		if !ok {
+			return
+		}
+		bl, _ := v.(*pkg.Baseline)
+		if bl == nil {
+			pool.Outwg.Done()
+			continue
+		}
+		pool.OutputCh <- bl
+	}
+}
@@ -0,0 +1,172 @@
+package pool
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/chainreactors/logs"
+	"github.com/chainreactors/spray/internal/ihttp"
+	"golang.org/x/time/rate"
+)
+
+var (
+	// ThrottleWindow 统计429/503与请求速率的窗口, 同时也是无推回后每次提速的间隔
+	ThrottleWindow = 5 * time.Second
+	// ThrottleBurst 窗口内429/503达到该数量视为服务端推回, 携带Retry-After时立即生效
+	ThrottleBurst = 3
+	// ThrottleMaxBackoff 单次暂停的最长时间
+	ThrottleMaxBackoff = 60 * time.Second
+)
+
+// throttler --auto-throttle, 在服务端返回429/503时暂停并降低速率, 持续无推回后逐步恢复到原始速率
+type throttler struct {
+	locker     sync.Mutex
+	limiter    *rate.Limiter
+	origin     rate.Limit // 未被降速前的速率, rate.Inf表示不限速
+	peak       rate.Limit // 原始速率不限时, 第一次推回时观测到的速率, 恢复到该速率后解除限制
+	start      time.Time
+	reqs       int
+	hits       int
+	backoff    time.Duration
+	pauseUntil time.Time
+	lastHit    time.Time
+	lastRamp   time.Time
+}
+
+func newThrottler(limiter *rate.Limiter, enable bool) *throttler {
+	if !enable {
+		return nil
+	}
+	return &throttler{
+		limiter: limiter,
+		origin:  limiter.Limit(),
+		start:   time.Now(),
+	}
+}
+
+// wait 处于暂停期时阻塞到暂停结束
+func (t *throttler) wait(ctx context.Context) {
+	if t == nil {
+		return
+	}
+	t.locker.Lock()
+	d := time.Until(t.pauseUntil)
+	t.locker.Unlock()
+	if d <= 0 {
+		return
+	}
+	select {
+	case <-time.After(d):
+	case <-ctx.Done():
+	}
+}
+
+// record 记录一次响应, 返回值用于日志: 发生降速时返回暂停时间与新的速率, 发生提速时返回0与新的速率
+func (t *throttler) record(resp *ihttp.Response) (time.Duration, rate.Limit, bool) {
+	t.locker.Lock()
+	defer t.locker.Unlock()
+	now := time.Now()
+	t.reqs++
+	if now.Sub(t.start) > ThrottleWindow {
+		t.start, t.reqs, t.hits = now, 0, 0
+	}
+
+	status := resp.StatusCode()
+	if status != http.StatusTooManyRequests && status != http.StatusServiceUnavailable {
+		return t.rampUp(now)
+	}
+	t.hits++
+	retryAfter := parseRetryAfter(resp.GetHeader("Retry-After"))
+	if retryAfter == 0 && t.hits < ThrottleBurst {
+		return 0, 0, false
+	}
+	if now.Before(t.pauseUntil) {
+		// 暂停期间已发出的请求返回的推回不再重复计算
+		return 0, 0, false
+	}
+
+	current := t.limiter.Limit()
+	if current == rate.Inf {
+		elapsed := now.Sub(t.start).Seconds()
+		current = rate.Limit(float64(t.reqs) / max(elapsed, 1))
+		if t.peak == 0 {
+			t.peak = current
+		}
+	}
+	next := max(current/2, 1)
+	t.limiter.SetLimit(next)
+
+	if retryAfter > 0 {
+		t.backoff = retryAfter
+	} else if t.backoff == 0 {
+		t.backoff = time.Second
+	} else {
+		t.backoff *= 2
+	}
+	t.backoff = min(t.backoff, ThrottleMaxBackoff)
+	t.pauseUntil = now.Add(t.backoff)
+	t.lastHit = now
+	t.hits = 0
+	return t.backoff, next, true
+}
+
+func (t *throttler) rampUp(now time.Time) (time.Duration, rate.Limit, bool) {
+	current := t.limiter.Limit()
+	if current == t.origin || now.Sub(t.lastHit) < ThrottleWindow || now.Sub(t.lastRamp) < ThrottleWindow {
+		return 0, 0, false
+	}
+	t.lastRamp = now
+	t.backoff = 0
+	next := current * 3 / 2
+	if (t.origin != rate.Inf && next >= t.origin) || (t.origin == rate.Inf && next >= t.peak) {
+		next = t.origin
+	}
+	t.limiter.SetLimit(next)
+	return 0, next, true
+}
+
+// reset 手动修改速率(reload)后, 以新的速率作为恢复目标
+func (t *throttler) reset(limit rate.Limit) {
+	if t == nil {
+		return
+	}
+	t.locker.Lock()
+	defer t.locker.Unlock()
+	t.origin = limit
+	t.peak = 0
+	t.backoff = 0
+}
+
+// parseRetryAfter 支持秒数与http date两种格式
+func parseRetryAfter(s string) time.Duration {
+	if s == "" {
+		return 0
+	}
+	if n, err := strconv.Atoi(s); err == nil && n > 0 {
+		return time.Duration(n) * time.Second
+	}
+	if t, err := http.ParseTime(s); err == nil {
+		return max(time.Until(t), 0)
+	}
+	return 0
+}
+
+func (pool *BrutePool) recordThrottle(resp *ihttp.Response) {
+	if pool.throttler == nil || resp == nil {
+		return
+	}
+	pause, next, changed := pool.throttler.record(resp)
+	if !changed {
+		return
+	}
+	if pause > 0 {
+		logs.Log.Warnf("[throttle] %s server push back, pause %s and slow down to %.1f/s", pool.BaseURL, pause, float64(next))
+	} else if next == rate.Inf {
+		logs.Log.Infof("[throttle] %s recovered, remove rate limit", pool.BaseURL)
+	} else {
+		logs.Log.Infof("[throttle] %s no push back, speed up to %.1f/s", pool.BaseURL, float64(next))
+	}
+}
@@ -3,6 +3,8 @@ package pool
 import (
 	"github.com/chainreactors/parsers"
 	"github.com/chainreactors/spray/pkg"
+	"sort"
+	"strings"
 )
 
 func newUnit(path string, source parsers.SpraySource) *Unit {
@@ -14,11 +16,39 @@ type Unit struct {
 	parent   int
 	host     string
 	path     string
+	method   string
+	headers  map[string]string
 	from     parsers.SpraySource
 	source   parsers.SpraySource
 	retry    int
+	deferred int
 	frontUrl string
 	depth    int
+	bypass   string
+	param    string // -m param下放在body中的参数名
+}
+
+// key 用于addition去重, 自定义了method或header的unit需要与原始path区分开
+func (u *Unit) key() string {
+	if u.method == "" && len(u.headers) == 0 && u.param == "" {
+		return u.path
+	}
+	var s strings.Builder
+	s.WriteString(u.method)
+	s.WriteString(" ")
+	s.WriteString(u.path)
+	if u.param != "" {
+		s.WriteString("#" + u.param)
+	}
+	keys := make([]string, 0, len(u.headers))
+	for k := range u.headers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		s.WriteString("|" + k + ":" + u.headers[k])
+	}
+	return s.String()
 }
 
 func (u *Unit) Update(bl *pkg.Baseline) {
@@ -49,9 +79,12 @@ const (
 	HostSpray
 	ParamSpray
 	CustomSpray
+	DNSSpray
 )
 
 var ModMap = map[string]SprayMod{
-	"path": PathSpray,
-	"host": HostSpray,
+	"path":  PathSpray,
+	"host":  HostSpray,
+	"param": ParamSpray,
+	"dns":   DNSSpray,
 }
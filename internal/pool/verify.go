@@ -0,0 +1,96 @@
+package pool
+
+import (
+	"sync/atomic"
+
+	"github.com/chainreactors/logs"
+	"github.com/chainreactors/spray/internal/ihttp"
+	"github.com/chainreactors/spray/pkg"
+	"github.com/valyala/fasthttp"
+)
+
+// newVerifyClient 根据--verify-with构造复核用的client, fast/standard/h3切换为另一种client, 其他值视为代理地址
+func newVerifyClient(config *Config) (*ihttp.Client, int) {
+	clientType, proxy, proxyPool := config.ClientType, config.ProxyAddr, config.ProxyPool
+	switch config.VerifyWith {
+	case "":
+		return nil, 0
+	case "fast":
+		clientType = ihttp.FAST
+	case "standard":
+		clientType = ihttp.STANDARD
+	case "h3":
+		clientType = ihttp.H3
+	default:
+		proxy, proxyPool = config.VerifyWith, nil
+	}
+	return ihttp.NewClient(&ihttp.ClientConfig{
+		Thread:    config.Thread,
+		Type:      clientType,
+		Timeout:   config.Timeout,
+		ProxyAddr: proxy,
+		Signer:    config.Signer,
+		TLS:       config.TLS,
+		ProxyPool: proxyPool,
+		HTTP2:     config.HTTP2,
+		Browser:   config.Impersonate,
+		Auth:      config.Auth,
+		Redirects: config.FollowRedirect,
+	}), clientType
+}
+
+// doVerify 使用另一个client对有效结果复核一次, 状态码不一致的结果视为client带来的误报, 复核通过后才记录为有效结果
+func (pool *BrutePool) doVerify(bl *pkg.Baseline, params map[string]interface{}) {
+	defer pool.wg.Done()
+	pool.limiter.Wait(pool.ctx)
+	atomic.AddInt32(&pool.Statistor.ReqTotal, 1)
+
+	// 使用结果实际的method复核, 例如--methods探测到的方法
+	method := bl.Method
+	if method == "" {
+		method = pool.Method
+	}
+	var req *ihttp.Request
+	var err error
+	if pool.RequestTemplate != nil {
+		req, err = pool.RequestTemplate.Build(pool.ctx, pool.verifyType, pool.relaWord(bl.Path))
+	} else {
+		req, err = ihttp.BuildRequest(pool.ctx, pool.verifyType, pool.base, bl.Path, bl.Host, method)
+	}
+	if err != nil {
+		logs.Log.Error(err.Error())
+		return
+	}
+	if pool.RequestTemplate == nil {
+		req.SetHeaders(pool.Headers)
+		pool.setBody(req, bl.Path)
+	}
+	resp, reqerr := pool.verifyClient.Do(req)
+	if pool.verifyType == ihttp.FAST {
+		defer fasthttp.ReleaseRequest(req.FastRequest)
+	}
+	if resp != nil {
+		// 复核只需要状态码, 请求失败时也可能带有需要释放的响应
+		if resp.FastResponse != nil {
+			defer fasthttp.ReleaseResponse(resp.FastResponse)
+		} else if resp.StandardResponse != nil {
+			defer resp.StandardResponse.Body.Close()
+		}
+	}
+
+	if reqerr != nil {
+		bl.IsValid = false
+		bl.Reason = pkg.ErrVerifyFailed.Error()
+		bl.ErrString = reqerr.Error()
+	} else if status := resp.StatusCode(); status != bl.Status {
+		bl.IsValid = false
+		bl.Reason = pkg.ErrVerifyFailed.Error()
+		logs.Log.Debugf("[verify] %s status %d, verify status %d", bl.UrlString, bl.Status, status)
+	}
+
+	if bl.IsValid {
+		pool.found(bl, params)
+	} else if !pool.closed {
+		pool.putToOutput(bl)
+	}
+}
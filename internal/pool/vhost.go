@@ -0,0 +1,56 @@
+package pool
+
+import (
+	"net"
+	"strings"
+
+	"github.com/chainreactors/spray/internal/ihttp"
+	"github.com/chainreactors/spray/pkg"
+	"github.com/valyala/fasthttp"
+)
+
+// vhostProbes host模式下探测泛解析使用的随机host数量, 一半为随机域名, 一半为目标域名的随机子域名
+const vhostProbes = 4
+
+// vhostWildcard -m host, 使用多个随机host请求, 学习前端对未知host的默认响应(泛解析, 默认站点)
+// 与这些响应一致的结果会被自动过滤, 避免在泛解析的反向代理上产生大量误报
+func (pool *BrutePool) vhostWildcard() {
+	if pool.Mod != HostSpray {
+		return
+	}
+	domain := pool.url.Hostname()
+	var samples []calibrateSample
+	for i := 0; i < vhostProbes; i++ {
+		// 不同长度的随机host, 避免反射host的页面因长度相同被误认为body长度一致
+		host := strings.ToLower(pkg.RandPath()[:6+i*3])
+		if i%2 == 1 && net.ParseIP(domain) == nil {
+			host += "." + domain
+		} else {
+			host += ".com"
+		}
+		if bl := pool.fetchHost(host); bl != nil && bl.IsValid {
+			bl.Host = host
+			samples = append(samples, calibrateSample{bl, host})
+		}
+	}
+	pool.learnCalibrations("vhost.wildcard", samples)
+}
+
+// fetchHost 同步请求指定host的index页面
+func (pool *BrutePool) fetchHost(host string) *pkg.Baseline {
+	pool.limiter.Wait(pool.ctx)
+	req, err := ihttp.BuildRequest(pool.ctx, pool.ClientType, pool.base, pool.url.Path, host, pool.Method)
+	if err != nil {
+		return nil
+	}
+	req.SetHeaders(pool.Headers)
+	resp, err := pool.do(req)
+	if pool.ClientType == ihttp.FAST {
+		defer fasthttp.ReleaseResponse(resp.FastResponse)
+		defer fasthttp.ReleaseRequest(req.FastRequest)
+	}
+	if err != nil {
+		return nil
+	}
+	return pkg.NewBaseline(req.URI(), req.Host(), resp)
+}
@@ -0,0 +1,79 @@
+package pool
+
+import (
+	"strings"
+
+	"github.com/chainreactors/logs"
+	"github.com/chainreactors/spray/pkg"
+	"github.com/chainreactors/utils/iutils"
+	"golang.org/x/time/rate"
+)
+
+var (
+	// WafProbes 触发waf规则的探针, 分别为sql注入, xss与路径穿越
+	WafProbes = []string{
+		"?id=1%20AND%201=1%20UNION%20SELECT%201,2,3--",
+		"?q=%3Cscript%3Ealert(1)%3C/script%3E",
+		"?file=../../../../etc/passwd",
+	}
+	// WafEvasionRate 识别到waf后的限速(rate/s)
+	WafEvasionRate = 10
+	// WafEvasionThread 识别到waf后的线程数上限
+	WafEvasionThread = 5
+)
+
+// detectWaf --waf-detect, 在爆破前发送少量探针, 根据index与探针响应的特征识别waf/cdn, 结果记录在stat中
+// 没有命中特征但探针被拦截(waf状态码, 或index正常而探针返回403)时记录为unknown
+func (pool *BrutePool) detectWaf() {
+	if !pool.WafDetect {
+		return
+	}
+	found := pkg.DetectWaf(pool.index)
+	var blocked bool
+	for _, probe := range WafProbes {
+		bl := pool.fetchPath(pool.url.Path + probe)
+		if bl == nil {
+			continue
+		}
+		for _, name := range pkg.DetectWaf(bl) {
+			if !iutils.StringsContains(found, name) {
+				found = append(found, name)
+			}
+		}
+		if pkg.WAFStatus.Contains(bl.Status) || (bl.Status == 403 && pool.index.Status != 403) {
+			blocked = true
+		}
+	}
+	if len(found) == 0 && blocked {
+		found = append(found, "unknown")
+	}
+	if len(found) == 0 {
+		logs.Log.Logf(pkg.LogVerbose, "[waf] %s no waf/cdn detected", pool.BaseURL)
+		return
+	}
+
+	pool.Statistor.Waf = strings.Join(found, ",")
+	logs.Log.Importantf("[waf] %s detected: %s, blocked probe: %v", pool.BaseURL, pool.Statistor.Waf, blocked)
+	if pool.WafEvasion {
+		pool.wafEvasion()
+	}
+}
+
+// wafEvasion 切换到低速的规避配置: 限速, 减少线程, 随机User-Agent并开启--auto-throttle
+func (pool *BrutePool) wafEvasion() {
+	if pool.limiter.Limit() > rate.Limit(WafEvasionRate) {
+		pool.limiter.SetLimit(rate.Limit(WafEvasionRate))
+	}
+	if pool.throttler == nil {
+		pool.throttler = newThrottler(pool.limiter, true)
+	} else {
+		pool.throttler.reset(pool.limiter.Limit())
+	}
+	if pool.reqPool.Cap() > WafEvasionThread {
+		pool.reqPool.Tune(WafEvasionThread)
+		pool.scopePool.Tune(WafEvasionThread)
+		pool.scaler.resize(WafEvasionThread)
+	}
+	pool.RandomUserAgent = true
+	logs.Log.Importantf("[waf] %s switch to evasion profile, rate %d/s, %d threads, random user-agent", pool.BaseURL, WafEvasionRate, pool.reqPool.Cap())
+}
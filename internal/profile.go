@@ -0,0 +1,106 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/chainreactors/files"
+	"github.com/gookit/config/v2"
+)
+
+// ProfileDir 用户自定义profile所在的目录(相对于home), 文件名即profile名, 格式与配置文件相同
+var ProfileDir = filepath.Join(".config", "spray", "profiles")
+
+// Profiles 内置的profile, 预设线程, 并发, 速率, 超时与黑名单状态码等组合, 优先级高于配置文件, 低于命令行参数
+var Profiles = map[string]string{
+	// 低速, 尽量减少对目标的影响与触发告警
+	"quiet": `
+misc:
+  thread: 5
+  pool: 1
+  timeout: 10
+mode:
+  rate-limit: 10
+  rate-per-host: 5
+  auto-throttle: true
+  retry: 1
+request:
+  random-useragent: true
+`,
+	// 高并发, 短超时, 适合内网或授权的压力测试
+	"aggressive": `
+misc:
+  thread: 100
+  pool: 20
+  timeout: 3
+mode:
+  error-threshold: 50
+  check-period: 500
+  retry: 0
+`,
+	// 识别waf后切换到低速规避配置, waf拦截常见的状态码不作为有效结果
+	"waf-evasion": `
+misc:
+  thread: 5
+  pool: 2
+  timeout: 10
+mode:
+  rate-limit: 5
+  auto-throttle: true
+  waf-detect: true
+  waf-evasion: true
+  black-status: 400,403,406,410,429
+request:
+  random-useragent: true
+`,
+}
+
+var profileExts = []string{".yaml", ".yml", ".toml", ".json"}
+
+// LoadProfile 加载profile, ~/.config/spray/profiles/下的同名文件优先于内置profile
+func LoadProfile(name string, option *Option) error {
+	if home, err := os.UserHomeDir(); err == nil {
+		for _, ext := range profileExts {
+			filename := filepath.Join(home, ProfileDir, name+ext)
+			if files.IsExist(filename) {
+				return LoadConfig(filename, option)
+			}
+		}
+	}
+	content, ok := Profiles[name]
+	if !ok {
+		return fmt.Errorf("profile %s not found, available profiles: %s, or create ~/%s/%s.yaml", name, strings.Join(ProfileNames(), ", "), filepath.ToSlash(ProfileDir), name)
+	}
+	if err := config.LoadSources(config.Yaml, []byte(content)); err != nil {
+		return err
+	}
+	return config.Decode(option)
+}
+
+// ProfileNames 内置与用户自定义的profile名, 按名称排序
+func ProfileNames() []string {
+	names := make(map[string]struct{})
+	for name := range Profiles {
+		names[name] = struct{}{}
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		entries, _ := os.ReadDir(filepath.Join(home, ProfileDir))
+		for _, entry := range entries {
+			ext := filepath.Ext(entry.Name())
+			for _, e := range profileExts {
+				if !entry.IsDir() && ext == e {
+					names[strings.TrimSuffix(entry.Name(), ext)] = struct{}{}
+				}
+			}
+		}
+	}
+	var list []string
+	for name := range names {
+		list = append(list, name)
+	}
+	sort.Strings(list)
+	return list
+}
@@ -0,0 +1,82 @@
+package internal
+
+import (
+	"github.com/chainreactors/logs"
+	"github.com/chainreactors/spray/internal/pool"
+	"github.com/chainreactors/spray/pkg"
+	"github.com/gookit/config/v2"
+)
+
+// Reload 从配置文件中重新加载可以在运行中修改的配置: rate-limit, thread, match, filter 与日志等级,
+// 只有配置文件中出现的key会覆盖当前的配置, 命令行中指定的其他配置保持不变, 对正在运行的pool与之后创建的pool同时生效
+func (r *Runner) Reload(filename string) error {
+	// 启动时加载的配置文件数据仍然保存在全局实例中, 需要先清空, 否则无法区分key是否出现在本次的文件中
+	cfg := config.Default()
+	cfg.ClearData()
+	if err := cfg.LoadFiles(filename); err != nil {
+		return err
+	}
+	var opt Option
+	if err := cfg.Decode(&opt); err != nil {
+		return err
+	}
+
+	r.reloadLocker.Lock()
+	defer r.reloadLocker.Unlock()
+	match, filter, mode := r.Match, r.Filter, r.MatcherMode
+	if cfg.Exists("output.match") {
+		match = opt.Match
+	}
+	if cfg.Exists("output.filter") {
+		filter = opt.Filter
+	}
+	if cfg.Exists("output.matcher-mode") {
+		mode = opt.MatcherMode
+	}
+	matchExpr, err := pkg.CompileMatchers(match, mode)
+	if err != nil {
+		return err
+	}
+	filterExpr, err := pkg.CompileMatchers(filter, mode)
+	if err != nil {
+		return err
+	}
+
+	var threads int
+	if cfg.Exists("mode.rate-limit") {
+		r.RateLimit = opt.RateLimit
+	}
+	if cfg.Exists("misc.thread") && opt.Threads > 0 {
+		threads = opt.Threads
+		r.Threads = opt.Threads
+	}
+	r.Match, r.Filter, r.MatcherMode = match, filter, mode
+	r.MatchExpr = matchExpr
+	r.FilterExpr = filterExpr
+
+	if cfg.Exists("misc.debug") || cfg.Exists("misc.verbose") {
+		if cfg.Exists("misc.debug") {
+			r.Debug = opt.Debug
+		}
+		if cfg.Exists("misc.verbose") {
+			r.Verbose = opt.Verbose
+		}
+		if r.Debug {
+			logs.Log.SetLevel(logs.Debug)
+		} else if len(r.Verbose) > 0 {
+			logs.Log.SetLevel(pkg.LogVerbose)
+		} else {
+			logs.Log.SetLevel(logs.Warn)
+		}
+	}
+
+	var count int
+	r.activePools.Range(func(key, value any) bool {
+		value.(*pool.BrutePool).Reload(r.RateLimit, threads, matchExpr, filterExpr)
+		count++
+		return true
+	})
+	logs.Log.Importantf("[reload] %s reloaded, rate-limit: %d, thread: %d, match: %q, filter: %q, %d running pools updated",
+		filename, r.RateLimit, r.Threads, match, filter, count)
+	return nil
+}
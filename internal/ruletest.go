@@ -0,0 +1,123 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/chainreactors/logs"
+	"github.com/chainreactors/spray/pkg"
+	"github.com/chainreactors/words/rule"
+)
+
+type RuleTestOptions struct {
+	Rules      []string `short:"r" long:"rules" description:"Files, rule files to test, .gz/.zst compressed files are decompressed on the fly, e.g.: -r best64.rule"`
+	Rule       []string `long:"rule" description:"Strings, inline rule to test, e.g.: --rule 'c $1' --rule 'i2X'"`
+	RuleSyntax string   `long:"rule-syntax" default:"hashcat" choice:"spray" choice:"hashcat" description:"String, syntax of rules, same as scan"`
+	FilterRule string   `long:"filter-rule" description:"String, filter rule of spray syntax, e.g.: --filter-rule '>8 <4'"`
+	Words      []string `short:"w" long:"word" description:"Strings, sample words, e.g.: -w password -w admin"`
+	Dictionary string   `short:"d" long:"dict" description:"File, sample words file"`
+}
+
+// ruleResult 单条规则在单个word上的结果, 被拒绝或过滤时rejected为true
+type ruleResult struct {
+	rule     string
+	word     string
+	rejected bool
+	err      error
+}
+
+// ruleSet 按语法编译后的规则, 对每个word返回与规则一一对应的结果
+type ruleSet struct {
+	count int
+	apply func(word string) []ruleResult
+}
+
+func (opts *RuleTestOptions) compile() (*ruleSet, error) {
+	var content string
+	if len(opts.Rules) > 0 {
+		var err error
+		content, err = pkg.LoadRuleAndCombine(opts.Rules)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(opts.Rule) > 0 {
+		content = strings.TrimRight(content, "\n") + "\n" + strings.Join(opts.Rule, "\n")
+	}
+	if strings.TrimSpace(content) == "" {
+		return nil, errors.New("no rules, use -r or --rule")
+	}
+
+	if opts.RuleSyntax == "hashcat" {
+		if opts.FilterRule != "" {
+			return nil, fmt.Errorf("--filter-rule is not supported with hashcat rule syntax, use rejection rules instead")
+		}
+		rules, err := pkg.ParseHashcatRules(content)
+		if err != nil {
+			return nil, err
+		}
+		return &ruleSet{count: len(rules), apply: func(word string) []ruleResult {
+			res := make([]ruleResult, len(rules))
+			for i, r := range rules {
+				s, ok := r.Apply(word)
+				res[i] = ruleResult{rule: r.Raw, word: s, rejected: !ok}
+			}
+			return res
+		}}, nil
+	}
+
+	exprs := rule.Compile(content, opts.FilterRule).Expressions
+	return &ruleSet{count: len(exprs), apply: func(word string) []ruleResult {
+		res := make([]ruleResult, len(exprs))
+		for i, e := range exprs {
+			// 逐条执行, 单条规则出错时不影响其他规则的输出
+			ss, err := rule.Run(exprs[i:i+1], word)
+			res[i] = ruleResult{rule: strings.TrimSpace(e.TokenLiteral()), err: err}
+			if err == nil {
+				res[i].word = ss[0]
+				res[i].rejected = ss[0] == ""
+			}
+		}
+		return res
+	}}, nil
+}
+
+// RuleTest 输出每条规则对样例word的变换结果, 用于在扫描前检查规则文件
+func RuleTest(opts *RuleTestOptions, args []string) error {
+	rs, err := opts.compile()
+	if err != nil {
+		return err
+	}
+	ws := append(append([]string{}, opts.Words...), args...)
+	if opts.Dictionary != "" {
+		dict, err := pkg.LoadFileToSlice(opts.Dictionary)
+		if err != nil {
+			return err
+		}
+		ws = append(ws, dict...)
+	}
+	if len(ws) == 0 {
+		return errors.New("no sample words, use -w, -d or positional arguments")
+	}
+
+	var generated, rejected int
+	for _, w := range ws {
+		logs.Log.Consolef("[word] %s\n", w)
+		for _, res := range rs.apply(w) {
+			switch {
+			case res.err != nil:
+				rejected++
+				logs.Log.Consolef("  %-16s => error: %s\n", res.rule, res.err.Error())
+			case res.rejected:
+				rejected++
+				logs.Log.Consolef("  %-16s => rejected\n", res.rule)
+			default:
+				generated++
+				logs.Log.Consolef("  %-16s => %s\n", res.rule, res.word)
+			}
+		}
+	}
+	logs.Log.Importantf("%s syntax, %d rules, %d words, %d generated, %d rejected", opts.RuleSyntax, rs.count, len(ws), generated, rejected)
+	return nil
+}
@@ -2,6 +2,7 @@ package internal
 
 import (
 	"context"
+	"errors"
 	"github.com/chainreactors/files"
 	"github.com/chainreactors/logs"
 	"github.com/chainreactors/spray/internal/ihttp"
@@ -13,8 +14,10 @@ import (
 	"github.com/panjf2000/ants/v2"
 	"github.com/vbauerster/mpb/v8"
 	"github.com/vbauerster/mpb/v8/decor"
+	"golang.org/x/time/rate"
 	"strings"
 	"sync"
+	"text/template"
 	"time"
 )
 
@@ -25,72 +28,157 @@ var (
 type Runner struct {
 	*Option
 
-	taskCh        chan *Task
-	poolwg        *sync.WaitGroup
-	outwg         *sync.WaitGroup
-	outputCh      chan *pkg.Baseline
-	fuzzyCh       chan *pkg.Baseline
-	bar           *mpb.Bar
-	bruteMod      bool
-	IsCheck       bool
-	Pools         *ants.PoolWithFunc
-	PoolName      map[string]bool
-	Tasks         *TaskGenerator
-	Rules         *rule.Program
-	AppendRules   *rule.Program
-	Headers       map[string]string
-	FilterExpr    *vm.Program
-	MatchExpr     *vm.Program
-	RecursiveExpr *vm.Program
-	OutputFile    *files.File
-	//FuzzyFile     *files.File
-	DumpFile    *files.File
-	StatFile    *files.File
-	Progress    *mpb.Progress
-	Fns         []words.WordFunc
-	Count       int // tasks total number
-	Wordlist    []string
-	AppendWords []string
-	ClientType  int
-	Probes      []string
-	Total       int // wordlist total number
-	Color       bool
-	Jsonify     bool
+	taskCh          chan *Task
+	poolwg          *sync.WaitGroup
+	outwg           *sync.WaitGroup
+	outputCh        chan *pkg.Baseline
+	fuzzyCh         chan *pkg.Baseline
+	bar             *mpb.Bar
+	bruteMod        bool
+	IsCheck         bool
+	Pools           *ants.PoolWithFunc
+	PoolName        map[string]bool
+	Tasks           *TaskGenerator
+	Rules           *rule.Program
+	AppendRules     *rule.Program
+	Headers         map[string]string
+	Body            []byte
+	RequestTemplate *ihttp.RequestTemplate
+	FilterExpr      *pkg.Matchers
+	MatchExpr       *pkg.Matchers
+	RecursiveExpr   *vm.Program
+	PrecheckExpr    *vm.Program
+	RedirectExpr    *vm.Program
+	FuzzyMatchExpr  *vm.Program
+	FuzzyFilterExpr *vm.Program
+	ResponseFilter  *pkg.ResponseFilter
+	OutputFile      *files.File
+	FuzzyFile       *files.File
+	DumpFile        *files.File
+	StatFile        *files.File
+	Sinks           []Sink
+	Chunks          *ChunkManifest
+	Checkpoint      *Checkpoint
+	Linked          *pkg.LinkedStore
+	activePools     sync.Map // 正在运行的pool, 用于reload
+	reloadLocker    sync.Mutex
+	pending         sync.Map // 已经加入但尚未开始的任务, 包括递归发现的目录, 用于强制退出时保存
+	statLocker      sync.Mutex
+	recorded        map[string]struct{} // 已经保存stat的目标
+	snapshotted     bool
+	NucleiOutFile   *files.File
+	nucleiTargets   map[string]struct{}
+	nucleiLocker    sync.Mutex
+	Progress        *mpb.Progress
+	Fns             []words.WordFunc
+	Decorators      []*pkg.Decorator
+	Count           int // tasks total number
+	Wordlist        []string
+	Words           *pkg.WordGenerator // 超过pkg.LazyThreshold时不展开到Wordlist, 按需生成
+	Slots           map[string][]string
+	Payloads        *pkg.PayloadSet
+	AppendWords     []string
+	ClientType      int
+	Signer          ihttp.Signer
+	ResponseHook    *pkg.ResponseHook
+	Auth            *ihttp.HTTPAuth
+	TLS             *ihttp.TLSOptions
+	ProxyPool       *ihttp.ProxyPool
+	Limiter         *rate.Limiter // --rate, 所有pool共享的全局限速
+	Pauser          *pool.Pauser  // 键盘控制的暂停, 所有pool共享
+	Probes          []string
+	Template        *template.Template // --format指定的输出模板
+	FuzzyProbes     []string
+	Total           int // wordlist total number
+	MaxHostTime     time.Duration
+	Color           bool
+	Jsonify         bool
+	InputHash       string // 字典与规则的hash, 记录在stat中用于断点续传时检查
+	// --rule-syntax hashcat解析的规则, 在字典流进入worder之前展开, 此时Rules为空
+	HashcatRules []*pkg.HashcatRule
 }
 
 func (r *Runner) PrepareConfig() *pool.Config {
+	r.reloadLocker.Lock()
+	defer r.reloadLocker.Unlock()
 	config := &pool.Config{
-		Thread:         r.Threads,
-		Timeout:        time.Duration(r.Timeout) * time.Second,
-		RateLimit:      r.RateLimit,
-		Headers:        r.Headers,
-		Method:         r.Method,
-		Mod:            pool.ModMap[r.Mod],
-		OutputCh:       r.outputCh,
-		FuzzyCh:        r.fuzzyCh,
-		Outwg:          r.outwg,
-		Fuzzy:          r.Fuzzy,
-		CheckPeriod:    r.CheckPeriod,
-		ErrPeriod:      int32(r.ErrPeriod),
-		BreakThreshold: int32(r.BreakThreshold),
-		MatchExpr:      r.MatchExpr,
-		FilterExpr:     r.FilterExpr,
-		RecuExpr:       r.RecursiveExpr,
-		AppendRule:     r.AppendRules, // 对有效目录追加规则, 根据rule生成
-		AppendWords:    r.AppendWords, // 对有效目录追加字典
-		Fns:            r.Fns,
+		Thread:          r.Threads,
+		HostConcurrency: r.HostConcurrency,
+		ScaleErrorRate:  r.ScaleErrorRate,
+		SpillLimit:      r.SpillLimit,
+		BloomSize:       r.BloomSize,
+		BloomFP:         r.BloomFP,
+		CacheSize:       r.CacheSize,
+		Timeout:         time.Duration(r.Timeout) * time.Second,
+		RateLimit:       r.RateLimit,
+		GlobalLimiter:   r.Limiter,
+		Pauser:          r.Pauser,
+		RatePerHost:     r.RatePerHost,
+		AutoThrottle:    r.AutoThrottle,
+		MaxTime:         r.MaxHostTime,
+		Headers:         r.Headers,
+		Method:          r.Method,
+		Methods:         pool.ParseMethods(r.Methods),
+		Body:            r.Body,
+		CookieJar:       r.CookieJar,
+		RequestTemplate: r.RequestTemplate,
+		Mod:             pool.ModMap[r.Mod],
+		OutputCh:        r.outputCh,
+		FuzzyCh:         r.fuzzyCh,
+		Outwg:           r.outwg,
+		Fuzzy:           r.Fuzzy,
+		Explain:         r.Explain,
+		CheckPeriod:     r.CheckPeriod,
+		ErrPeriod:       int32(r.ErrPeriod),
+		BreakThreshold:  int32(r.BreakThreshold),
+		MatchExpr:       r.MatchExpr,
+		FilterExpr:      r.FilterExpr,
+		ResponseFilter:  r.ResponseFilter,
+		RecuExpr:        r.RecursiveExpr,
+		PrecheckExpr:    r.PrecheckExpr,
+		AppendRule:      r.AppendRules, // 对有效目录追加规则, 根据rule生成
+		AppendWords:     r.AppendWords, // 对有效目录追加字典
+		Fns:             r.WordFuncs(0),
 		//IgnoreWaf:       r.IgnoreWaf,
 		Crawl:             r.CrawlPlugin,
 		Scope:             r.Scope,
 		Active:            r.Finger,
 		Bak:               r.BakPlugin,
 		Common:            r.CommonPlugin,
+		Bypass:            r.BypassPlugin,
+		GraphQL:           r.GraphQLPlugin,
+		APIExpand:         r.APIExpandPlugin,
+		CORS:              r.CORSPlugin,
+		DirCheck:          r.DirCheckPlugin,
+		Listing:           r.ListingPlugin,
+		AutoWord:          r.AutoWordPlugin,
+		AutoWordLimit:     r.AutoWordLimit,
+		Favicon:           r.FaviconPlugin,
+		Resolvers:         r.Resolvers,
+		DNSProbe:          r.DNSProbe,
+		WafDetect:         r.WafDetect || r.WafEvasion,
+		WafEvasion:        r.WafEvasion,
+		Quick:             r.Quick,
+		HiddenOnly:        r.HiddenOnly,
+		AutoCalibrate:     r.AutoCalibrate,
+		Linked:            r.Linked,
 		RetryLimit:        r.RetryCount,
+		DeferLimit:        r.DeferRetry,
 		ClientType:        r.ClientType,
+		VerifyWith:        r.VerifyWith,
+		ReplayProxy:       r.ReplayProxy,
+		Signer:            r.Signer,
+		ResponseHook:      r.ResponseHook,
+		Auth:              r.Auth,
+		TLS:               r.TLS,
 		RandomUserAgent:   r.RandomUserAgent,
+		AgentRotate:       r.AgentRotate,
 		Random:            r.Random,
 		Index:             r.Index,
 		ProxyAddr:         r.Proxy,
+		ProxyPool:         r.ProxyPool,
+		HTTP2:             r.HTTP2,
+		Impersonate:       r.Impersonate,
 		MaxRecursionDepth: r.Depth,
 		MaxRedirect:       3,
 		MaxAppendDepth:    r.AppendDepth,
@@ -98,12 +186,19 @@ func (r *Runner) PrepareConfig() *pool.Config {
 	}
 
 	if config.ClientType == ihttp.Auto {
-		if config.Mod == pool.PathSpray {
+		if config.Mod == pool.PathSpray || config.Mod == pool.ParamSpray {
 			config.ClientType = ihttp.FAST
 		} else if config.Mod == pool.HostSpray {
 			config.ClientType = ihttp.STANDARD
 		}
 	}
+	if r.RedirectExpr != nil {
+		// 由--redirect-rule逐个响应决定是否跟随, client不自动跟随
+		config.RedirectRule = r.RedirectExpr
+		config.FollowLimit = r.MaxRedirects
+	} else if r.FollowRedirects {
+		config.FollowRedirect = r.MaxRedirects
+	}
 	return config
 }
 
@@ -111,11 +206,101 @@ func (r *Runner) AppendFunction(fn func(string) []string) {
 	r.Fns = append(r.Fns, fn)
 }
 
+// WordFuncs 返回{ext}占位符处理之后追加了decorator的变换管道, depth为当前任务的递归深度
+func (r *Runner) WordFuncs(depth int) []words.WordFunc {
+	if len(r.Decorators) == 0 {
+		return r.Fns
+	}
+	fns := make([]words.WordFunc, 0, len(r.Fns)+len(r.Decorators))
+	fns = append(fns, r.Fns...)
+	for _, d := range r.Decorators {
+		fns = append(fns, d.Func(depth))
+	}
+	return fns
+}
+
+func (r *Runner) newWorder() *words.Worder {
+	if len(r.HashcatRules) > 0 {
+		return words.NewWorderWithChan(pkg.HashcatStream(r.Words.Stream(), r.HashcatRules))
+	}
+	if r.Words.Count > pkg.LazyThreshold {
+		return words.NewWorderWithChan(r.Words.Stream())
+	}
+	return words.NewWorderWithList(r.Wordlist)
+}
+
+// wordlist 需要完整列表的场景(路径模板, 分布式分片), 超大字典仍然需要展开
+func (r *Runner) wordlist() []string {
+	if r.Words.Count > pkg.LazyThreshold {
+		return r.Words.All()
+	}
+	return r.Wordlist
+}
+
+func (r *Runner) wordOrder() pkg.WordOrder {
+	return pkg.WordOrder{UniqueWords: r.UniqueWords, Shuffle: r.Shuffle, SortPriority: r.SortPriority}
+}
+
+// orderWorder 需要去重或调整顺序时, 展开worder生成的全部word后重新构造worder, 返回新的总数, 不需要时原样返回, 总数为-1.
+// 字典数量在解析参数时已经限制在LazyThreshold以内
+func (r *Runner) orderWorder(worder *words.Worder, order pkg.WordOrder) (*words.Worder, int) {
+	if !order.Enabled() {
+		return worder, -1
+	}
+	worder.Run()
+	var ws []string
+	for w := range worder.Output {
+		if w != "" {
+			ws = append(ws, w)
+		}
+	}
+	ws = order.Apply(ws)
+	return words.NewWorderWithList(ws), len(ws)
+}
+
+// TemplateWorder 根据任务自带的路径模板生成worder, {word}插槽使用主字典, 其他插槽使用--slot指定的字典
+func (r *Runner) TemplateWorder(t *Task) (*words.Worder, int, error) {
+	ws := r.wordlist()
+	if len(r.HashcatRules) > 0 {
+		var expanded []string
+		for _, w := range ws {
+			for _, hr := range r.HashcatRules {
+				if s, ok := hr.Apply(w); ok {
+					expanded = append(expanded, s)
+				}
+			}
+		}
+		ws = expanded
+	}
+	for _, fn := range r.WordFuncs(t.depth - 1) {
+		var next []string
+		for _, w := range ws {
+			next = append(next, fn(w)...)
+		}
+		ws = next
+	}
+	// 经过变换函数后ws已经是新的切片, 可以原地调整顺序
+	dicts := map[string][]string{"word": r.wordOrder().Apply(ws)}
+	for name, slot := range r.Slots {
+		dicts[name] = slot
+	}
+
+	tpl := pkg.NewPathTemplate(strings.TrimPrefix(t.template, "/"))
+	if err := tpl.Compile(dicts); err != nil {
+		return nil, 0, err
+	}
+	return words.NewWorderWithChan(tpl.Generate()), tpl.Count(), nil
+}
+
 func (r *Runner) Prepare(ctx context.Context) error {
 	if r.bruteMod {
 		r.IsCheck = false
 	}
 	r.OutputHandler()
+	if r.RequestTemplate != nil {
+		// 命令行指定的header覆盖原始请求中的同名header
+		r.RequestTemplate.SetHeaders(r.Headers)
+	}
 	var err error
 	if r.IsCheck {
 		// 仅check, 类似httpx
@@ -138,7 +323,7 @@ func (r *Runner) Prepare(ctx context.Context) error {
 				close(ch)
 			}()
 			checkPool.Worder = words.NewWorderWithChan(ch)
-			checkPool.Worder.Fns = r.Fns
+			checkPool.Worder.Fns = r.WordFuncs(0)
 			checkPool.Bar = pkg.NewBar("check", r.Count-r.Offset, checkPool.Statistor, r.Progress)
 			checkPool.Run(ctx, r.Offset, r.Count)
 			r.poolwg.Done()
@@ -159,13 +344,36 @@ func (r *Runner) Prepare(ctx context.Context) error {
 
 		r.Pools, err = ants.NewPoolWithFunc(r.PoolSize, func(i interface{}) {
 			t := i.(*Task)
-			if t.origin != nil && t.origin.End == t.origin.Total {
-				r.saveStat(t.origin.Json())
+			defer r.pending.Delete(t)
+			if t.origin != nil && int(t.origin.End) == t.origin.Total {
+				r.saveStat(t.origin.Statistor)
+				r.Done()
+				return
+			}
+			if ctx.Err() != nil {
+				// 退出过程中才开始的任务(例如退出前发现的递归目录)不再扫描, 直接保存, 之后可以通过--resume继续
+				r.recordStat(t.Stat())
 				r.Done()
 				return
 			}
 			config := r.PrepareConfig()
 			config.BaseURL = t.baseUrl
+			config.Techs = t.techs
+			if config.Mod == pool.DNSSpray {
+				r.RunDNS(ctx, config)
+				return
+			}
+			if r.Payloads != nil && r.RequestTemplate == nil {
+				// url中带有关键字, 以url, header与body构造请求模板, pool只保留origin
+				tpl, err := ihttp.NewRequestTemplate(r.Method, t.baseUrl, r.Headers, r.Body, r.Payloads.Keywords)
+				if err != nil {
+					logs.Log.Error(err.Error())
+					r.Done()
+					return
+				}
+				config.RequestTemplate = tpl
+				config.BaseURL = tpl.BaseURL() + "/"
+			}
 
 			brutePool, err := pool.NewBrutePool(ctx, config)
 			if err != nil {
@@ -174,22 +382,50 @@ func (r *Runner) Prepare(ctx context.Context) error {
 				r.Done()
 				return
 			}
-			if t.origin != nil && len(r.Wordlist) == 0 {
+			if t.origin != nil && r.Words.Count == 0 {
 				// 如果是从断点续传中恢复的任务, 则自动设置word,dict与rule, 不过优先级低于命令行参数
 				brutePool.Statistor = pkg.NewStatistorFromStat(t.origin.Statistor)
-				brutePool.Worder, err = t.origin.InitWorder(r.Fns)
+				brutePool.Worder, err = t.origin.InitWorder(r.WordFuncs(t.depth - 1))
 				if err != nil {
 					logs.Log.Error(err.Error())
 					r.Done()
 					return
 				}
 				brutePool.Statistor.Total = t.origin.sum
+			} else if r.Payloads != nil {
+				brutePool.Statistor = pkg.NewStatistor(t.baseUrl)
+				// pool提前退出(max-time, 错误过多等)时停止生成payload
+				gctx, gcancel := context.WithCancel(ctx)
+				defer gcancel()
+				brutePool.Worder = words.NewWorderWithChan(r.Payloads.Generate(gctx))
+				brutePool.Statistor.Total = r.Payloads.Count()
+			} else if t.template != "" {
+				brutePool.Statistor = pkg.NewStatistor(t.baseUrl)
+				brutePool.Worder, brutePool.Statistor.Total, err = r.TemplateWorder(t)
+				if err != nil {
+					logs.Log.Error(err.Error())
+					r.Done()
+					return
+				}
 			} else {
 				brutePool.Statistor = pkg.NewStatistor(t.baseUrl)
-				brutePool.Worder = words.NewWorderWithList(r.Wordlist)
-				brutePool.Worder.Fns = r.Fns
+				brutePool.Worder = r.newWorder()
+				brutePool.Worder.Fns = r.WordFuncs(t.depth - 1)
 				brutePool.Worder.Rules = r.Rules.Expressions
 			}
+			if r.Payloads == nil && t.template == "" {
+				var total int
+				order := r.wordOrder()
+				if t.origin != nil && r.Words.Count == 0 {
+					// 续传时使用stat中记录的顺序
+					order = t.origin.WordOrder
+				}
+				if brutePool.Worder, total = r.orderWorder(brutePool.Worder, order); total >= 0 {
+					brutePool.Statistor.Total = total
+				}
+			}
+
+			brutePool.Statistor.Depth = t.depth - 1
 
 			var limit int
 			if brutePool.Statistor.Total > r.Limit && r.Limit != 0 {
@@ -202,22 +438,25 @@ func (r *Runner) Prepare(ctx context.Context) error {
 			err = brutePool.Init()
 			if err != nil {
 				brutePool.Statistor.Error = err.Error()
-				if !r.Force {
+				if !r.Force || errors.Is(err, pkg.ErrPrecheckSkip) {
 					// 如果没开启force, init失败将会关闭pool
 					brutePool.Close()
-					r.PrintStat(brutePool)
+					r.PrintStat(brutePool.Statistor)
 					r.Done()
 					return
 				}
 			}
 
+			r.activePools.Store(brutePool.BaseURL, brutePool)
 			brutePool.Run(brutePool.Statistor.Offset, limit)
+			r.activePools.Delete(brutePool.BaseURL)
 
 			if brutePool.IsFailed && len(brutePool.FailedBaselines) > 0 {
 				// 如果因为错误积累退出, end将指向第一个错误发生时, 防止resume时跳过大量目标
-				brutePool.Statistor.End = brutePool.FailedBaselines[0].Number
+				brutePool.Statistor.End = int64(brutePool.FailedBaselines[0].Number)
 			}
-			r.PrintStat(brutePool)
+			r.PrintStat(brutePool.Statistor)
+			r.RunNuclei(brutePool.ValidURLs)
 			r.Done()
 		})
 		r.Run(ctx)
@@ -229,7 +468,47 @@ func (r *Runner) Prepare(ctx context.Context) error {
 	return nil
 }
 
+// RunDNS -m dns, 以目标的域名作为后缀解析字典生成的子域名
+func (r *Runner) RunDNS(ctx context.Context, config *pool.Config) {
+	defer r.Done()
+	dnsPool, err := pool.NewDNSPool(ctx, config)
+	if err != nil {
+		logs.Log.Error(err.Error())
+		return
+	}
+	dnsPool.Worder = r.newWorder()
+	dnsPool.Worder.Fns = r.WordFuncs(0)
+	dnsPool.Worder.Rules = r.Rules.Expressions
+	dnsPool.Statistor.Total = r.Total
+	if worder, total := r.orderWorder(dnsPool.Worder, r.wordOrder()); total >= 0 {
+		dnsPool.Worder, dnsPool.Statistor.Total = worder, total
+	}
+
+	limit := dnsPool.Statistor.Total
+	if limit > r.Limit && r.Limit != 0 {
+		limit = r.Limit
+	}
+	dnsPool.Bar = pkg.NewBar(config.BaseURL, limit-r.Offset, dnsPool.Statistor, r.Progress)
+	logs.Log.Importantf("[pool] dns task: %s, total %d words, %d threads", config.BaseURL, limit-r.Offset, dnsPool.Thread)
+	if err := dnsPool.Init(); err != nil {
+		dnsPool.Statistor.Error = err.Error()
+		dnsPool.Cancel()
+		r.PrintStat(dnsPool.Statistor)
+		return
+	}
+	dnsPool.Run(r.Offset, limit)
+	r.PrintStat(dnsPool.Statistor)
+}
+
 func (r *Runner) Run(ctx context.Context) {
+	if r.Checkpoint != nil {
+		stop := make(chan struct{})
+		go r.runCheckpoint(stop)
+		defer func() {
+			close(stop)
+			r.saveCheckpoint()
+		}()
+	}
 Loop:
 	for {
 		select {
@@ -237,11 +516,17 @@ Loop:
 			// 如果超过了deadline, 尚未开始的任务都将被记录到stat中
 			if len(r.taskCh) > 0 {
 				for t := range r.taskCh {
-					stat := pkg.NewStatistor(t.baseUrl)
-					r.saveStat(stat.Json())
+					if r.Chunks != nil {
+						r.Chunks.Assign(t.baseUrl)
+					}
+					// 与AddPool一致, 保存的是加入后的深度
+					t.depth++
+					r.recordStat(t.Stat())
 				}
 			}
-			if r.StatFile != nil {
+			if r.Checkpoint != nil {
+				logs.Log.Importantf("already save all stat to %s", r.Checkpoint.Filename)
+			} else if r.StatFile != nil {
 				logs.Log.Importantf("already save all stat to %s", r.StatFile.Filename)
 			}
 			break Loop
@@ -249,6 +534,11 @@ Loop:
 			if !ok {
 				break Loop
 			}
+			if r.Chunks != nil {
+				if err := r.Chunks.Assign(t.baseUrl); err != nil {
+					logs.Log.Error(err.Error())
+				}
+			}
 			r.AddPool(t)
 		}
 	}
@@ -284,25 +574,47 @@ Loop:
 }
 
 func (r *Runner) AddRecursive(bl *pkg.Baseline) {
-	// 递归新任务
+	// 递归新任务, --dir-check确认为目录但不以"/"结尾的结果补全"/"
+	baseUrl := bl.UrlString
+	if bl.IsDir() && !strings.HasSuffix(baseUrl, "/") {
+		baseUrl += "/"
+	}
 	task := &Task{
-		baseUrl: bl.UrlString,
+		baseUrl: baseUrl,
 		depth:   bl.RecuDepth + 1,
-		origin:  NewOrigin(pkg.NewStatistor(bl.UrlString)),
+		origin:  NewOrigin(pkg.NewStatistor(baseUrl)),
 	}
 
-	r.AddPool(task)
+	if r.queueTask(task) {
+		// 在输出的goroutine中调用, pool已满时异步等待, 防止阻塞输出导致正在运行的pool无法结束
+		go r.invoke(task)
+	}
 }
 
 func (r *Runner) AddPool(task *Task) {
-	// 递归新任务
+	if r.queueTask(task) {
+		r.invoke(task)
+	}
+}
+
+func (r *Runner) queueTask(task *Task) bool {
 	if _, ok := r.PoolName[task.baseUrl]; ok {
 		logs.Log.Importantf("already added pool, skip %s", task.baseUrl)
-		return
+		return false
 	}
 	task.depth++
+	r.pending.Store(task, struct{}{})
 	r.poolwg.Add(1)
-	r.Pools.Invoke(task)
+	return true
+}
+
+func (r *Runner) invoke(task *Task) {
+	if err := r.Pools.Invoke(task); err != nil {
+		// pool已经关闭, 任务保存到stat中
+		r.pending.Delete(task)
+		r.recordStat(task.Stat())
+		r.Done()
+	}
 }
 
 func (r *Runner) newBar(total int) {
@@ -319,6 +631,12 @@ func (r *Runner) newBar(total int) {
 			decor.OnComplete( // 当进度完成时显示的文本
 				decor.Counters(0, "% d/% d"), " done!",
 			),
+			decor.Any(func(s decor.Statistics) string {
+				if r.Pauser.Paused() {
+					return " [paused, press space to resume]"
+				}
+				return ""
+			}),
 		),
 		mpb.AppendDecorators(
 			// 显示经过的时间
@@ -334,35 +652,95 @@ func (r *Runner) Done() {
 	r.poolwg.Done()
 }
 
-func (r *Runner) PrintStat(pool *pool.BrutePool) {
+func (r *Runner) PrintStat(stat *pkg.Statistor) {
 	if r.Color {
-		logs.Log.Important(pool.Statistor.ColorString())
-		if pool.Statistor.Error == "" {
-			logs.Log.Log(pkg.LogVerbose, pool.Statistor.ColorCountString())
-			logs.Log.Log(pkg.LogVerbose, pool.Statistor.ColorSourceString())
+		logs.Log.Important(stat.ColorString())
+		if stat.Error == "" {
+			logs.Log.Log(pkg.LogVerbose, stat.ColorCountString())
+			logs.Log.Log(pkg.LogVerbose, stat.ColorSourceString())
 		}
 	} else {
-		logs.Log.Important(pool.Statistor.String())
-		if pool.Statistor.Error == "" {
-			logs.Log.Log(pkg.LogVerbose, pool.Statistor.CountString())
-			logs.Log.Log(pkg.LogVerbose, pool.Statistor.SourceString())
+		logs.Log.Important(stat.String())
+		if stat.Error == "" {
+			logs.Log.Log(pkg.LogVerbose, stat.CountString())
+			logs.Log.Log(pkg.LogVerbose, stat.SourceString())
 		}
 	}
 
-	r.saveStat(pool.Statistor.Json())
+	r.recordStat(stat)
+}
+
+func (r *Runner) recordStat(stat *pkg.Statistor) {
+	r.statLocker.Lock()
+	defer r.statLocker.Unlock()
+	if r.snapshotted {
+		// 已经保存了退出前的快照, 之后的记录会与快照重复
+		return
+	}
+	r.writeStat(stat)
+}
+
+func (r *Runner) writeStat(stat *pkg.Statistor) {
+	if r.recorded == nil {
+		r.recorded = make(map[string]struct{})
+	}
+	r.recorded[stat.BaseUrl] = struct{}{}
+	if r.Chunks == nil || !r.Chunks.SaveStat(stat) {
+		r.saveStat(stat)
+	}
+	r.writeSinkStat(stat)
+}
+
+// Snapshot 强制退出前保存所有尚未保存的状态, 包括正在运行的pool的当前进度, 以及已经加入但尚未开始的任务(例如递归发现的目录),
+// 保证之后通过--resume继续时不会丢失任何目标
+func (r *Runner) Snapshot() {
+	stats := r.progress()
+	r.statLocker.Lock()
+	defer r.statLocker.Unlock()
+	if r.snapshotted {
+		return
+	}
+	var count int
+	for _, stat := range stats {
+		if _, ok := r.recorded[stat.BaseUrl]; ok {
+			continue
+		}
+		r.writeStat(stat)
+		count++
+	}
+	r.snapshotted = true
+	// stat文件是异步写入的, 关闭时等待写入完成
+	if r.Chunks != nil {
+		r.Chunks.Close()
+	}
+	if r.Checkpoint != nil {
+		if err := r.Checkpoint.Save(nil); err != nil {
+			logs.Log.Warnf("[checkpoint] %s", err.Error())
+		}
+		logs.Log.Importantf("save snapshot of %d running and pending tasks to %s", count, r.Checkpoint.Filename)
+	} else if r.StatFile != nil {
+		r.StatFile.Close()
+		logs.Log.Importantf("save snapshot of %d running and pending tasks to %s", count, r.StatFile.Filename)
+	}
 }
 
-func (r *Runner) saveStat(content string) {
-	if r.StatFile != nil {
-		r.StatFile.SafeWrite(content)
+func (r *Runner) saveStat(stat *pkg.Statistor) {
+	if r.Checkpoint != nil {
+		r.Checkpoint.Add(stat)
+	} else if r.StatFile != nil {
+		r.StatFile.SafeWrite(stat.Json())
 		r.StatFile.SafeSync()
 	}
 }
 
 func (r *Runner) Output(bl *pkg.Baseline) {
 	var out string
-	if r.Option.Json {
+	if r.Httpx {
+		out = bl.ToHttpxJson()
+	} else if r.Option.Json {
 		out = bl.ToJson()
+	} else if r.Template != nil {
+		out = bl.TemplateOutput(r.Template)
 	} else if len(r.Probes) > 0 {
 		out = bl.ProbeOutput(r.Probes)
 	} else if r.Color {
@@ -371,27 +749,83 @@ func (r *Runner) Output(bl *pkg.Baseline) {
 		out = bl.String()
 	}
 
-	if bl.IsValid {
+	if bl.IsValid || (r.Fuzzy && bl.IsFuzzy && r.Option.Json) {
+		// json行通过fuzzy字段区分, 不添加前缀
 		logs.Log.Console(out + "\n")
 	} else if r.Fuzzy && bl.IsFuzzy {
 		logs.Log.Console("[fuzzy] " + out + "\n")
 	}
 
 	if r.OutputFile != nil {
-		if r.FileOutput == "json" {
-			r.OutputFile.SafeWrite(bl.ToJson() + "\n")
-		} else if r.FileOutput == "csv" {
-			r.OutputFile.SafeWrite(bl.ToCSV() + "\n")
-		} else if r.FileOutput == "full" {
-			r.OutputFile.SafeWrite(bl.String() + "\n")
+		r.writeFile(r.OutputFile, bl)
+	}
+	r.writeSinks(bl)
+	if bl.IsValid && bl.Source != pkg.NucleiSource {
+		r.writeNucleiTarget(bl.UrlString)
+	}
+}
+
+// OutputFuzzy fuzzy结果拥有独立的match/filter与输出格式
+func (r *Runner) OutputFuzzy(bl *pkg.Baseline) {
+	params := pkg.NewExprEnv(bl, nil, nil)
+	if r.FuzzyMatchExpr != nil && !pkg.CompareWithExpr(r.FuzzyMatchExpr, params) {
+		return
+	}
+	if r.FuzzyFilterExpr != nil && pkg.CompareWithExpr(r.FuzzyFilterExpr, params) {
+		return
+	}
+
+	if r.FuzzyFile == nil && len(r.FuzzyProbes) == 0 {
+		r.Output(bl)
+		return
+	}
+
+	if r.Fuzzy {
+		var out string
+		if r.Option.Json {
+			out = bl.ToJson()
+		} else if r.Template != nil {
+			out = "[fuzzy] " + bl.TemplateOutput(r.Template)
+		} else if len(r.FuzzyProbes) > 0 {
+			out = "[fuzzy] " + bl.ProbeOutput(r.FuzzyProbes)
+		} else if r.Color {
+			out = "[fuzzy] " + bl.ColorString()
 		} else {
-			r.OutputFile.SafeWrite(bl.ProbeOutput(strings.Split(r.FileOutput, ",")) + "\n")
+			out = "[fuzzy] " + bl.String()
 		}
+		logs.Log.Console(out + "\n")
+	}
 
-		r.OutputFile.SafeSync()
+	if r.FuzzyFile != nil {
+		r.writeFile(r.FuzzyFile, bl)
+	} else if r.OutputFile != nil {
+		r.writeFile(r.OutputFile, bl)
 	}
 }
 
+func (r *Runner) writeFile(file *files.File, bl *pkg.Baseline) {
+	if r.FileOutput == "json" {
+		file.SafeWrite(bl.ToJson() + "\n")
+	} else if r.FileOutput == "csv" {
+		file.SafeWrite(bl.ToCSV() + "\n")
+	} else if r.FileOutput == "httpx" {
+		file.SafeWrite(bl.ToHttpxJson() + "\n")
+	} else if r.FileOutput == "msgpack" {
+		content, err := bl.ToMsgpack()
+		if err != nil {
+			logs.Log.Warn(err.Error())
+			return
+		}
+		file.SafeWrite(string(content))
+	} else if r.FileOutput == "full" {
+		file.SafeWrite(bl.String() + "\n")
+	} else {
+		file.SafeWrite(bl.ProbeOutput(strings.Split(r.FileOutput, ",")) + "\n")
+	}
+
+	file.SafeSync()
+}
+
 func (r *Runner) OutputHandler() {
 	go func() {
 		for {
@@ -410,10 +844,19 @@ func (r *Runner) OutputHandler() {
 						r.AddRecursive(bl)
 					}
 				} else {
+					logf := logs.Log.Debug
+					if r.Explain {
+						// --explain下所有被过滤的结果都以info级别输出
+						logf = logs.Log.Info
+					}
 					if r.Color {
-						logs.Log.Debug(bl.ColorString())
+						logf(bl.ColorString())
 					} else {
-						logs.Log.Debug(bl.String())
+						logf(bl.String())
+					}
+					if bl.Recu {
+						// 未输出但仍需要递归的结果, 例如--hidden-only下爬虫可达的目录
+						r.AddRecursive(bl)
 					}
 				}
 				r.outwg.Done()
@@ -428,7 +871,7 @@ func (r *Runner) OutputHandler() {
 				if !ok {
 					return
 				}
-				r.Output(bl)
+				r.OutputFuzzy(bl)
 				r.outwg.Done()
 			}
 		}
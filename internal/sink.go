@@ -0,0 +1,71 @@
+package internal
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/chainreactors/logs"
+	"github.com/chainreactors/spray/pkg"
+)
+
+// Sink 文件之外的结果输出端, 通过 --output scheme://... 指定, 可以同时指定多个
+type Sink interface {
+	WriteBaseline(bl *pkg.Baseline) error
+	WriteStat(stat *pkg.Statistor) error
+	Close() error
+}
+
+// SinkCreators 以url scheme注册的sink构造函数
+var SinkCreators = map[string]func(u *url.URL) (Sink, error){}
+
+func NewSink(addr string) (Sink, error) {
+	u, err := url.Parse(addr)
+	if err != nil {
+		return nil, err
+	}
+	creator, ok := SinkCreators[strings.ToLower(u.Scheme)]
+	if !ok {
+		return nil, fmt.Errorf("unsupported output %s", addr)
+	}
+	return creator(u)
+}
+
+// joinExtracteds 将Extracteds展开为 "name: a,b;name: c" 形式, 用于不支持嵌套结构的sink
+func joinExtracteds(bl *pkg.Baseline) string {
+	var extracteds []string
+	for _, e := range bl.Extracteds {
+		extracteds = append(extracteds, e.Name+": "+strings.Join(e.ExtractResult, ","))
+	}
+	return strings.Join(extracteds, ";")
+}
+
+func (r *Runner) writeSinks(bl *pkg.Baseline) {
+	for _, sink := range r.Sinks {
+		if err := sink.WriteBaseline(bl); err != nil {
+			logs.Log.Warnf("output %s failed, %s", bl.UrlString, err.Error())
+		}
+	}
+}
+
+func (r *Runner) writeSinkStat(stat *pkg.Statistor) {
+	for _, sink := range r.Sinks {
+		if err := sink.WriteStat(stat); err != nil {
+			logs.Log.Warnf("output stat %s failed, %s", stat.BaseUrl, err.Error())
+		}
+	}
+}
+
+// Close 关闭所有sink, 保证缓冲中的结果写入
+func (r *Runner) Close() {
+	if r.Linked != nil {
+		if err := r.Linked.Save(); err != nil {
+			logs.Log.Warn(err.Error())
+		}
+	}
+	for _, sink := range r.Sinks {
+		if err := sink.Close(); err != nil {
+			logs.Log.Warn(err.Error())
+		}
+	}
+}
@@ -0,0 +1,473 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chainreactors/logs"
+	"github.com/chainreactors/spray/pkg"
+)
+
+func init() {
+	SinkCreators["nats"] = NewNATSSink
+	SinkCreators["kafka"] = NewKafkaSink
+}
+
+// busTopic 从url path或topic/subject参数中获取topic, 默认为spray
+func busTopic(u *url.URL, key string) string {
+	if t := u.Query().Get(key); t != "" {
+		return t
+	}
+	if t := strings.Trim(u.Path, "/"); t != "" {
+		return t
+	}
+	return "spray"
+}
+
+// natsReconnects 发布失败时重新连接的次数
+var natsReconnects = 3
+
+// NATSSink 通过nats文本协议发布结果, 连接断开时在下一次发布时重新连接,
+// 协议没有确认机制, 断开时正在写入的结果可能丢失, e.g.: --output nats://127.0.0.1:4222/spray.results
+type NATSSink struct {
+	u       *url.URL
+	conn    net.Conn
+	subject string
+	locker  sync.Mutex
+}
+
+func NewNATSSink(u *url.URL) (Sink, error) {
+	s := &NATSSink{u: u, subject: busTopic(u, "subject")}
+	if err := s.connect(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// connect 建立连接并完成INFO/CONNECT握手, 除构造时外需要持有locker
+func (s *NATSSink) connect() error {
+	host := s.u.Host
+	if s.u.Port() == "" {
+		host = net.JoinHostPort(s.u.Hostname(), "4222")
+	}
+	conn, err := net.DialTimeout("tcp", host, 5*time.Second)
+	if err != nil {
+		return err
+	}
+	reader := bufio.NewReader(conn)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	info, err := reader.ReadString('\n')
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	if !strings.HasPrefix(info, "INFO ") {
+		conn.Close()
+		return fmt.Errorf("not nats server, %s", strings.TrimSpace(info))
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	connect := map[string]interface{}{
+		"verbose":  false,
+		"pedantic": false,
+		"name":     "spray",
+		"lang":     "go",
+	}
+	if s.u.User != nil {
+		if pass, ok := s.u.User.Password(); ok {
+			connect["user"] = s.u.User.Username()
+			connect["pass"] = pass
+		} else {
+			connect["auth_token"] = s.u.User.Username()
+		}
+	}
+	content, _ := json.Marshal(connect)
+	if _, err = conn.Write([]byte("CONNECT " + string(content) + "\r\n")); err != nil {
+		conn.Close()
+		return err
+	}
+
+	s.conn = conn
+	go s.read(conn, reader)
+	return nil
+}
+
+// read 响应服务端的PING并记录错误, 否则会被服务端断开, 连接断开后退出, 由publish重新连接
+func (s *NATSSink) read(conn net.Conn, reader *bufio.Reader) {
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			// 服务端断开后写入仍可能成功, 标记连接失效, 下一次发布时重新连接
+			s.locker.Lock()
+			if s.conn == conn {
+				conn.Close()
+				s.conn = nil
+			}
+			s.locker.Unlock()
+			return
+		}
+		switch {
+		case strings.HasPrefix(line, "PING"):
+			s.locker.Lock()
+			conn.Write([]byte("PONG\r\n"))
+			s.locker.Unlock()
+		case strings.HasPrefix(line, "-ERR"):
+			logs.Log.Warnf("[nats] %s", strings.TrimSpace(line))
+		}
+	}
+}
+
+func (s *NATSSink) publish(content []byte) error {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	var buf bytes.Buffer
+	buf.WriteString("PUB " + s.subject + " " + strconv.Itoa(len(content)) + "\r\n")
+	buf.Write(content)
+	buf.WriteString("\r\n")
+
+	var err error
+	for i := 0; i <= natsReconnects; i++ {
+		if s.conn == nil {
+			time.Sleep(time.Duration(i) * time.Second)
+			if err = s.connect(); err != nil {
+				logs.Log.Debugf("[nats] reconnect %s failed, %s", s.u.Host, err.Error())
+				continue
+			}
+		}
+		s.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+		if _, err = s.conn.Write(buf.Bytes()); err == nil {
+			return nil
+		}
+		s.conn.Close()
+		s.conn = nil
+	}
+	return err
+}
+
+func (s *NATSSink) WriteBaseline(bl *pkg.Baseline) error {
+	return s.publish([]byte(bl.ToJson()))
+}
+
+func (s *NATSSink) WriteStat(stat *pkg.Statistor) error {
+	return nil
+}
+
+func (s *NATSSink) Close() error {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	if s.conn == nil {
+		return nil
+	}
+	return s.conn.Close()
+}
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// KafkaSink 使用produce v3协议将结果写入topic的指定partition(默认0), 通过bootstrap broker的metadata找到partition的leader,
+// leader变更或连接断开时重新查询metadata, 多个bootstrap broker使用逗号分隔,
+// e.g.: --output kafka://10.0.0.1:9092,10.0.0.2:9092/spray?partition=0
+type KafkaSink struct {
+	brokers       []string
+	conn          net.Conn
+	topic         string
+	partition     int32
+	correlationID int32
+	locker        sync.Mutex
+}
+
+func NewKafkaSink(u *url.URL) (Sink, error) {
+	s := &KafkaSink{topic: busTopic(u, "topic")}
+	for _, broker := range strings.Split(u.Host, ",") {
+		if _, _, err := net.SplitHostPort(broker); err != nil {
+			broker = net.JoinHostPort(broker, "9092")
+		}
+		s.brokers = append(s.brokers, broker)
+	}
+	if p := u.Query().Get("partition"); p != "" {
+		partition, err := strconv.Atoi(p)
+		if err != nil {
+			return nil, err
+		}
+		s.partition = int32(partition)
+	}
+	if err := s.connect(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// kafkaError broker返回的错误码
+type kafkaError int16
+
+func (e kafkaError) Error() string {
+	return fmt.Sprintf("kafka error code %d", int16(e))
+}
+
+// retriable LEADER_NOT_AVAILABLE与NOT_LEADER_FOR_PARTITION需要重新查询leader
+func (e kafkaError) retriable() bool {
+	return e == 5 || e == 6
+}
+
+// kafkaReader 按kafka协议的大端格式读取响应
+type kafkaReader struct {
+	buf []byte
+	err error
+}
+
+func (r *kafkaReader) next(n int) []byte {
+	if r.err != nil || len(r.buf) < n {
+		r.err = errors.New("kafka response too short")
+		return make([]byte, n)
+	}
+	b := r.buf[:n]
+	r.buf = r.buf[n:]
+	return b
+}
+
+func (r *kafkaReader) int16() int16 {
+	return int16(binary.BigEndian.Uint16(r.next(2)))
+}
+
+func (r *kafkaReader) int32() int32 {
+	return int32(binary.BigEndian.Uint32(r.next(4)))
+}
+
+func (r *kafkaReader) string() string {
+	n := r.int16()
+	if n < 0 {
+		return ""
+	}
+	return string(r.next(int(n)))
+}
+
+func (s *KafkaSink) header(apiKey, version uint16) []byte {
+	s.correlationID++
+	var req []byte
+	req = binary.BigEndian.AppendUint16(req, apiKey)
+	req = binary.BigEndian.AppendUint16(req, version)
+	req = binary.BigEndian.AppendUint32(req, uint32(s.correlationID))
+	return appendKafkaString(req, "spray")
+}
+
+// roundTrip 发送一个请求并读取完整的响应, 返回去掉correlation id的响应
+func roundTrip(conn net.Conn, req []byte) (*kafkaReader, error) {
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	defer conn.SetDeadline(time.Time{})
+	if _, err := conn.Write(append(binary.BigEndian.AppendUint32(nil, uint32(len(req))), req...)); err != nil {
+		return nil, err
+	}
+	size := make([]byte, 4)
+	if _, err := io.ReadFull(conn, size); err != nil {
+		return nil, err
+	}
+	resp := make([]byte, binary.BigEndian.Uint32(size))
+	if _, err := io.ReadFull(conn, resp); err != nil {
+		return nil, err
+	}
+	r := &kafkaReader{buf: resp}
+	r.int32()
+	return r, r.err
+}
+
+// connect 依次通过bootstrap broker查询metadata并连接partition的leader, 除构造时外需要持有locker
+func (s *KafkaSink) connect() error {
+	var err error
+	for _, broker := range s.brokers {
+		var leader string
+		if leader, err = s.findLeader(broker); err != nil {
+			logs.Log.Debugf("[kafka] metadata from %s failed, %s", broker, err.Error())
+			continue
+		}
+		var conn net.Conn
+		if conn, err = net.DialTimeout("tcp", leader, 5*time.Second); err != nil {
+			logs.Log.Debugf("[kafka] connect leader %s failed, %s", leader, err.Error())
+			continue
+		}
+		s.conn = conn
+		return nil
+	}
+	return err
+}
+
+// findLeader 使用metadata v0查询topic partition的leader地址, topic自动创建时第一次查询可能还没有leader, 稍后重试
+func (s *KafkaSink) findLeader(broker string) (string, error) {
+	conn, err := net.DialTimeout("tcp", broker, 5*time.Second)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	for i := 0; i < 3; i++ {
+		if i > 0 {
+			time.Sleep(time.Second)
+		}
+		// api key: metadata, version 0
+		req := s.header(3, 0)
+		req = binary.BigEndian.AppendUint32(req, 1)
+		req = appendKafkaString(req, s.topic)
+		r, err := roundTrip(conn, req)
+		if err != nil {
+			return "", err
+		}
+
+		brokers := make(map[int32]string)
+		for n := r.int32(); n > 0 && r.err == nil; n-- {
+			id := r.int32()
+			host := r.string()
+			port := r.int32()
+			brokers[id] = net.JoinHostPort(host, strconv.Itoa(int(port)))
+		}
+		leader, code := int32(-1), kafkaError(0)
+		for n := r.int32(); n > 0 && r.err == nil; n-- {
+			if topicCode := kafkaError(r.int16()); topicCode != 0 {
+				code = topicCode
+			}
+			r.string()
+			for m := r.int32(); m > 0 && r.err == nil; m-- {
+				partitionCode := kafkaError(r.int16())
+				id := r.int32()
+				l := r.int32()
+				r.next(4 * int(r.int32())) // replicas
+				r.next(4 * int(r.int32())) // isr
+				if id == s.partition {
+					leader, code = l, partitionCode
+				}
+			}
+		}
+		if r.err != nil {
+			return "", r.err
+		}
+		if addr, ok := brokers[leader]; ok && code == 0 {
+			return addr, nil
+		}
+		if code != 0 && !code.retriable() {
+			return "", code
+		}
+	}
+	return "", fmt.Errorf("no leader for %s partition %d", s.topic, s.partition)
+}
+
+func appendKafkaString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
+
+// recordBatch 构造只包含一条record的RecordBatch v2
+func recordBatch(value []byte) []byte {
+	now := time.Now().UnixMilli()
+	var record []byte
+	record = append(record, 0)               // attributes
+	record = binary.AppendVarint(record, 0)  // timestamp delta
+	record = binary.AppendVarint(record, 0)  // offset delta
+	record = binary.AppendVarint(record, -1) // key
+	record = binary.AppendVarint(record, int64(len(value)))
+	record = append(record, value...)
+	record = binary.AppendVarint(record, 0) // headers
+
+	var body []byte
+	body = binary.BigEndian.AppendUint16(body, 0)           // attributes
+	body = binary.BigEndian.AppendUint32(body, 0)           // last offset delta
+	body = binary.BigEndian.AppendUint64(body, uint64(now)) // base timestamp
+	body = binary.BigEndian.AppendUint64(body, uint64(now)) // max timestamp
+	body = binary.BigEndian.AppendUint64(body, ^uint64(0))  // producer id
+	body = binary.BigEndian.AppendUint16(body, ^uint16(0))  // producer epoch
+	body = binary.BigEndian.AppendUint32(body, ^uint32(0))  // base sequence
+	body = binary.BigEndian.AppendUint32(body, 1)           // records count
+	body = binary.AppendVarint(body, int64(len(record)))
+	body = append(body, record...)
+
+	var batch []byte
+	batch = binary.BigEndian.AppendUint64(batch, 0) // base offset
+	batch = binary.BigEndian.AppendUint32(batch, uint32(4+1+4+len(body)))
+	batch = binary.BigEndian.AppendUint32(batch, ^uint32(0)) // partition leader epoch
+	batch = append(batch, 2)                                 // magic
+	batch = binary.BigEndian.AppendUint32(batch, crc32.Checksum(body, castagnoli))
+	return append(batch, body...)
+}
+
+func (s *KafkaSink) produce(value []byte) error {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	batch := recordBatch(value)
+
+	var err error
+	for i := 0; i < 2; i++ {
+		if s.conn == nil {
+			if err = s.connect(); err != nil {
+				return err
+			}
+		}
+		// api key: produce, version 3
+		req := s.header(0, 3)
+		req = binary.BigEndian.AppendUint16(req, ^uint16(0)) // transactional id: null
+		req = binary.BigEndian.AppendUint16(req, 1)          // acks
+		req = binary.BigEndian.AppendUint32(req, 5000)       // timeout
+		req = binary.BigEndian.AppendUint32(req, 1)
+		req = appendKafkaString(req, s.topic)
+		req = binary.BigEndian.AppendUint32(req, 1)
+		req = binary.BigEndian.AppendUint32(req, uint32(s.partition))
+		req = binary.BigEndian.AppendUint32(req, uint32(len(batch)))
+		req = append(req, batch...)
+
+		if err = s.readProduceResponse(roundTrip(s.conn, req)); err == nil {
+			return nil
+		}
+		var code kafkaError
+		if errors.As(err, &code) && !code.retriable() {
+			return err
+		}
+		// 连接断开或leader变更, 重新查询metadata
+		s.conn.Close()
+		s.conn = nil
+	}
+	return err
+}
+
+// readProduceResponse 只解析单topic单partition的错误码
+func (s *KafkaSink) readProduceResponse(r *kafkaReader, err error) error {
+	if err != nil {
+		return err
+	}
+	// topics count(4) + topic name(2+n) + partitions count(4) + partition(4) + error code(2)
+	r.int32()
+	r.string()
+	r.int32()
+	r.int32()
+	code := kafkaError(r.int16())
+	if r.err != nil {
+		return r.err
+	}
+	if code != 0 {
+		return code
+	}
+	return nil
+}
+
+func (s *KafkaSink) WriteBaseline(bl *pkg.Baseline) error {
+	return s.produce([]byte(bl.ToJson()))
+}
+
+func (s *KafkaSink) WriteStat(stat *pkg.Statistor) error {
+	return nil
+}
+
+func (s *KafkaSink) Close() error {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	if s.conn == nil {
+		return nil
+	}
+	return s.conn.Close()
+}
@@ -0,0 +1,131 @@
+package internal
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chainreactors/spray/pkg"
+)
+
+func init() {
+	SinkCreators["syslog"] = NewSyslogSink
+	SinkCreators["syslog+udp"] = NewSyslogSink
+	SinkCreators["syslog+tcp"] = NewSyslogSink
+}
+
+const (
+	syslogFacility = 16 // local0
+	syslogNotice   = 5
+	syslogInfo     = 6
+	// syslogEnterpriseID 结构化数据的SD-ID, 使用RFC5424保留的示例私有编号
+	syslogEnterpriseID = "spray@32473"
+	// syslogTimestamp RFC5424要求秒的小数部分不超过6位, RFC3339Nano会输出9位并去掉末尾的0
+	syslogTimestamp = "2006-01-02T15:04:05.000000Z07:00"
+)
+
+// SyslogSink 输出RFC5424格式的syslog, 默认udp, tcp使用RFC6587 octet-counting分帧,
+// e.g.: --output syslog://127.0.0.1:514 --output syslog+tcp://127.0.0.1:601
+type SyslogSink struct {
+	conn     net.Conn
+	tcp      bool
+	hostname string
+	locker   sync.Mutex
+}
+
+func NewSyslogSink(u *url.URL) (Sink, error) {
+	network := "udp"
+	if strings.HasSuffix(u.Scheme, "+tcp") || u.Query().Get("proto") == "tcp" {
+		network = "tcp"
+	}
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "514")
+	}
+	conn, err := net.DialTimeout(network, host, 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+	return &SyslogSink{conn: conn, tcp: network == "tcp", hostname: hostname}, nil
+}
+
+// escapeSDParam 按RFC5424转义结构化数据中的 " \ ]
+func escapeSDParam(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(s)
+}
+
+// FormatSyslog 生成一条RFC5424格式的syslog消息
+func FormatSyslog(severity int, hostname, msgid string, params [][2]string, msg string) string {
+	var sd strings.Builder
+	sd.WriteString("[" + syslogEnterpriseID)
+	for _, p := range params {
+		if p[1] == "" {
+			continue
+		}
+		sd.WriteString(fmt.Sprintf(` %s="%s"`, p[0], escapeSDParam(p[1])))
+	}
+	sd.WriteString("]")
+	return fmt.Sprintf("<%d>1 %s %s spray %d %s %s %s",
+		syslogFacility*8+severity,
+		time.Now().Format(syslogTimestamp),
+		hostname,
+		os.Getpid(),
+		msgid,
+		sd.String(),
+		msg)
+}
+
+func (s *SyslogSink) send(msg string) error {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	if s.tcp {
+		msg = strconv.Itoa(len(msg)) + " " + msg
+	}
+	_, err := s.conn.Write([]byte(msg))
+	return err
+}
+
+func (s *SyslogSink) WriteBaseline(bl *pkg.Baseline) error {
+	severity := syslogNotice
+	if !bl.IsValid {
+		severity = syslogInfo
+	}
+	params := [][2]string{
+		{"url", bl.UrlString},
+		{"status", strconv.Itoa(bl.Status)},
+		{"length", strconv.Itoa(bl.BodyLength)},
+		{"title", bl.Title},
+		{"frameworks", bl.Frameworks.String()},
+		{"extracteds", joinExtracteds(bl)},
+		{"source", pkg.SourceName(bl.Source)},
+		{"fuzzy", strconv.FormatBool(bl.IsFuzzy)},
+	}
+	msg := fmt.Sprintf("found %s [%d] %s", bl.UrlString, bl.Status, bl.Title)
+	return s.send(FormatSyslog(severity, s.hostname, "finding", params, msg))
+}
+
+func (s *SyslogSink) WriteStat(stat *pkg.Statistor) error {
+	params := [][2]string{
+		{"url", stat.BaseUrl},
+		{"req_total", strconv.Itoa(int(stat.ReqTotal))},
+		{"found", strconv.FormatInt(stat.FoundNumber, 10)},
+		{"fuzzy", strconv.Itoa(stat.FuzzyNumber)},
+		{"failed", strconv.Itoa(int(stat.FailedNumber))},
+		{"error", stat.Error},
+	}
+	msg := fmt.Sprintf("finished %s, found %d", stat.BaseUrl, stat.FoundNumber)
+	return s.send(FormatSyslog(syslogInfo, s.hostname, "stat", params, msg))
+}
+
+func (s *SyslogSink) Close() error {
+	return s.conn.Close()
+}
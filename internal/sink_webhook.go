@@ -0,0 +1,178 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/chainreactors/logs"
+	"github.com/chainreactors/spray/pkg"
+)
+
+const (
+	DefaultNotifyTemplate = "[{{.Status}}] {{.UrlString}} [{{.BodyLength}}] {{.Title}}"
+	webhookBatchSize      = 20 // 单条消息最多合并的结果数
+)
+
+// WebhookSink 将有效结果推送到webhook, 结果先进入缓冲, 按间隔或数量合并为一条消息发送, 避免触发平台限流
+// 根据域名自动适配slack, discord与钉钉的消息格式, 其他地址发送通用json: {"text": "...", "results": [...]}
+// e.g.: --notify https://hooks.slack.com/services/xxx --notify-template '{{.UrlString}} {{.Title}}'
+type WebhookSink struct {
+	URL      string
+	kind     string
+	tmpl     *template.Template
+	client   *http.Client
+	interval time.Duration
+	locker   sync.Mutex
+	lines    []string
+	results  []json.RawMessage
+	closeCh  chan struct{}
+	wg       sync.WaitGroup
+}
+
+func NewWebhookSink(addr, tmpl string, interval time.Duration) (*WebhookSink, error) {
+	u, err := url.Parse(addr)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid webhook %s", addr)
+	}
+	if tmpl == "" {
+		tmpl = DefaultNotifyTemplate
+	}
+	t, err := template.New("notify").Parse(tmpl)
+	if err != nil {
+		return nil, err
+	}
+	if interval <= 0 {
+		interval = time.Second
+	}
+	s := &WebhookSink{
+		URL:      addr,
+		kind:     webhookKind(u.Hostname()),
+		tmpl:     t,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		interval: interval,
+		closeCh:  make(chan struct{}),
+	}
+	s.wg.Add(1)
+	go s.loop()
+	return s, nil
+}
+
+func webhookKind(host string) string {
+	switch {
+	case strings.HasSuffix(host, "slack.com"):
+		return "slack"
+	case strings.HasSuffix(host, "discord.com"), strings.HasSuffix(host, "discordapp.com"):
+		return "discord"
+	case strings.HasSuffix(host, "dingtalk.com"):
+		return "dingtalk"
+	default:
+		return "json"
+	}
+}
+
+func (s *WebhookSink) loop() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.flush()
+		case <-s.closeCh:
+			s.flush()
+			return
+		}
+	}
+}
+
+func (s *WebhookSink) WriteBaseline(bl *pkg.Baseline) error {
+	if !bl.IsValid {
+		return nil
+	}
+	var buf bytes.Buffer
+	if err := s.tmpl.Execute(&buf, bl); err != nil {
+		return err
+	}
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	s.lines = append(s.lines, buf.String())
+	s.results = append(s.results, json.RawMessage(bl.ToJson()))
+	return nil
+}
+
+func (s *WebhookSink) WriteStat(stat *pkg.Statistor) error {
+	return nil
+}
+
+// flush 每个间隔最多发送一条消息, 超出单条上限的结果留到下一个间隔
+func (s *WebhookSink) flush() {
+	s.locker.Lock()
+	n := len(s.lines)
+	if n == 0 {
+		s.locker.Unlock()
+		return
+	}
+	if n > webhookBatchSize {
+		n = webhookBatchSize
+	}
+	lines, results := s.lines[:n], s.results[:n]
+	s.lines, s.results = s.lines[n:], s.results[n:]
+	s.locker.Unlock()
+
+	if err := s.post(lines, results); err != nil {
+		logs.Log.Warnf("notify %s failed, %s", s.URL, err.Error())
+	}
+}
+
+func (s *WebhookSink) post(lines []string, results []json.RawMessage) error {
+	text := strings.Join(lines, "\n")
+	var payload interface{}
+	switch s.kind {
+	case "slack":
+		payload = map[string]string{"text": text}
+	case "discord":
+		payload = map[string]string{"content": text}
+	case "dingtalk":
+		payload = map[string]interface{}{"msgtype": "text", "text": map[string]string{"content": text}}
+	default:
+		payload = map[string]interface{}{"text": text, "results": results}
+	}
+	content, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.Post(s.URL, "application/json", bytes.NewReader(content))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// Close 停止定时发送, 将缓冲中剩余的结果全部发送
+func (s *WebhookSink) Close() error {
+	close(s.closeCh)
+	s.wg.Wait()
+	for {
+		s.locker.Lock()
+		remain := len(s.lines)
+		s.locker.Unlock()
+		if remain == 0 {
+			return nil
+		}
+		s.flush()
+	}
+}
@@ -3,29 +3,57 @@ package internal
 import (
 	"fmt"
 	"github.com/chainreactors/logs"
+	"github.com/chainreactors/spray/pkg"
 	"github.com/chainreactors/utils"
 	"github.com/chainreactors/words/rule"
+	"net"
 	"net/url"
+	"sort"
+	"strings"
 )
 
 type Task struct {
-	baseUrl string
-	depth   int
-	rule    []rule.Expression
-	origin  *Origin
+	baseUrl  string
+	depth    int
+	rule     []rule.Expression
+	origin   *Origin
+	techs    []string // 外部输入的指纹, 例如httpx的tech字段
+	template string   // 目标自带的路径模板, e.g.: /api/{word}/v1/{id}
 }
 
-func NewTaskGenerator(port string) *TaskGenerator {
+// Stat 尚未开始扫描的任务的stat, 退出时保存以便--resume继续, 从断点续传中恢复的任务保留原来的进度
+func (t *Task) Stat() *pkg.Statistor {
+	var stat *pkg.Statistor
+	if t.origin != nil {
+		origin := *t.origin.Statistor
+		stat = &origin
+	} else {
+		stat = pkg.NewStatistor(t.baseUrl)
+	}
+	stat.Depth = t.depth - 1
+	return stat
+}
+
+func NewTaskGenerator(port string, mergeResolved bool) *TaskGenerator {
 	gen := &TaskGenerator{
-		ports: utils.ParsePortsString(port),
-		tasks: make(chan *Task),
-		In:    make(chan *Task),
+		ports:         utils.ParsePortsString(port),
+		tasks:         make(chan *Task),
+		In:            make(chan *Task),
+		mergeResolved: mergeResolved,
+		seen:          make(map[string]string),
+		resolved:      make(map[string]string),
 	}
 
 	go func() {
 		for task := range gen.In {
+			if gen.merge(task) {
+				continue
+			}
 			gen.tasks <- task
 		}
+		if gen.Merged > 0 {
+			logs.Log.Importantf("merged %d duplicate targets", gen.Merged)
+		}
 		close(gen.tasks)
 	}()
 	return gen
@@ -33,13 +61,51 @@ func NewTaskGenerator(port string) *TaskGenerator {
 }
 
 type TaskGenerator struct {
-	Name  string
-	ports []string
-	tasks chan *Task
-	In    chan *Task
+	Name          string
+	Merged        int
+	ports         []string
+	tasks         chan *Task
+	In            chan *Task
+	mergeResolved bool
+	seen          map[string]string // 归一化后的key -> 首次出现的目标
+	resolved      map[string]string // host -> 解析结果
+}
+
+// merge 归一化后重复的目标将被合并, 开启mergeResolved时解析到相同ip的host也视为重复
+func (gen *TaskGenerator) merge(task *Task) bool {
+	key := task.baseUrl + "|" + task.template
+	if gen.mergeResolved {
+		if u, err := url.Parse(task.baseUrl); err == nil {
+			key = u.Scheme + "://" + gen.resolve(u.Hostname()) + ":" + u.Port() + u.Path + "|" + task.template
+		}
+	}
+	if first, ok := gen.seen[key]; ok {
+		gen.Merged++
+		logs.Log.Importantf("[merge] %s duplicate with %s, skipped", task.baseUrl, first)
+		return true
+	}
+	gen.seen[key] = task.baseUrl
+	return false
 }
 
-func (gen *TaskGenerator) Run(baseurl string) {
+func (gen *TaskGenerator) resolve(host string) string {
+	if ips, ok := gen.resolved[host]; ok {
+		return ips
+	}
+	ips := host
+	if addrs, err := net.LookupHost(host); err == nil && len(addrs) > 0 {
+		sort.Strings(addrs)
+		ips = strings.Join(addrs, ",")
+	}
+	gen.resolved[host] = ips
+	return ips
+}
+
+func (gen *TaskGenerator) Run(baseurl string, techs ...string) {
+	var template string
+	if pkg.IsPathTemplate(baseurl) {
+		baseurl, template = pkg.SplitPathTemplate(baseurl)
+	}
 	parsed, err := url.Parse(baseurl)
 	if err != nil {
 		logs.Log.Warnf("parse %s, %s ", baseurl, err.Error())
@@ -55,15 +121,15 @@ func (gen *TaskGenerator) Run(baseurl string) {
 	}
 
 	if len(gen.ports) == 0 {
-		gen.In <- &Task{baseUrl: parsed.String()}
+		gen.In <- &Task{baseUrl: pkg.NormalizeURL(parsed.String()), techs: techs, template: template}
 		return
 	}
 
 	for _, p := range gen.ports {
 		if parsed.Host == "" {
-			gen.In <- &Task{baseUrl: fmt.Sprintf("%s://%s:%s", parsed.Scheme, parsed.Path, p)}
+			gen.In <- &Task{baseUrl: pkg.NormalizeURL(fmt.Sprintf("%s://%s:%s", parsed.Scheme, parsed.Path, p)), techs: techs, template: template}
 		} else {
-			gen.In <- &Task{baseUrl: fmt.Sprintf("%s://%s:%s/%s", parsed.Scheme, parsed.Host, p, parsed.Path)}
+			gen.In <- &Task{baseUrl: pkg.NormalizeURL(fmt.Sprintf("%s://%s:%s/%s", parsed.Scheme, parsed.Host, p, parsed.Path)), techs: techs, template: template}
 		}
 	}
 }
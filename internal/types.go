@@ -16,11 +16,25 @@ type Origin struct {
 
 func (o *Origin) InitWorder(fns []words.WordFunc) (*words.Worder, error) {
 	var worder *words.Worder
-	wl, err := pkg.LoadWordlist(o.Word, o.Dictionaries)
+	gen, err := pkg.LoadWordGenerator(o.Word, o.Dictionaries)
 	if err != nil {
 		return nil, err
 	}
-	worder = words.NewWorderWithList(wl)
+	if o.RuleSyntax == "hashcat" {
+		rules, err := pkg.LoadHashcatRules(o.RuleFiles)
+		if err != nil {
+			return nil, err
+		}
+		worder = words.NewWorderWithChan(pkg.HashcatStream(gen.Stream(), rules))
+		worder.Fns = fns
+		if len(rules) > 0 {
+			o.sum = len(rules) * gen.Count
+		} else {
+			o.sum = gen.Count
+		}
+		return worder, nil
+	}
+	worder = words.NewWorderWithChan(gen.Stream())
 	worder.Fns = fns
 	rules, err := pkg.LoadRuleWithFiles(o.RuleFiles, o.RuleFilter)
 	if err != nil {
@@ -28,9 +42,9 @@ func (o *Origin) InitWorder(fns []words.WordFunc) (*words.Worder, error) {
 	}
 	worder.Rules = rules
 	if len(rules) > 0 {
-		o.sum = len(rules) * len(wl)
+		o.sum = len(rules) * gen.Count
 	} else {
-		o.sum = len(wl)
+		o.sum = gen.Count
 	}
 
 	return worder, nil
@@ -0,0 +1,181 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/chainreactors/logs"
+	"github.com/chainreactors/parsers"
+	"github.com/chainreactors/spray/internal/ihttp"
+	"github.com/chainreactors/spray/pkg"
+)
+
+const (
+	VerifyConfirmed = "confirmed"
+	VerifyChanged   = "changed"
+	VerifyGone      = "gone"
+)
+
+type VerifyOptions struct {
+	Client  string `short:"C" long:"client" default:"standard" choice:"fast" choice:"standard" choice:"h3" description:"String, Client type"`
+	Proxy   string `long:"proxy" description:"String, proxy address, e.g.: --proxy socks5://127.0.0.1:1080"`
+	Timeout int    `short:"T" long:"timeout" default:"5" description:"Int, timeout with request (seconds)"`
+	Thread  int    `short:"t" long:"thread" default:"20" description:"Int, number of concurrent request"`
+	Output  string `short:"f" long:"file" description:"String, write verify result to file as json lines"`
+	Fuzzy   bool   `long:"fuzzy" description:"Bool, verify fuzzy result too"`
+}
+
+type VerifyResult struct {
+	Url         string `json:"url"`
+	State       string `json:"state"`
+	OldStatus   int    `json:"old_status"`
+	Status      int    `json:"status"`
+	OldLength   int    `json:"old_length"`
+	Length      int    `json:"length"`
+	HashMatched bool   `json:"hash_matched"`
+	ErrString   string `json:"error,omitempty"`
+}
+
+func (r *VerifyResult) String() string {
+	s := fmt.Sprintf("[%s] %s [%d -> %d] [%d -> %d]", r.State, r.Url, r.OldStatus, r.Status, r.OldLength, r.Length)
+	if r.ErrString != "" {
+		s += " " + r.ErrString
+	}
+	return s
+}
+
+func (r *VerifyResult) ColorString() string {
+	switch r.State {
+	case VerifyConfirmed:
+		return logs.GreenBold(r.String())
+	case VerifyChanged:
+		return logs.YellowBold(r.String())
+	default:
+		return logs.RedBold(r.String())
+	}
+}
+
+// Verify 重新请求结果文件中的每一个记录, 对比状态码, 长度与body hash, 标记为confirmed, changed或gone
+func Verify(filename string, opts *VerifyOptions) error {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	lines, err := resultLines(content)
+	if err != nil {
+		return err
+	}
+
+	var out *os.File
+	if opts.Output != "" {
+		out, err = os.Create(opts.Output)
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+	}
+
+	clientType := ihttp.STANDARD
+	if opts.Client == "fast" {
+		clientType = ihttp.FAST
+	} else if opts.Client == "h3" {
+		clientType = ihttp.H3
+	}
+	client := ihttp.NewClient(&ihttp.ClientConfig{
+		Type:      clientType,
+		Timeout:   time.Duration(opts.Timeout) * time.Second,
+		Thread:    opts.Thread,
+		ProxyAddr: opts.Proxy,
+	})
+
+	ch := make(chan *pkg.Baseline)
+	var wg sync.WaitGroup
+	var locker sync.Mutex
+	counts := make(map[string]int)
+	for i := 0; i < opts.Thread; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for bl := range ch {
+				res := verifyBaseline(client, clientType, bl)
+				locker.Lock()
+				counts[res.State]++
+				logs.Log.Console(res.ColorString() + "\n")
+				if out != nil {
+					b, _ := json.Marshal(res)
+					out.Write(append(b, '\n'))
+				}
+				locker.Unlock()
+			}
+		}()
+	}
+
+	for _, line := range lines {
+		if len(line) == 0 {
+			continue
+		}
+		var bl pkg.Baseline
+		if err := json.Unmarshal(line, &bl); err != nil {
+			logs.Log.Error(err.Error())
+			continue
+		}
+		if bl.IsFuzzy && !opts.Fuzzy {
+			continue
+		}
+		ch <- &bl
+	}
+	close(ch)
+	wg.Wait()
+
+	logs.Log.Importantf("verify %s finished, confirmed: %d, changed: %d, gone: %d", filename,
+		counts[VerifyConfirmed], counts[VerifyChanged], counts[VerifyGone])
+	return nil
+}
+
+func verifyBaseline(client *ihttp.Client, clientType int, origin *pkg.Baseline) *VerifyResult {
+	res := &VerifyResult{
+		Url:       origin.UrlString,
+		OldStatus: origin.Status,
+		OldLength: origin.BodyLength,
+		State:     VerifyGone,
+	}
+	u, err := url.Parse(origin.UrlString)
+	if err != nil {
+		res.ErrString = err.Error()
+		return res
+	}
+	req, err := ihttp.BuildRequest(context.Background(), clientType, origin.UrlString, "", origin.Host, "GET")
+	if err != nil {
+		res.ErrString = err.Error()
+		return res
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		res.ErrString = err.Error()
+		return res
+	}
+
+	bl := pkg.NewBaseline(origin.UrlString, u.Host, resp)
+	res.Status = bl.Status
+	res.Length = bl.BodyLength
+	if origin.Hashes != nil {
+		hashes := parsers.NewHashes(bl.Raw)
+		res.HashMatched = hashes.BodyMd5 == origin.Hashes.BodyMd5
+	} else {
+		res.HashMatched = bl.BodyLength == origin.BodyLength
+	}
+
+	if res.Status == origin.Status && res.HashMatched {
+		res.State = VerifyConfirmed
+	} else if (res.Status == 404 || res.Status == 410) && origin.Status != res.Status {
+		res.State = VerifyGone
+	} else {
+		res.State = VerifyChanged
+	}
+	return res
+}
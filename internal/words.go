@@ -0,0 +1,53 @@
+package internal
+
+import (
+	"context"
+	"errors"
+
+	"github.com/chainreactors/logs"
+	"github.com/chainreactors/words"
+)
+
+// PreviewWords 按照扫描时相同的流程(mask, 字典, 规则, 后缀, decorator)生成字典并输出, 不发送任何请求,
+// --offset与--limit控制输出的范围, 总数为实际生成的数量而非预估值
+func PreviewWords(opt *Option) error {
+	r := &Runner{Option: opt}
+	if err := opt.BuildWords(r); err != nil {
+		return err
+	}
+	if r.IsCheck {
+		return errors.New("no words, use -w, -d, -r, --slot or --payload to generate words")
+	}
+
+	var worder *words.Worder
+	estimated := r.Total
+	if r.Payloads != nil {
+		worder = words.NewWorderWithChan(r.Payloads.Generate(context.Background()))
+		estimated = r.Payloads.Count()
+	} else {
+		worder = r.newWorder()
+		worder.Fns = r.WordFuncs(0)
+		worder.Rules = r.Rules.Expressions
+		var total int
+		if worder, total = r.orderWorder(worder, r.wordOrder()); total >= 0 {
+			estimated = total
+		}
+	}
+	worder.Run()
+
+	var total, skipped, printed int
+	for w := range worder.Output {
+		if w == "" {
+			// 被过滤的word, 扫描时同样跳过
+			skipped++
+			continue
+		}
+		total++
+		if total > opt.Offset && (opt.Limit == 0 || printed < opt.Limit) {
+			logs.Log.Console(w + "\n")
+			printed++
+		}
+	}
+	logs.Log.Importantf("total %d words, %d skipped, estimated %d, printed %d", total, skipped, estimated, printed)
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"github.com/vbauerster/mpb/v8"
 	"github.com/vbauerster/mpb/v8/decor"
+	"sync/atomic"
 	"time"
 )
 
@@ -21,7 +22,7 @@ func NewBar(u string, total int, stat *Statistor, p *mpb.Progress) *Bar {
 			decor.NewAverageSpeed(0, "% .0f/s ", time.Now()),
 			decor.Counters(0, "%d/%d"),
 			decor.Any(func(s decor.Statistics) string {
-				return fmt.Sprintf(" found: %d", stat.FoundNumber)
+				return fmt.Sprintf(" found: %d", atomic.LoadInt64(&stat.FoundNumber))
 			}),
 		),
 		mpb.AppendDecorators(
@@ -2,6 +2,9 @@ package pkg
 
 import (
 	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
 	"github.com/chainreactors/fingers/common"
 	"github.com/chainreactors/parsers"
 	"github.com/chainreactors/spray/internal/ihttp"
@@ -9,6 +12,7 @@ import (
 	"github.com/chainreactors/utils/iutils"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 )
 
@@ -21,6 +25,19 @@ func NewBaseline(u, host string, resp *ihttp.Response) *Baseline {
 			IsValid:    true,
 			Frameworks: make(common.Frameworks),
 		},
+		Protocol:  resp.Proto(),
+		Redirects: resp.Redirects,
+	}
+	if state := resp.TLSState(); state != nil {
+		bl.TLSVersion = ihttp.TLSVersionName(state.Version)
+		bl.TLSCipher = tls.CipherSuiteName(state.CipherSuite)
+		if len(state.PeerCertificates) > 0 {
+			cert := state.PeerCertificates[0]
+			bl.CertSubject = cert.Subject.CommonName
+			bl.CertIssuer = cert.Issuer.CommonName
+			bl.CertSANs = cert.DNSNames
+			bl.CertExpire = cert.NotAfter.Unix()
+		}
 	}
 
 	if t, ok := ContentTypeMap[resp.ContentType()]; ok {
@@ -47,6 +64,7 @@ func NewBaseline(u, host string, resp *ihttp.Response) *Baseline {
 		} else {
 			bl.BodyLength = int(i)
 		}
+		bl.Words, bl.Lines = CountWords(bl.Body)
 	}
 
 	bl.Raw = append(bl.Header, bl.Body...)
@@ -62,6 +80,12 @@ func NewBaseline(u, host string, resp *ihttp.Response) *Baseline {
 	} else {
 		bl.RedirectURL = bl.Response.Header.Get("location")
 	}
+	if len(bl.Redirects) > 0 {
+		bl.Extracteds = append(bl.Extracteds, &parsers.Extracted{
+			Name:          "redirect",
+			ExtractResult: []string{bl.Chain()},
+		})
+	}
 
 	bl.Dir = bl.IsDir()
 	uu, err := url.Parse(u)
@@ -88,6 +112,7 @@ func NewInvalidBaseline(u, host string, resp *ihttp.Response, reason string) *Ba
 			IsValid:   false,
 			Reason:    reason,
 		},
+		Redirects: resp.Redirects,
 	}
 
 	// 无效数据也要读取body, 否则keep-alive不生效
@@ -115,6 +140,7 @@ type Baseline struct {
 	*parsers.SprayResult
 	Url                *url.URL       `json:"-"`
 	Dir                bool           `json:"-"`
+	DirChecked         bool           `json:"-"`
 	Chunked            bool           `json:"-"`
 	Body               BS             `json:"-"`
 	Header             BS             `json:"-"`
@@ -127,9 +153,27 @@ type Baseline struct {
 	Retry              int            `json:"-"`
 	SameRedirectDomain bool           `json:"-"`
 	IsBaseline         bool           `json:"-"`
+	Protocol           string         `json:"-"` // 协商得到的协议版本, e.g.: HTTP/2.0
+	TLSVersion         string         `json:"-"` // e.g.: TLS 1.2
+	TLSCipher          string         `json:"-"`
+	CertSubject        string         `json:"-"` // 叶子证书的CN
+	CertIssuer         string         `json:"-"`
+	CertSANs           []string       `json:"-"`
+	CertExpire         int64          `json:"-"` // 证书过期时间戳
+	Redirects          RedirectChain  `json:"-"` // --follow-redirects经过的跳转, 不包含最终响应
+	Words              int            `json:"-"` // body中以空白分隔的单词数
+	Lines              int            `json:"-"`
+	SimilarHash        interface{}    `json:"-"` // --similarity算法计算的特征, 对比时按需计算
+	Listing            bool           `json:"-"` // 是否为目录列表页面
+	ListingEntries     []string       `json:"-"`
+	Method             string         `json:"-"` // 请求使用的方法
 }
 
 func (bl *Baseline) IsDir() bool {
+	if bl.DirChecked {
+		// --dir-check确认的结果
+		return bl.Dir
+	}
 	if strings.HasSuffix(bl.Path, "/") {
 		return true
 	}
@@ -165,7 +209,9 @@ func (bl *Baseline) Collect() {
 	}
 
 	bl.Hashes = parsers.NewHashes(bl.Raw)
-	bl.Extracteds = Extractors.Extract(string(bl.Raw))
+	bl.Extracteds = append(bl.Extracteds, Extractors.Extract(string(bl.Raw))...)
+	bl.CollectListing()
+	bl.CollectMisconfig()
 	bl.Unique = UniqueHash(bl)
 }
 
@@ -237,18 +283,124 @@ func (bl *Baseline) Compare(other *Baseline) int {
 	return -1
 }
 
+type RedirectChain []*ihttp.Redirect
+
+// jsonResult 在SprayResult的基础上附加协议, tls证书与重定向链等信息, json与msgpack输出共用
+type jsonResult struct {
+	*parsers.SprayResult
+	Protocol    string        `json:"protocol,omitempty"`
+	TLSVersion  string        `json:"tls_version,omitempty"`
+	TLSCipher   string        `json:"tls_cipher,omitempty"`
+	CertSubject string        `json:"cert_subject,omitempty"`
+	CertIssuer  string        `json:"cert_issuer,omitempty"`
+	CertSANs    []string      `json:"cert_sans,omitempty"`
+	Redirects   RedirectChain `json:"redirect_chain,omitempty"`
+	Words       int           `json:"words"`
+	Lines       int           `json:"lines"`
+	Listing     bool          `json:"listing,omitempty"`
+	Method      string        `json:"method,omitempty"`
+}
+
+func (bl *Baseline) jsonResult() *jsonResult {
+	return &jsonResult{bl.SprayResult, bl.Protocol, bl.TLSVersion, bl.TLSCipher, bl.CertSubject, bl.CertIssuer, bl.CertSANs, bl.Redirects, bl.Words, bl.Lines, bl.Listing, bl.Method}
+}
+
+// ToJson 每个结果输出为一行
+func (bl *Baseline) ToJson() string {
+	bs, err := json.Marshal(bl.jsonResult())
+	if err != nil {
+		return ""
+	}
+	return string(bs)
+}
+
+// ToMsgpack 与ToJson字段一致的messagepack编码
+func (bl *Baseline) ToMsgpack() ([]byte, error) {
+	return MsgpackEncode(bl.jsonResult())
+}
+
+// Chain 重定向链, e.g.: 301 /admin -> 302 /admin/ -> 200
+func (bl *Baseline) Chain() string {
+	if len(bl.Redirects) == 0 {
+		return ""
+	}
+	var hops []string
+	for _, r := range bl.Redirects {
+		hops = append(hops, strconv.Itoa(r.Status)+" "+r.Location)
+	}
+	return strings.Join(append(hops, strconv.Itoa(bl.Status)), " -> ")
+}
+
 func (bl *Baseline) ProbeOutput(format []string) string {
 	var s strings.Builder
 	for _, f := range format {
 		s.WriteString("\t")
-		s.WriteString(bl.Get(f))
+		switch f {
+		case "protocol":
+			s.WriteString(bl.Protocol)
+		case "tls":
+			s.WriteString(strings.TrimSpace(bl.TLSVersion + " " + bl.TLSCipher))
+		case "san":
+			s.WriteString(strings.Join(bl.CertSANs, ","))
+		case "cert":
+			s.WriteString(bl.CertSubject)
+		case "chain":
+			s.WriteString(bl.Chain())
+		case "words":
+			s.WriteString(strconv.Itoa(bl.Words))
+		case "lines":
+			s.WriteString(strconv.Itoa(bl.Lines))
+		case "source":
+			s.WriteString(SourceName(bl.Source))
+		case "from":
+			s.WriteString(SourceName(bl.From))
+		default:
+			s.WriteString(bl.Get(f))
+		}
 	}
 	return strings.TrimSpace(s.String())
 }
 
-var Distance uint8 = 5 // 数字越小越相似, 数字为0则为完全一致.
+var (
+	Distance       uint8   = 5    // 数字越小越相似, 数字为0则为完全一致.
+	RatioThreshold         = 0    // body小于该长度时, 使用编辑距离代替simhash, 0为关闭
+	Ratio          float64 = 0.85 // 编辑距离相似度阈值, 越接近1越相似
+)
+
+func (bl *Baseline) similarHash(s Similarity) interface{} {
+	if bl.SimilarHash == nil {
+		bl.SimilarHash = s.Hash(bl.Body)
+	}
+	return bl.SimilarHash
+}
+
+// SimilarityDetail FuzzyCompare使用的算法与结果, 用于--explain
+func (bl *Baseline) SimilarityDetail(other *Baseline) string {
+	if RatioThreshold > 0 && len(bl.Body) < RatioThreshold && len(other.Body) < RatioThreshold {
+		return fmt.Sprintf("levenshtein ratio %.2f >= %.2f", SimilarityRatio(bl.Body, other.Body), Ratio)
+	}
+	if _, ok := Similarities[SimilarityAlgo]; ok && bl.SimilarHash != nil && other.SimilarHash != nil {
+		if SimilarityAlgo == SimilarityTLSH {
+			return fmt.Sprintf("tlsh distance %d <= %d", bl.SimilarHash.(*TLSH).Diff(other.SimilarHash.(*TLSH)), TLSHDistance)
+		}
+		return SimilarityAlgo + " similar"
+	}
+	return fmt.Sprintf("simhash distance %d < %d", other.Distance, Distance)
+}
 
 func (bl *Baseline) FuzzyCompare(other *Baseline) bool {
+	if RatioThreshold > 0 && len(bl.Body) < RatioThreshold && len(other.Body) < RatioThreshold {
+		// 超短的body(例如json报错, 简单的错误页)simhash不可靠, 改用编辑距离相似度
+		return SimilarityRatio(bl.Body, other.Body) >= Ratio
+	}
+
+	if s, ok := Similarities[SimilarityAlgo]; ok {
+		// 数据不足以计算特征时(例如tlsh要求至少50字节), 回退到simhash
+		if a, b := bl.similarHash(s), other.similarHash(s); a != nil && b != nil {
+			return s.Similar(a, b)
+		}
+	}
+
 	// 这里使用rawsimhash, 是为了保证一定数量的字符串, 否则超短的body会导致simhash偏差指较大
 	if other.Distance = encode.SimhashCompare(other.RawSimhash, bl.RawSimhash); other.Distance < Distance {
 		return true
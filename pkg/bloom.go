@@ -0,0 +1,61 @@
+package pkg
+
+import (
+	"hash/fnv"
+	"math"
+	"sync"
+)
+
+// BloomFilter 用于海量url去重, 以可控的误判率换取固定的内存占用, 误判只会导致少量url被跳过
+type BloomFilter struct {
+	locker sync.Mutex
+	bits   []uint64
+	m      uint64
+	k      uint64
+}
+
+// NewBloomFilter 根据预期数量n与误判率fp计算位数组大小与hash次数
+func NewBloomFilter(n int, fp float64) *BloomFilter {
+	if n <= 0 {
+		n = 1
+	}
+	if fp <= 0 || fp >= 1 {
+		fp = 0.001
+	}
+	m := uint64(math.Ceil(-float64(n) * math.Log(fp) / (math.Ln2 * math.Ln2)))
+	k := uint64(math.Max(1, math.Round(float64(m)/float64(n)*math.Ln2)))
+	return &BloomFilter{
+		bits: make([]uint64, (m+63)/64),
+		m:    m,
+		k:    k,
+	}
+}
+
+func (b *BloomFilter) hashes(key string) (uint64, uint64) {
+	h1 := fnv.New64a()
+	h1.Write([]byte(key))
+	h2 := fnv.New64()
+	h2.Write([]byte(key))
+	return h1.Sum64(), h2.Sum64() | 1
+}
+
+// TestAndAdd 返回key是否(可能)已经存在, 并将其加入过滤器
+func (b *BloomFilter) TestAndAdd(key string) bool {
+	h1, h2 := b.hashes(key)
+	b.locker.Lock()
+	defer b.locker.Unlock()
+	exist := true
+	for i := uint64(0); i < b.k; i++ {
+		pos := (h1 + i*h2) % b.m
+		if b.bits[pos/64]&(1<<(pos%64)) == 0 {
+			exist = false
+			b.bits[pos/64] |= 1 << (pos % 64)
+		}
+	}
+	return exist
+}
+
+// Size 位数组占用的字节数
+func (b *BloomFilter) Size() int {
+	return len(b.bits) * 8
+}
@@ -0,0 +1,70 @@
+package pkg
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+type decompressReader struct {
+	io.Reader
+	closers    []func() error
+	compressed bool
+}
+
+func (r *decompressReader) Close() error {
+	var err error
+	for _, c := range r.closers {
+		if e := c(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// OpenFile 打开字典与规则文件, gzip与zstd压缩的文件根据文件头自动解压, 与文件后缀无关
+func OpenFile(filename string) (io.ReadCloser, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	br := bufio.NewReader(f)
+	head, _ := br.Peek(4)
+	switch {
+	case bytes.HasPrefix(head, gzipMagic):
+		gr, err := gzip.NewReader(br)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		return &decompressReader{Reader: gr, closers: []func() error{gr.Close, f.Close}, compressed: true}, nil
+	case bytes.HasPrefix(head, zstdMagic):
+		zr, err := zstd.NewReader(br)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		return &decompressReader{Reader: zr, closers: []func() error{func() error { zr.Close(); return nil }, f.Close}, compressed: true}, nil
+	default:
+		return &decompressReader{Reader: br, closers: []func() error{f.Close}}, nil
+	}
+}
+
+// ReadFile 与os.ReadFile相同, 压缩的文件返回解压后的内容
+func ReadFile(filename string) ([]byte, error) {
+	r, err := OpenFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
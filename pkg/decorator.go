@@ -0,0 +1,245 @@
+package pkg
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/chainreactors/utils/iutils"
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm"
+)
+
+// DecoratorFuncs 可在配置文件中按顺序声明的字典变换, 参数使用":"分割, e.g.: prefix:api_, replace:old:new, encode:url
+// -U, -L, --replace, --skip, --remove-extension, --exclude-extension与--encode也会转换为对应的decorator
+var DecoratorFuncs = map[string]func(args []string) (func(string) []string, error){
+	"prefix": func(args []string) (func(string) []string, error) {
+		if len(args) == 0 {
+			return nil, fmt.Errorf("prefix need an argument")
+		}
+		return func(s string) []string {
+			var ws []string
+			for _, p := range args {
+				ws = append(ws, p+s)
+			}
+			return ws
+		}, nil
+	},
+	"suffix": func(args []string) (func(string) []string, error) {
+		if len(args) == 0 {
+			return nil, fmt.Errorf("suffix need an argument")
+		}
+		return func(s string) []string {
+			var ws []string
+			for _, p := range args {
+				ws = append(ws, s+p)
+			}
+			return ws
+		}, nil
+	},
+	"upper": func(args []string) (func(string) []string, error) {
+		return WrapWordsFunc(strings.ToUpper), nil
+	},
+	"lower": func(args []string) (func(string) []string, error) {
+		return WrapWordsFunc(strings.ToLower), nil
+	},
+	"title": func(args []string) (func(string) []string, error) {
+		return WrapWordsFunc(func(s string) string {
+			if s == "" {
+				return s
+			}
+			return strings.ToUpper(s[:1]) + s[1:]
+		}), nil
+	},
+	"replace": func(args []string) (func(string) []string, error) {
+		if len(args) != 2 {
+			return nil, fmt.Errorf("replace need old and new, e.g.: replace:old:new")
+		}
+		return WrapWordsFunc(func(s string) string {
+			return strings.ReplaceAll(s, args[0], args[1])
+		}), nil
+	},
+	"encode": func(args []string) (func(string) []string, error) {
+		if len(args) == 0 {
+			return nil, fmt.Errorf("encode need encoder name, e.g.: encode:url, encode:urlencode:doubleurl")
+		}
+		encoders, err := ParseEncoders(strings.Join(args, ","))
+		if err != nil {
+			return nil, err
+		}
+		return WrapWordsFunc(func(s string) string {
+			for _, encoder := range encoders {
+				s = encoder(s)
+			}
+			return s
+		}), nil
+	},
+	"remove-ext": func(args []string) (func(string) []string, error) {
+		if len(args) == 0 {
+			return nil, fmt.Errorf("remove-ext need an argument")
+		}
+		return WrapWordsFunc(func(s string) string {
+			if ext := ParseExtension(s); iutils.StringsContains(args, ext) {
+				return strings.TrimSuffix(s, "."+ext)
+			}
+			return s
+		}), nil
+	},
+	"exclude-ext": func(args []string) (func(string) []string, error) {
+		if len(args) == 0 {
+			return nil, fmt.Errorf("exclude-ext need an argument")
+		}
+		return func(s string) []string {
+			if ext := ParseExtension(s); iutils.StringsContains(args, ext) {
+				return nil
+			}
+			return []string{s}
+		}, nil
+	},
+	"skip": func(args []string) (func(string) []string, error) {
+		if len(args) == 0 {
+			return nil, fmt.Errorf("skip need an argument")
+		}
+		return func(s string) []string {
+			for _, skip := range args {
+				if strings.Contains(s, skip) {
+					return nil
+				}
+			}
+			return []string{s}
+		}, nil
+	},
+}
+
+// WordEncoders 可用于--encode与encode decorator的编码, urlencode, doubleurl与unicode会编码包括字母在内的全部字符, 只保留路径分隔符"/"
+var WordEncoders = map[string]func(string) string{
+	"url": url.PathEscape,
+	"urlencode": func(s string) string {
+		return encodePath(s, func(b byte) string {
+			return fmt.Sprintf("%%%02X", b)
+		})
+	},
+	"doubleurl": func(s string) string {
+		return encodePath(s, func(b byte) string {
+			return fmt.Sprintf("%%25%02X", b)
+		})
+	},
+	"unicode": func(s string) string {
+		var sb strings.Builder
+		for _, r := range s {
+			if r == '/' {
+				sb.WriteRune(r)
+			} else if r > 0xffff {
+				// 超出BMP的字符使用utf-16代理对
+				r1, r2 := utf16.EncodeRune(r)
+				sb.WriteString(fmt.Sprintf("%%u%04X%%u%04X", r1, r2))
+			} else {
+				sb.WriteString(fmt.Sprintf("%%u%04X", r))
+			}
+		}
+		return sb.String()
+	},
+	"base64": func(s string) string {
+		return base64.StdEncoding.EncodeToString([]byte(s))
+	},
+	"hex": func(s string) string {
+		return hex.EncodeToString([]byte(s))
+	},
+}
+
+// encodePath 逐字节编码, 保留"/"
+func encodePath(s string, enc func(byte) string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '/' {
+			sb.WriteByte(s[i])
+		} else {
+			sb.WriteString(enc(s[i]))
+		}
+	}
+	return sb.String()
+}
+
+// ParseEncoders 解析逗号分割的编码列表, 按声明顺序依次编码
+func ParseEncoders(s string) ([]func(string) string, error) {
+	var encoders []func(string) string
+	for _, name := range strings.Split(s, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		encoder, ok := WordEncoders[name]
+		if !ok {
+			var names []string
+			for k := range WordEncoders {
+				names = append(names, k)
+			}
+			sort.Strings(names)
+			return nil, fmt.Errorf("unknown encoder %s, available: %s", name, strings.Join(names, ","))
+		}
+		encoders = append(encoders, encoder)
+	}
+	return encoders, nil
+}
+
+// Decorator 字典变换管道中的一个环节, Guard不为空时只作用于满足条件的word
+type Decorator struct {
+	Raw   string
+	Fn    func(string) []string
+	Guard *vm.Program
+}
+
+// Func 返回绑定了递归深度的变换函数, guard中可以使用word与depth两个变量
+func (d *Decorator) Func(depth int) func(string) []string {
+	if d.Guard == nil {
+		return d.Fn
+	}
+	return func(s string) []string {
+		if CompareWithExpr(d.Guard, map[string]interface{}{"word": s, "depth": depth}) {
+			return d.Fn(s)
+		}
+		return []string{s}
+	}
+}
+
+// ParseDecorator 解析单个decorator声明, 格式为 name[:arg1[:arg2]] [if expr]
+// e.g.: suffix:.php if word matches "^[a-z_]+$", prefix:v1_ if depth == 0
+func ParseDecorator(s string) (*Decorator, error) {
+	d := &Decorator{Raw: s}
+	if i := strings.Index(s, " if "); i != -1 {
+		guard, err := expr.Compile(strings.TrimSpace(s[i+4:]), expr.Env(map[string]interface{}{"word": "", "depth": 0}), expr.AsBool())
+		if err != nil {
+			return nil, err
+		}
+		d.Guard = guard
+		s = s[:i]
+	}
+
+	parts := strings.Split(strings.TrimSpace(s), ":")
+	decorator, err := NewDecorator(parts[0], parts[1:]...)
+	if err != nil {
+		return nil, err
+	}
+	d.Fn = decorator.Fn
+	return d, nil
+}
+
+// NewDecorator 使用已经拆分好的参数构造decorator, 参数中可以包含":", 用于将flag转换为decorator
+func NewDecorator(name string, args ...string) (*Decorator, error) {
+	name = strings.ToLower(name)
+	fn, ok := DecoratorFuncs[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown decorator %s", name)
+	}
+	d := &Decorator{Raw: strings.Join(append([]string{name}, args...), ":")}
+	var err error
+	d.Fn, err = fn(args)
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
@@ -17,6 +17,19 @@ const (
 	ErrFuzzyNotUnique
 	ErrUrlError
 	ErrResponseError
+	ErrBypassFailed
+	ErrQuickNotMatch
+	ErrMaxTimeExceeded
+	ErrVerifyFailed
+	ErrTLSHandshake
+	ErrPrecheckSkip
+	ErrLinkedPath
+	ErrResponseNotMatch
+	ErrCalibrated
+	ErrDNSNotResolved
+	ErrDNSWildcard
+	ErrHookDropped
+	ErrUserSkipped
 )
 
 var ErrMap = map[ErrorType]string{
@@ -34,6 +47,19 @@ var ErrMap = map[ErrorType]string{
 	ErrFuzzyNotUnique:      "not unique",
 	ErrUrlError:            "url parse error",
 	ErrResponseError:       "response parse error",
+	ErrBypassFailed:        "bypass failed",
+	ErrQuickNotMatch:       "quick check not match",
+	ErrMaxTimeExceeded:     "max time per host exceeded",
+	ErrVerifyFailed:        "second opinion verify failed",
+	ErrTLSHandshake:        "tls handshake failed",
+	ErrPrecheckSkip:        "skipped by precheck",
+	ErrLinkedPath:          "reachable by public link",
+	ErrResponseNotMatch:    "body size/words/lines/regex/time not match",
+	ErrCalibrated:          "auto calibration filtered",
+	ErrDNSNotResolved:      "dns not resolved",
+	ErrDNSWildcard:         "dns wildcard",
+	ErrHookDropped:         "dropped by --on-response",
+	ErrUserSkipped:         "skipped by user",
 }
 
 func (e ErrorType) Error() string {
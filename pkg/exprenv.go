@@ -0,0 +1,74 @@
+package pkg
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// ExprBaseline 传入expr的current/index/random, 在Baseline的基础上提供解析后的header与cookie
+// Headers的key为规范化的header名, 多个值以", "连接, current.Header仍然是原始的header字节
+// e.g.: current.Headers["X-Powered-By"] contains "PHP", current.Cookies["JSESSIONID"] != ""
+type ExprBaseline struct {
+	*Baseline
+	Headers map[string]string
+	Cookies map[string]string
+}
+
+func NewExprBaseline(bl *Baseline) *ExprBaseline {
+	if bl == nil {
+		return nil
+	}
+	eb := &ExprBaseline{Baseline: bl, Headers: make(map[string]string), Cookies: make(map[string]string)}
+	if bl.Response == nil {
+		return eb
+	}
+	for k, v := range bl.Response.Header {
+		eb.Headers[http.CanonicalHeaderKey(k)] = strings.Join(v, ", ")
+	}
+	for _, c := range bl.Response.Cookies() {
+		eb.Cookies[c.Name] = c.Value
+	}
+	return eb
+}
+
+var regexCache sync.Map
+
+// ExprFuncs expr中可用的辅助函数, len, lower, upper等使用expr内置函数
+var ExprFuncs = map[string]interface{}{
+	// icontains(current.Title, "login") 忽略大小写的contains
+	"icontains": func(s, sub string) bool {
+		return strings.Contains(strings.ToLower(s), strings.ToLower(sub))
+	},
+	// regex("v[0-9]+", current.Headers["Server"]) 正则编译后缓存, 非法的正则视为不匹配
+	"regex": func(pattern, s string) bool {
+		if r, ok := regexCache.Load(pattern); ok {
+			return r.(*regexp.Regexp).MatchString(s)
+		}
+		r, err := regexp.Compile(pattern)
+		if err != nil {
+			return false
+		}
+		regexCache.Store(pattern, r)
+		return r.MatchString(s)
+	},
+}
+
+// NewExprEnv 构造--match/--filter/--recursive等表达式的运行环境
+func NewExprEnv(current, index, random *Baseline) map[string]interface{} {
+	return NewExprEnvWith(current, NewExprBaseline(index), NewExprBaseline(random))
+}
+
+// NewExprEnvWith index与random在pool中不会随结果变化, 由调用者构造一次后复用, 避免每个结果都重新解析header与cookie
+func NewExprEnvWith(current *Baseline, index, random *ExprBaseline) map[string]interface{} {
+	env := map[string]interface{}{
+		"current": NewExprBaseline(current),
+		"index":   index,
+		"random":  random,
+	}
+	for name, fn := range ExprFuncs {
+		env[name] = fn
+	}
+	return env
+}
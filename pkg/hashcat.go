@@ -0,0 +1,392 @@
+package pkg
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// HashcatRule 一行hashcat语法的规则, 支持hashcat的全部规则函数,
+// 包括基于位置的插入与覆盖, 记忆(M 4 6 X Q)以及拒绝规则(< > _ ! / ( ) = % Q)
+type HashcatRule struct {
+	Raw string
+	ops []hashcatOp
+}
+
+type hashcatOp struct {
+	name byte
+	args []byte
+}
+
+// hashcatArgs 每个函数的参数, N与M为位置(0-9, A-Z表示10-35), X与Y为字符
+var hashcatArgs = map[byte]string{
+	':': "", 'l': "", 'u': "", 'c': "", 'C': "", 't': "", 'T': "N", 'r': "", 'd': "", 'p': "N",
+	'f': "", '{': "", '}': "", '$': "X", '^': "X", '[': "", ']': "", 'D': "N", 'x': "NM", 'O': "NM",
+	'i': "NX", 'o': "NX", '\'': "N", 's': "XY", '@': "X", 'z': "N", 'Z': "N", 'q': "", 'k': "", 'K': "",
+	'*': "NM", 'L': "N", 'R': "N", '+': "N", '-': "N", '.': "N", ',': "N", 'y': "N", 'Y': "N", 'E': "",
+	'e': "X", '3': "NX", 'M': "", '4': "", '6': "", 'X': "NMN", 'Q': "",
+	'<': "N", '>': "N", '_': "N", '!': "X", '/': "X", '(': "X", ')': "X", '=': "NX", '%': "NX",
+}
+
+func hashcatPos(c byte) (byte, bool) {
+	switch {
+	case c >= '0' && c <= '9':
+		return c - '0', true
+	case c >= 'A' && c <= 'Z':
+		return c - 'A' + 10, true
+	}
+	return 0, false
+}
+
+// ParseHashcatRule 解析单行规则, 函数之间的空白会被忽略, 作为参数时除外
+func ParseHashcatRule(line string) (*HashcatRule, error) {
+	r := &HashcatRule{Raw: line}
+	for i := 0; i < len(line); i++ {
+		name := line[i]
+		if name == ' ' || name == '\t' {
+			continue
+		}
+		spec, ok := hashcatArgs[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown rule function %q at %d", name, i)
+		}
+		if len(spec) > len(line)-i-1 {
+			return nil, fmt.Errorf("rule function %q at %d need %d arguments", name, i, len(spec))
+		}
+		op := hashcatOp{name: name}
+		start := i
+		for j := 0; j < len(spec); j++ {
+			i++
+			arg := line[i]
+			if spec[j] != 'X' && spec[j] != 'Y' {
+				pos, ok := hashcatPos(arg)
+				if !ok {
+					return nil, fmt.Errorf("rule function %q at %d need position 0-9 or A-Z, got %q", name, start, arg)
+				}
+				arg = pos
+			}
+			op.args = append(op.args, arg)
+		}
+		r.ops = append(r.ops, op)
+	}
+	if len(r.ops) == 0 {
+		return nil, fmt.Errorf("empty rule")
+	}
+	return r, nil
+}
+
+// ParseHashcatRules 解析规则文件内容, 跳过空行与#开头的注释
+func ParseHashcatRules(content string) ([]*HashcatRule, error) {
+	var rules []*HashcatRule
+	for i, line := range strings.Split(content, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		r, err := ParseHashcatRule(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d %q, %w", i+1, line, err)
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+// LoadHashcatRules 与LoadRuleAndCombine相同支持预设规则与压缩文件
+func LoadHashcatRules(filenames []string) ([]*HashcatRule, error) {
+	content, err := LoadRuleAndCombine(filenames)
+	if err != nil {
+		return nil, err
+	}
+	return ParseHashcatRules(content)
+}
+
+// Apply 对word执行规则, 被拒绝规则过滤时返回false. 位置超出长度的函数不做修改, 与hashcat一致
+func (r *HashcatRule) Apply(word string) (string, bool) {
+	w := []byte(word)
+	var mem []byte
+	for _, op := range r.ops {
+		var n, m int
+		if len(op.args) > 0 {
+			n = int(op.args[0])
+		}
+		if len(op.args) > 1 {
+			m = int(op.args[1])
+		}
+		l := len(w)
+		switch op.name {
+		case ':':
+		case 'l':
+			w = bytes.ToLower(w)
+		case 'u':
+			w = bytes.ToUpper(w)
+		case 'c':
+			w = bytes.ToLower(w)
+			if l > 0 {
+				w[0] = upperByte(w[0])
+			}
+		case 'C':
+			w = bytes.ToUpper(w)
+			if l > 0 {
+				w[0] = lowerByte(w[0])
+			}
+		case 't':
+			for i := range w {
+				w[i] = toggleByte(w[i])
+			}
+		case 'T':
+			if n < l {
+				w[n] = toggleByte(w[n])
+			}
+		case 'r':
+			for i, j := 0, l-1; i < j; i, j = i+1, j-1 {
+				w[i], w[j] = w[j], w[i]
+			}
+		case 'd':
+			w = append(w, w...)
+		case 'p':
+			raw := append([]byte{}, w...)
+			for i := 0; i < n; i++ {
+				w = append(w, raw...)
+			}
+		case 'f':
+			for i := l - 1; i >= 0; i-- {
+				w = append(w, w[i])
+			}
+		case '{':
+			if l > 1 {
+				w = append(w[1:], w[0])
+			}
+		case '}':
+			if l > 1 {
+				w = append([]byte{w[l-1]}, w[:l-1]...)
+			}
+		case '$':
+			w = append(w, op.args[0])
+		case '^':
+			w = append([]byte{op.args[0]}, w...)
+		case '[':
+			if l > 0 {
+				w = w[1:]
+			}
+		case ']':
+			if l > 0 {
+				w = w[:l-1]
+			}
+		case 'D':
+			if n < l {
+				w = append(w[:n], w[n+1:]...)
+			}
+		case 'x':
+			if n < l && n+m <= l {
+				w = append([]byte{}, w[n:n+m]...)
+			}
+		case 'O':
+			if n < l && n+m <= l {
+				w = append(w[:n], w[n+m:]...)
+			}
+		case 'i':
+			if n <= l {
+				w = append(w[:n], append([]byte{op.args[1]}, w[n:]...)...)
+			}
+		case 'o':
+			if n < l {
+				w[n] = op.args[1]
+			}
+		case '\'':
+			if n < l {
+				w = w[:n]
+			}
+		case 's':
+			w = bytes.ReplaceAll(w, []byte{op.args[0]}, []byte{op.args[1]})
+		case '@':
+			w = bytes.ReplaceAll(w, []byte{op.args[0]}, nil)
+		case 'z':
+			if l > 0 {
+				w = append(bytes.Repeat(w[:1], n), w...)
+			}
+		case 'Z':
+			if l > 0 {
+				w = append(w, bytes.Repeat(w[l-1:], n)...)
+			}
+		case 'q':
+			dup := make([]byte, 0, l*2)
+			for _, c := range w {
+				dup = append(dup, c, c)
+			}
+			w = dup
+		case 'k':
+			if l > 1 {
+				w[0], w[1] = w[1], w[0]
+			}
+		case 'K':
+			if l > 1 {
+				w[l-2], w[l-1] = w[l-1], w[l-2]
+			}
+		case '*':
+			if n < l && m < l {
+				w[n], w[m] = w[m], w[n]
+			}
+		case 'L':
+			if n < l {
+				w[n] <<= 1
+			}
+		case 'R':
+			if n < l {
+				w[n] >>= 1
+			}
+		case '+':
+			if n < l {
+				w[n]++
+			}
+		case '-':
+			if n < l {
+				w[n]--
+			}
+		case '.':
+			if n+1 < l {
+				w[n] = w[n+1]
+			}
+		case ',':
+			if n > 0 && n < l {
+				w[n] = w[n-1]
+			}
+		case 'y':
+			if n <= l {
+				w = append(append([]byte{}, w[:n]...), w...)
+			}
+		case 'Y':
+			if n <= l {
+				w = append(w, w[l-n:]...)
+			}
+		case 'E':
+			w = titleBytes(w, ' ')
+		case 'e':
+			w = titleBytes(w, op.args[0])
+		case '3':
+			// 第n个(从0开始)分隔符之后的字符切换大小写
+			var count int
+			for i := 0; i < l-1; i++ {
+				if w[i] == op.args[1] {
+					if count == n {
+						w[i+1] = toggleByte(w[i+1])
+						break
+					}
+					count++
+				}
+			}
+		case 'M':
+			mem = append([]byte{}, w...)
+		case '4':
+			w = append(w, mem...)
+		case '6':
+			w = append(append([]byte{}, mem...), w...)
+		case 'X':
+			i := int(op.args[2])
+			if n < len(mem) && n+m <= len(mem) && i <= l {
+				w = append(w[:i], append(append([]byte{}, mem[n:n+m]...), w[i:]...)...)
+			}
+		case 'Q':
+			if bytes.Equal(w, mem) {
+				return "", false
+			}
+		case '<':
+			if l > n {
+				return "", false
+			}
+		case '>':
+			if l < n {
+				return "", false
+			}
+		case '_':
+			if l != n {
+				return "", false
+			}
+		case '!':
+			if bytes.IndexByte(w, op.args[0]) != -1 {
+				return "", false
+			}
+		case '/':
+			if bytes.IndexByte(w, op.args[0]) == -1 {
+				return "", false
+			}
+		case '(':
+			if l == 0 || w[0] != op.args[0] {
+				return "", false
+			}
+		case ')':
+			if l == 0 || w[l-1] != op.args[0] {
+				return "", false
+			}
+		case '=':
+			if n >= l || w[n] != op.args[1] {
+				return "", false
+			}
+		case '%':
+			if bytes.Count(w, []byte{op.args[1]}) < n {
+				return "", false
+			}
+		}
+	}
+	return string(w), true
+}
+
+// ApplyHashcatRules 每条规则生成一个结果, 被拒绝的结果为空字符串, 保证数量与规则数一致, 扫描时计为skipped
+func ApplyHashcatRules(rules []*HashcatRule, word string) []string {
+	ws := make([]string, len(rules))
+	for i, r := range rules {
+		ws[i], _ = r.Apply(word)
+	}
+	return ws
+}
+
+// HashcatStream 在字典流之前展开规则
+func HashcatStream(words chan string, rules []*HashcatRule) chan string {
+	ch := make(chan string)
+	go func() {
+		defer close(ch)
+		for w := range words {
+			if w == "" {
+				continue
+			}
+			for _, s := range ApplyHashcatRules(rules, w) {
+				ch <- s
+			}
+		}
+	}()
+	return ch
+}
+
+func upperByte(c byte) byte {
+	if c >= 'a' && c <= 'z' {
+		return c - 32
+	}
+	return c
+}
+
+func lowerByte(c byte) byte {
+	if c >= 'A' && c <= 'Z' {
+		return c + 32
+	}
+	return c
+}
+
+func toggleByte(c byte) byte {
+	if c >= 'a' && c <= 'z' {
+		return c - 32
+	} else if c >= 'A' && c <= 'Z' {
+		return c + 32
+	}
+	return c
+}
+
+// titleBytes 全部小写后, 首字母与分隔符之后的字母大写
+func titleBytes(w []byte, sep byte) []byte {
+	w = bytes.ToLower(w)
+	for i := range w {
+		if i == 0 || w[i-1] == sep {
+			w[i] = upperByte(w[i])
+		}
+	}
+	return w
+}
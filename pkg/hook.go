@@ -0,0 +1,54 @@
+package pkg
+
+import (
+	"github.com/chainreactors/logs"
+	"github.com/chainreactors/parsers"
+	"github.com/chainreactors/spray/internal/ihttp"
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm"
+)
+
+// ResponseHook --on-response, 在对比之前对每个响应依次执行表达式, 运行环境与--match相同, 额外提供以下函数, 均返回true:
+//
+//	extract(name, value) 添加到结果的extracteds, drop() 丢弃当前结果, set_var(name, value) 保存变量供--on-request使用
+//
+// e.g.: current.Headers["X-Token"] != "" && set_var("token", current.Headers["X-Token"])
+type ResponseHook struct {
+	Programs []*vm.Program
+	Vars     *ihttp.HookVars
+}
+
+func NewResponseHook(scripts []string, vars *ihttp.HookVars) (*ResponseHook, error) {
+	programs, err := ihttp.CompileHooks(scripts)
+	if err != nil {
+		return nil, err
+	}
+	return &ResponseHook{Programs: programs, Vars: vars}, nil
+}
+
+// Run 执行所有表达式, 返回是否被drop
+func (h *ResponseHook) Run(current *Baseline, index, random *ExprBaseline) bool {
+	var dropped bool
+	env := NewExprEnvWith(current, index, random)
+	env["extract"] = func(name, value string) bool {
+		current.Extracteds = append(current.Extracteds, &parsers.Extracted{
+			Name:          name,
+			ExtractResult: []string{value},
+		})
+		return true
+	}
+	env["drop"] = func() bool {
+		dropped = true
+		return true
+	}
+	for name, fn := range ihttp.HookFuncs {
+		env[name] = fn
+	}
+	h.Vars.Funcs(env)
+	for _, program := range h.Programs {
+		if _, err := expr.Run(program, env); err != nil {
+			logs.Log.Warnf("--on-response %s, %s", program.Source().String(), err.Error())
+		}
+	}
+	return dropped
+}
@@ -0,0 +1,106 @@
+package pkg
+
+import (
+	"encoding/json"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HttpxResult httpx -json 的输出格式, 同时用于读取httpx结果作为输入与输出httpx兼容的结果
+type HttpxResult struct {
+	Timestamp     string   `json:"timestamp,omitempty"`
+	URL           string   `json:"url"`
+	Input         string   `json:"input,omitempty"`
+	Host          string   `json:"host,omitempty"`
+	Port          string   `json:"port,omitempty"`
+	Scheme        string   `json:"scheme,omitempty"`
+	Path          string   `json:"path,omitempty"`
+	Title         string   `json:"title,omitempty"`
+	ContentType   string   `json:"content_type,omitempty"`
+	ContentLength int      `json:"content_length"`
+	StatusCode    int      `json:"status_code"`
+	Location      string   `json:"location,omitempty"`
+	Webserver     string   `json:"webserver,omitempty"`
+	Tech          []string `json:"tech,omitempty"`
+	Time          string   `json:"time,omitempty"`
+	Failed        bool     `json:"failed"`
+	Source        string   `json:"source,omitempty"`
+}
+
+// ParseHttpxLine 读取一行httpx json结果, 返回目标url与tech
+func ParseHttpxLine(line string) (string, []string, bool) {
+	var result HttpxResult
+	if err := json.Unmarshal([]byte(line), &result); err != nil {
+		return "", nil, false
+	}
+	if result.URL != "" {
+		return result.URL, result.Tech, true
+	}
+	if result.Host != "" {
+		scheme := result.Scheme
+		if scheme == "" {
+			scheme = "http"
+		}
+		host := result.Host
+		if result.Port != "" {
+			host = net.JoinHostPort(host, result.Port)
+		}
+		return scheme + "://" + host, result.Tech, true
+	}
+	if result.Input != "" {
+		return result.Input, result.Tech, true
+	}
+	return "", nil, false
+}
+
+func NewHttpxResult(bl *Baseline) *HttpxResult {
+	result := &HttpxResult{
+		Timestamp:     time.Now().Format(time.RFC3339),
+		URL:           bl.UrlString,
+		Path:          bl.Path,
+		Title:         bl.Title,
+		ContentLength: bl.BodyLength,
+		StatusCode:    bl.Status,
+		Location:      bl.RedirectURL,
+		Time:          strconv.FormatInt(bl.Spended, 10) + "ms",
+		Failed:        bl.ErrString != "",
+		Source:        SourceName(bl.Source),
+	}
+	if bl.Url != nil {
+		result.Input = bl.Url.Host
+		result.Host = bl.Url.Hostname()
+		result.Scheme = bl.Url.Scheme
+		result.Port = bl.Url.Port()
+		if result.Port == "" {
+			if bl.Url.Scheme == "https" {
+				result.Port = "443"
+			} else {
+				result.Port = "80"
+			}
+		}
+	} else if u, err := url.Parse(bl.UrlString); err == nil {
+		result.Input = u.Host
+		result.Host = u.Hostname()
+		result.Scheme = u.Scheme
+	}
+	if bl.Response != nil {
+		result.ContentType = strings.TrimSpace(strings.Split(bl.Response.Header.Get("Content-Type"), ";")[0])
+		result.Webserver = bl.Response.Header.Get("Server")
+	}
+	for _, frame := range bl.Frameworks {
+		result.Tech = append(result.Tech, frame.Name)
+	}
+	return result
+}
+
+// ToHttpxJson 输出httpx兼容的json格式
+func (bl *Baseline) ToHttpxJson() string {
+	content, err := json.Marshal(NewHttpxResult(bl))
+	if err != nil {
+		return ""
+	}
+	return string(content)
+}
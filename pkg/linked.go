@@ -0,0 +1,70 @@
+package pkg
+
+import (
+	"encoding/json"
+	"os"
+	"sort"
+	"sync"
+)
+
+// LinkedStore 记录每个目标通过爬虫可达的路径, 保存到文件后可以在之后的任务中复用
+type LinkedStore struct {
+	locker   sync.RWMutex
+	Filename string
+	Targets  map[string][]string
+}
+
+func LoadLinkedStore(filename string) (*LinkedStore, error) {
+	store := &LinkedStore{Filename: filename, Targets: make(map[string][]string)}
+	if filename == "" {
+		return store, nil
+	}
+	content, err := os.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return store, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(content, &store.Targets); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+func (s *LinkedStore) Get(target string) (map[string]struct{}, bool) {
+	s.locker.RLock()
+	defer s.locker.RUnlock()
+	paths, ok := s.Targets[target]
+	if !ok {
+		return nil, false
+	}
+	set := make(map[string]struct{}, len(paths))
+	for _, p := range paths {
+		set[p] = struct{}{}
+	}
+	return set, true
+}
+
+func (s *LinkedStore) Set(target string, set map[string]struct{}) {
+	paths := make([]string, 0, len(set))
+	for p := range set {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	s.locker.Lock()
+	s.Targets[target] = paths
+	s.locker.Unlock()
+}
+
+func (s *LinkedStore) Save() error {
+	if s.Filename == "" {
+		return nil
+	}
+	s.locker.RLock()
+	content, err := json.MarshalIndent(s.Targets, "", "  ")
+	s.locker.RUnlock()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.Filename, content, 0o644)
+}
@@ -0,0 +1,57 @@
+package pkg
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/chainreactors/parsers"
+)
+
+// ListingRegexp 常见web服务器的目录列表页面特征
+// apache/nginx/lighttpd: Index of /, python http.server: Directory listing for /, tomcat: Directory Listing For /, iis: [To Parent Directory]
+var ListingRegexp = regexp.MustCompile(`(?i)<title>\s*(Index of /|Directory listing for /)|<h1>\s*Index of /|\[To Parent Directory]`)
+
+var listingHrefRegexp = regexp.MustCompile(`(?i)<a\s[^>]*href\s*=\s*["']([^"'#]+)["']`)
+
+// ParseListing 解析目录列表中的条目, 去除排序链接, 上级目录与其他站点的链接, 目录以"/"结尾
+func ParseListing(body []byte) []string {
+	var entries []string
+	for _, match := range listingHrefRegexp.FindAllSubmatch(body, -1) {
+		href := string(match[1])
+		if strings.HasPrefix(href, "?") || strings.HasPrefix(href, "..") || strings.Contains(href, "://") || strings.HasPrefix(href, "//") {
+			continue
+		}
+		if strings.HasPrefix(href, "/") {
+			// iis与部分服务器使用绝对路径, 只保留最后一段
+			trimmed := strings.TrimSuffix(href, "/")
+			if trimmed == "" {
+				continue
+			}
+			name := trimmed[strings.LastIndex(trimmed, "/")+1:]
+			if strings.HasSuffix(href, "/") {
+				name += "/"
+			}
+			href = name
+		}
+		if href == "" || href == "./" || href == "/" {
+			continue
+		}
+		entries = append(entries, href)
+	}
+	return entries
+}
+
+// CollectListing 识别目录列表并解析其中的条目, 目录列表是高价值的结果, 不应与普通200页面混在一起
+func (bl *Baseline) CollectListing() {
+	if bl.Listing || len(bl.Body) == 0 || bl.ContentType != "html" || !ListingRegexp.Match(bl.Body) {
+		return
+	}
+	bl.Listing = true
+	bl.ListingEntries = ParseListing(bl.Body)
+	if len(bl.ListingEntries) != 0 {
+		bl.Extracteds = append(bl.Extracteds, &parsers.Extracted{
+			Name:          "listing",
+			ExtractResult: bl.ListingEntries,
+		})
+	}
+}
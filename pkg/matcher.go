@@ -0,0 +1,85 @@
+package pkg
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm"
+)
+
+const (
+	MatcherAnd = "and"
+	MatcherOr  = "or"
+)
+
+var matcherNameRegexp = regexp.MustCompile(`^(\w+):\s*(.+)$`)
+
+// Matcher 具名的expr表达式, 通过 name:expr 命名, 未命名时使用序号
+type Matcher struct {
+	Name    string
+	Expr    string
+	Program *vm.Program
+}
+
+// Matchers 多个--match/--filter表达式, 按声明顺序求值
+// and模式下全部为true才命中, or模式下任意一个为true即命中, 均会短路
+type Matchers struct {
+	Mode  string
+	Items []*Matcher
+}
+
+// CompileMatchers 编译多个表达式, 没有表达式时返回nil
+// e.g.: --match 'ok: current.Status == 200' --match 'current.Title contains "admin"'
+func CompileMatchers(exprs []string, mode string) (*Matchers, error) {
+	if len(exprs) == 0 {
+		return nil, nil
+	}
+	if mode == "" {
+		mode = MatcherOr
+	} else if mode != MatcherAnd && mode != MatcherOr {
+		return nil, fmt.Errorf("invalid matcher mode %s, only support and/or", mode)
+	}
+	m := &Matchers{Mode: mode}
+	for i, s := range exprs {
+		matcher := &Matcher{Name: strconv.Itoa(i), Expr: s}
+		if sub := matcherNameRegexp.FindStringSubmatch(s); sub != nil {
+			matcher.Name, matcher.Expr = sub[1], sub[2]
+		}
+		program, err := expr.Compile(matcher.Expr)
+		if err != nil {
+			return nil, fmt.Errorf("compile %s failed, %w", matcher.Name, err)
+		}
+		matcher.Program = program
+		m.Items = append(m.Items, matcher)
+	}
+	return m, nil
+}
+
+// Eval 返回是否命中以及决定结果的表达式名, or模式为第一个为true的表达式, and模式为全部表达式
+func (m *Matchers) Eval(params map[string]interface{}) (bool, string) {
+	var names []string
+	for _, matcher := range m.Items {
+		ok := CompareWithExpr(matcher.Program, params)
+		if m.Mode == MatcherOr && ok {
+			return true, matcher.Name
+		} else if m.Mode == MatcherAnd && !ok {
+			return false, matcher.Name
+		}
+		names = append(names, matcher.Name)
+	}
+	if m.Mode == MatcherOr {
+		return false, ""
+	}
+	return true, strings.Join(names, ",")
+}
+
+func (m *Matchers) String() string {
+	var s []string
+	for _, matcher := range m.Items {
+		s = append(s, matcher.Name+": "+matcher.Expr)
+	}
+	return strings.Join(s, " "+m.Mode+" ")
+}
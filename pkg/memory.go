@@ -0,0 +1,75 @@
+package pkg
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/chainreactors/logs"
+)
+
+var (
+	memoryPaused atomic.Bool
+	// MemoryPauseTimeout 内存压力下暂停读取新词的最长时间, 避免内存无法回落时任务卡死
+	MemoryPauseTimeout = 10 * time.Second
+)
+
+// ParseSize 解析 512M, 2G 形式的大小, 不带单位时为字节
+func ParseSize(s string) (uint64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	s = strings.TrimSuffix(s, "B")
+	var unit uint64 = 1
+	switch {
+	case strings.HasSuffix(s, "K"):
+		unit = 1 << 10
+	case strings.HasSuffix(s, "M"):
+		unit = 1 << 20
+	case strings.HasSuffix(s, "G"):
+		unit = 1 << 30
+	}
+	if unit != 1 {
+		s = s[:len(s)-1]
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid size %s", s)
+	}
+	return uint64(n * float64(unit)), nil
+}
+
+// MemoryGuard 监控进程内存, 接近上限时强制GC并暂停读取新词, 避免被OOM kill导致结果丢失
+func MemoryGuard(limit uint64) {
+	// 让runtime在接近上限时更积极的GC
+	debug.SetMemoryLimit(int64(limit))
+	high := limit * 9 / 10
+	go func() {
+		var stats runtime.MemStats
+		for {
+			runtime.ReadMemStats(&stats)
+			used := stats.HeapInuse + stats.StackInuse
+			if used > high {
+				if !memoryPaused.Load() {
+					logs.Log.Warnf("[memory] %dMB used, approached max memory %dMB, pause new words and force gc", used>>20, limit>>20)
+				}
+				memoryPaused.Store(true)
+				debug.FreeOSMemory()
+			} else if memoryPaused.Load() && used < limit*7/10 {
+				logs.Log.Infof("[memory] %dMB used, resume", used>>20)
+				memoryPaused.Store(false)
+			}
+			time.Sleep(time.Second)
+		}
+	}()
+}
+
+// WaitMemory 内存压力解除前阻塞, 最长等待MemoryPauseTimeout
+func WaitMemory() {
+	deadline := time.Now().Add(MemoryPauseTimeout)
+	for memoryPaused.Load() && time.Now().Before(deadline) {
+		time.Sleep(100 * time.Millisecond)
+	}
+}
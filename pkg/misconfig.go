@@ -0,0 +1,37 @@
+package pkg
+
+import (
+	"github.com/chainreactors/parsers"
+)
+
+// Misconfig 与路径无关的常见错误配置
+type Misconfig struct {
+	Name        string
+	Description string
+	Match       func(bl *Baseline) bool
+}
+
+var Misconfigs = []*Misconfig{
+	{"directory-listing", "directory listing enabled",
+		func(bl *Baseline) bool { return bl.Listing }},
+}
+
+// NewMisconfigExtracted 将错误配置记录为 "类型: 描述" 形式的结构化结果
+func NewMisconfigExtracted(name, description string) *parsers.Extracted {
+	return &parsers.Extracted{
+		Name:          "misconfig",
+		ExtractResult: []string{name + ": " + description},
+	}
+}
+
+// CollectMisconfig 检测命中的常见错误配置, 例如目录遍历, .git泄露, actuator未授权, .env可读等
+func (bl *Baseline) CollectMisconfig() {
+	if check := FindQuickCheck(bl.Path); check != nil && check.Match(bl) {
+		bl.Extracteds = append(bl.Extracteds, NewMisconfigExtracted(check.Name, check.Description))
+	}
+	for _, m := range Misconfigs {
+		if m.Match(bl) {
+			bl.Extracteds = append(bl.Extracteds, NewMisconfigExtracted(m.Name, m.Description))
+		}
+	}
+}
@@ -0,0 +1,420 @@
+package pkg
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// 结果文件的messagepack编码, 只需要覆盖json的数据模型, 字段名与omitempty遵循json tag, 与json格式可以互相转换, 不依赖额外的库
+
+// MsgpackEncode 按照json tag直接将结构体编码为messagepack, 实现了json.Marshaler的类型通过json中转
+func MsgpackEncode(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := msgpackEncodeValue(&buf, reflect.ValueOf(v)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+var jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+
+func msgpackEncodeValue(buf *bytes.Buffer, v reflect.Value) error {
+	if !v.IsValid() {
+		buf.WriteByte(0xc0)
+		return nil
+	}
+	if v.Type().Implements(jsonMarshalerType) && !(v.Kind() == reflect.Pointer && v.IsNil()) {
+		content, err := v.Interface().(json.Marshaler).MarshalJSON()
+		if err != nil {
+			return err
+		}
+		packed, err := JsonToMsgpack(content)
+		if err != nil {
+			return err
+		}
+		buf.Write(packed)
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			buf.WriteByte(0xc0)
+			return nil
+		}
+		return msgpackEncodeValue(buf, v.Elem())
+	case reflect.Bool:
+		return msgpackEncode(buf, v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		msgpackEncodeInt(buf, v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if u := v.Uint(); u <= math.MaxInt64 {
+			msgpackEncodeInt(buf, int64(u))
+		} else {
+			buf.WriteByte(0xcf)
+			buf.Write(binary.BigEndian.AppendUint64(nil, u))
+		}
+	case reflect.Float32, reflect.Float64:
+		buf.WriteByte(0xcb)
+		buf.Write(binary.BigEndian.AppendUint64(nil, math.Float64bits(v.Float())))
+	case reflect.String:
+		return msgpackEncode(buf, v.String())
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			buf.WriteByte(0xc0)
+			return nil
+		}
+		msgpackEncodeLength(buf, v.Len(), 0x90, 15, 0, 0xdc, 0xdd)
+		for i := 0; i < v.Len(); i++ {
+			if err := msgpackEncodeValue(buf, v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if v.IsNil() {
+			buf.WriteByte(0xc0)
+			return nil
+		}
+		keys := make([]string, 0, v.Len())
+		values := make(map[string]reflect.Value, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			k := fmt.Sprint(iter.Key().Interface())
+			keys = append(keys, k)
+			values[k] = iter.Value()
+		}
+		sort.Strings(keys)
+		msgpackEncodeLength(buf, len(keys), 0x80, 15, 0, 0xde, 0xdf)
+		for _, k := range keys {
+			msgpackEncode(buf, k)
+			if err := msgpackEncodeValue(buf, values[k]); err != nil {
+				return err
+			}
+		}
+	case reflect.Struct:
+		fields := msgpackFields(v, nil, 0, map[string]int{})
+		msgpackEncodeLength(buf, len(fields), 0x80, 15, 0, 0xde, 0xdf)
+		for _, f := range fields {
+			msgpackEncode(buf, f.name)
+			if err := msgpackEncodeValue(buf, f.value); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("msgpack unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+type msgpackField struct {
+	name  string
+	value reflect.Value
+	depth int
+}
+
+// msgpackFields 按照encoding/json的规则展开结构体字段, 匿名结构体的字段提升到外层, 同名时外层优先
+func msgpackFields(v reflect.Value, fields []msgpackField, depth int, index map[string]int) []msgpackField {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		fv := v.Field(i)
+		if sf.Anonymous && name == "" {
+			ft := sf.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+				if fv.IsNil() {
+					continue
+				}
+				fv = fv.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				fields = msgpackFields(fv, fields, depth+1, index)
+				continue
+			}
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		if strings.Contains(opts, "omitempty") && fv.IsZero() {
+			continue
+		}
+		if i, ok := index[name]; ok {
+			if fields[i].depth > depth {
+				fields[i] = msgpackField{name, fv, depth}
+			}
+			continue
+		}
+		index[name] = len(fields)
+		fields = append(fields, msgpackField{name, fv, depth})
+	}
+	return fields
+}
+
+// JsonToMsgpack 将单个json对象转换为messagepack
+func JsonToMsgpack(content []byte) ([]byte, error) {
+	var v interface{}
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.UseNumber()
+	if err := decoder.Decode(&v); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := msgpackEncode(&buf, v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func msgpackEncode(buf *bytes.Buffer, v interface{}) error {
+	switch v := v.(type) {
+	case nil:
+		buf.WriteByte(0xc0)
+	case bool:
+		if v {
+			buf.WriteByte(0xc3)
+		} else {
+			buf.WriteByte(0xc2)
+		}
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			msgpackEncodeInt(buf, i)
+		} else if f, err := v.Float64(); err == nil {
+			buf.WriteByte(0xcb)
+			buf.Write(binary.BigEndian.AppendUint64(nil, math.Float64bits(f)))
+		} else {
+			return err
+		}
+	case string:
+		msgpackEncodeLength(buf, len(v), 0xa0, 31, 0xd9, 0xda, 0xdb)
+		buf.WriteString(v)
+	case []interface{}:
+		msgpackEncodeLength(buf, len(v), 0x90, 15, 0, 0xdc, 0xdd)
+		for _, item := range v {
+			if err := msgpackEncode(buf, item); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		msgpackEncodeLength(buf, len(v), 0x80, 15, 0, 0xde, 0xdf)
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			msgpackEncode(buf, k)
+			if err := msgpackEncode(buf, v[k]); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("msgpack unsupported type %T", v)
+	}
+	return nil
+}
+
+func msgpackEncodeInt(buf *bytes.Buffer, i int64) {
+	switch {
+	case i >= 0 && i <= 127:
+		buf.WriteByte(byte(i))
+	case i < 0 && i >= -32:
+		buf.WriteByte(byte(int8(i)))
+	case i >= math.MinInt32 && i <= math.MaxInt32:
+		buf.WriteByte(0xd2)
+		buf.Write(binary.BigEndian.AppendUint32(nil, uint32(int32(i))))
+	default:
+		buf.WriteByte(0xd3)
+		buf.Write(binary.BigEndian.AppendUint64(nil, uint64(i)))
+	}
+}
+
+// msgpackEncodeLength 写入str/array/map的长度头, fixMax以内使用fix格式, code8为0表示该类型没有8位长度格式
+func msgpackEncodeLength(buf *bytes.Buffer, n int, fix byte, fixMax int, code8, code16, code32 byte) {
+	switch {
+	case n <= fixMax:
+		buf.WriteByte(fix | byte(n))
+	case code8 != 0 && n <= math.MaxUint8:
+		buf.WriteByte(code8)
+		buf.WriteByte(byte(n))
+	case n <= math.MaxUint16:
+		buf.WriteByte(code16)
+		buf.Write(binary.BigEndian.AppendUint16(nil, uint16(n)))
+	default:
+		buf.WriteByte(code32)
+		buf.Write(binary.BigEndian.AppendUint32(nil, uint32(n)))
+	}
+}
+
+// MsgpackDecoder 从连续写入的messagepack流中逐个读取对象
+type MsgpackDecoder struct {
+	r *bufio.Reader
+}
+
+func NewMsgpackDecoder(r io.Reader) *MsgpackDecoder {
+	return &MsgpackDecoder{r: bufio.NewReader(r)}
+}
+
+// DecodeJson 读取下一个对象并转换为json, 读取完毕时返回io.EOF
+func (d *MsgpackDecoder) DecodeJson() ([]byte, error) {
+	v, err := d.decode()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+func (d *MsgpackDecoder) readN(n int) ([]byte, error) {
+	b := make([]byte, n)
+	_, err := io.ReadFull(d.r, b)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return b, err
+}
+
+func (d *MsgpackDecoder) readUint(n int) (uint64, error) {
+	b, err := d.readN(n)
+	if err != nil {
+		return 0, err
+	}
+	var u uint64
+	for _, c := range b {
+		u = u<<8 | uint64(c)
+	}
+	return u, nil
+}
+
+func (d *MsgpackDecoder) decode() (interface{}, error) {
+	c, err := d.r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case c <= 0x7f:
+		return int64(c), nil
+	case c >= 0xe0:
+		return int64(int8(c)), nil
+	case c >= 0x80 && c <= 0x8f:
+		return d.decodeMap(int(c & 0x0f))
+	case c >= 0x90 && c <= 0x9f:
+		return d.decodeArray(int(c & 0x0f))
+	case c >= 0xa0 && c <= 0xbf:
+		return d.decodeString(int(c & 0x1f))
+	}
+
+	switch c {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xca:
+		u, err := d.readUint(4)
+		return float64(math.Float32frombits(uint32(u))), err
+	case 0xcb:
+		u, err := d.readUint(8)
+		return math.Float64frombits(u), err
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		u, err := d.readUint(1 << (c - 0xcc))
+		return u, err
+	case 0xd0:
+		u, err := d.readUint(1)
+		return int64(int8(u)), err
+	case 0xd1:
+		u, err := d.readUint(2)
+		return int64(int16(u)), err
+	case 0xd2:
+		u, err := d.readUint(4)
+		return int64(int32(u)), err
+	case 0xd3:
+		u, err := d.readUint(8)
+		return int64(u), err
+	case 0xd9, 0xda, 0xdb, 0xc4, 0xc5, 0xc6:
+		size := map[byte]int{0xd9: 1, 0xda: 2, 0xdb: 4, 0xc4: 1, 0xc5: 2, 0xc6: 4}[c]
+		n, err := d.readUint(size)
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeString(int(n))
+	case 0xdc, 0xdd:
+		n, err := d.readUint(2 << (c - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeArray(int(n))
+	case 0xde, 0xdf:
+		n, err := d.readUint(2 << (c - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeMap(int(n))
+	}
+	return nil, fmt.Errorf("msgpack unsupported format 0x%x", c)
+}
+
+func (d *MsgpackDecoder) decodeString(n int) (interface{}, error) {
+	b, err := d.readN(n)
+	return string(b), err
+}
+
+func (d *MsgpackDecoder) decodeArray(n int) (interface{}, error) {
+	arr := make([]interface{}, n)
+	for i := range arr {
+		v, err := d.decode()
+		if err != nil {
+			return nil, noEOF(err)
+		}
+		arr[i] = v
+	}
+	return arr, nil
+}
+
+func (d *MsgpackDecoder) decodeMap(n int) (interface{}, error) {
+	m := make(map[string]interface{}, n)
+	for i := 0; i < n; i++ {
+		k, err := d.decode()
+		if err != nil {
+			return nil, noEOF(err)
+		}
+		v, err := d.decode()
+		if err != nil {
+			return nil, noEOF(err)
+		}
+		m[fmt.Sprint(k)] = v
+	}
+	return m, nil
+}
+
+// noEOF 对象内部读到结尾说明数据被截断
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// IsMsgpack 通过首字节判断结果文件是否为messagepack格式, 结果总是map
+func IsMsgpack(content []byte) bool {
+	if len(content) == 0 {
+		return false
+	}
+	c := content[0]
+	return (c >= 0x80 && c <= 0x8f) || c == 0xde || c == 0xdf
+}
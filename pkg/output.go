@@ -0,0 +1,34 @@
+package pkg
+
+import (
+	"bytes"
+	"strings"
+	"text/template"
+)
+
+// IsOutputTemplate --format中包含{{时视为输出模板, 否则为需要重新格式化的结果文件
+func IsOutputTemplate(s string) bool {
+	return strings.Contains(s, "{{")
+}
+
+// NewOutputTemplate 以go template自定义输出行, 模板数据为Baseline, e.g.: {{.Status}} {{.Url}} {{.Length}} {{.Title}}
+func NewOutputTemplate(text string) (*template.Template, error) {
+	return template.New("output").Funcs(template.FuncMap{
+		"join":  strings.Join,
+		"upper": strings.ToUpper,
+		"lower": strings.ToLower,
+	}).Parse(text)
+}
+
+// Length 模板中 {{.Length}} 的简写
+func (bl *Baseline) Length() int {
+	return bl.BodyLength
+}
+
+func (bl *Baseline) TemplateOutput(t *template.Template) string {
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, bl); err != nil {
+		return err.Error()
+	}
+	return buf.String()
+}
@@ -0,0 +1,103 @@
+package pkg
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var slotRegexp = regexp.MustCompile(`\{(\w+)\}`)
+
+// PathTemplate 目标自带的路径模板, 例如 http://host/api/{word}/v1/{id}, 每个插槽使用独立的字典
+type PathTemplate struct {
+	Raw   string
+	parts []string // 模板按插槽切分后的固定部分, 长度为len(slots)+1
+	slots []string
+	words [][]string
+}
+
+func IsPathTemplate(s string) bool {
+	return slotRegexp.MatchString(s)
+}
+
+// SplitPathTemplate 将带插槽的目标拆分为baseurl与路径模板
+func SplitPathTemplate(raw string) (string, string) {
+	i := strings.Index(raw, "://")
+	start := 0
+	if i != -1 {
+		start = i + 3
+	}
+	j := strings.Index(raw[start:], "/")
+	if j == -1 {
+		return raw, ""
+	}
+	return raw[:start+j], raw[start+j:]
+}
+
+func NewPathTemplate(tpl string) *PathTemplate {
+	t := &PathTemplate{Raw: tpl}
+	last := 0
+	for _, loc := range slotRegexp.FindAllStringSubmatchIndex(tpl, -1) {
+		t.parts = append(t.parts, tpl[last:loc[0]])
+		t.slots = append(t.slots, tpl[loc[2]:loc[3]])
+		last = loc[1]
+	}
+	t.parts = append(t.parts, tpl[last:])
+	return t
+}
+
+// Compile 为每个插槽绑定字典, 缺少字典的插槽将返回错误
+func (t *PathTemplate) Compile(dicts map[string][]string) error {
+	t.words = make([][]string, len(t.slots))
+	for i, slot := range t.slots {
+		ws, ok := dicts[slot]
+		if !ok {
+			return fmt.Errorf("path template %s, slot {%s} has no dictionary", t.Raw, slot)
+		}
+		t.words[i] = ws
+	}
+	return nil
+}
+
+func (t *PathTemplate) Count() int {
+	count := 1
+	for _, ws := range t.words {
+		count *= len(ws)
+	}
+	return count
+}
+
+// Generate 按笛卡尔积逐个生成路径, 不会一次性展开到内存中
+func (t *PathTemplate) Generate() chan string {
+	ch := make(chan string)
+	go func() {
+		defer close(ch)
+		if t.Count() == 0 {
+			return
+		}
+		index := make([]int, len(t.words))
+		for {
+			var sb strings.Builder
+			for i, ws := range t.words {
+				sb.WriteString(t.parts[i])
+				sb.WriteString(ws[index[i]])
+			}
+			sb.WriteString(t.parts[len(t.parts)-1])
+			ch <- sb.String()
+
+			// 末尾的插槽变化最快
+			i := len(index) - 1
+			for ; i >= 0; i-- {
+				index[i]++
+				if index[i] < len(t.words[i]) {
+					break
+				}
+				index[i] = 0
+			}
+			if i < 0 {
+				return
+			}
+		}
+	}()
+	return ch
+}
@@ -0,0 +1,140 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/chainreactors/spray/internal/ihttp"
+)
+
+const (
+	ClusterBomb = "clusterbomb"
+	Pitchfork   = "pitchfork"
+	Sniper      = "sniper"
+)
+
+// PayloadSet 多位置关键字fuzz, 每个关键字使用独立的字典
+//
+//	clusterbomb 所有字典的笛卡尔积
+//	pitchfork 按行同时取每个字典的第i个word, 以最短的字典为准
+//	sniper 每次只替换一个关键字, 其他关键字使用各自字典的第一个word作为基准值
+type PayloadSet struct {
+	Keywords []string
+	Mode     string
+	words    [][]string
+}
+
+func NewPayloadSet(dicts map[string][]string, mode string) (*PayloadSet, error) {
+	switch mode {
+	case ClusterBomb, Pitchfork, Sniper:
+	case "":
+		mode = ClusterBomb
+	default:
+		return nil, fmt.Errorf("unknown payload mode %s, support clusterbomb, pitchfork, sniper", mode)
+	}
+	p := &PayloadSet{Mode: mode}
+	for k := range dicts {
+		p.Keywords = append(p.Keywords, k)
+	}
+	sort.Strings(p.Keywords)
+	for _, k := range p.Keywords {
+		if len(dicts[k]) == 0 {
+			return nil, fmt.Errorf("payload %s has empty dictionary", k)
+		}
+		p.words = append(p.words, dicts[k])
+	}
+	return p, nil
+}
+
+func (p *PayloadSet) Count() int {
+	switch p.Mode {
+	case Pitchfork:
+		count := len(p.words[0])
+		for _, ws := range p.words {
+			count = min(count, len(ws))
+		}
+		return count
+	case Sniper:
+		count := 1
+		for _, ws := range p.words {
+			count += len(ws) - 1
+		}
+		return count
+	default:
+		count := 1
+		for _, ws := range p.words {
+			count *= len(ws)
+		}
+		return count
+	}
+}
+
+// Generate 逐个生成拼接后的payload, 不会一次性展开到内存中, ctx结束后停止生成, 避免消费者提前退出时goroutine泄露
+func (p *PayloadSet) Generate(ctx context.Context) chan string {
+	ch := make(chan string)
+	send := func(values []string) bool {
+		select {
+		case ch <- strings.Join(values, ihttp.PayloadSep):
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+	go func() {
+		defer close(ch)
+		values := make([]string, len(p.words))
+		switch p.Mode {
+		case Pitchfork:
+			for i := 0; i < p.Count(); i++ {
+				for j, ws := range p.words {
+					values[j] = ws[i]
+				}
+				if !send(values) {
+					return
+				}
+			}
+		case Sniper:
+			for i, ws := range p.words {
+				for j := range p.words {
+					values[j] = p.words[j][0]
+				}
+				for j, w := range ws {
+					if i > 0 && j == 0 {
+						// 全部为第一个word的组合已经生成过
+						continue
+					}
+					values[i] = w
+					if !send(values) {
+						return
+					}
+				}
+			}
+		default:
+			index := make([]int, len(p.words))
+			for {
+				for j, ws := range p.words {
+					values[j] = ws[index[j]]
+				}
+				if !send(values) {
+					return
+				}
+
+				// 末尾的关键字变化最快
+				i := len(index) - 1
+				for ; i >= 0; i-- {
+					index[i]++
+					if index[i] < len(p.words[i]) {
+						break
+					}
+					index[i] = 0
+				}
+				if i < 0 {
+					return
+				}
+			}
+		}
+	}()
+	return ch
+}
@@ -0,0 +1,83 @@
+package pkg
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+)
+
+// QuickCheck 快速扫描的高价值路径, 每个路径有独立的匹配逻辑
+type QuickCheck struct {
+	Name        string
+	Path        string
+	Description string
+	Match       func(bl *Baseline) bool
+}
+
+func bodyContains(keywords ...string) func(bl *Baseline) bool {
+	return func(bl *Baseline) bool {
+		if bl.Status != 200 {
+			return false
+		}
+		for _, k := range keywords {
+			if bytes.Contains(bl.Body, []byte(k)) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func bodyMatch(reg *regexp.Regexp) func(bl *Baseline) bool {
+	return func(bl *Baseline) bool {
+		return bl.Status == 200 && reg.Match(bl.Body)
+	}
+}
+
+var QuickChecks = []*QuickCheck{
+	{"git", ".git/HEAD", "exposed git repository, source code may be restored", bodyContains("ref: refs/")},
+	{"git", ".git/config", "exposed git repository config", bodyContains("[core]")},
+	{"svn", ".svn/entries", "exposed svn metadata, source code may be restored", bodyMatch(regexp.MustCompile(`^\d+\s`))},
+	{"env", ".env", "readable .env file, may leak credentials", bodyMatch(regexp.MustCompile(`(?m)^[A-Z_][A-Z0-9_]*=`))},
+	{"ds_store", ".DS_Store", "exposed .DS_Store, leak directory structure", bodyContains("Bud1")},
+	{"actuator", "actuator", "open spring boot actuator endpoints", bodyContains(`"_links"`)},
+	{"actuator", "actuator/env", "open spring boot actuator env, may leak credentials", bodyContains("activeProfiles", "propertySources")},
+	{"actuator", "actuator/heapdump", "downloadable spring boot heapdump, may leak credentials", func(bl *Baseline) bool {
+		return bl.Status == 200 && (bl.ContentType == "bin" || bytes.HasPrefix(bl.Body, []byte("JAVA PROFILE")))
+	}},
+	{"actuator", "env", "open spring boot env endpoint, may leak credentials", bodyContains("activeProfiles", "propertySources")},
+	{"swagger", "swagger.json", "exposed api documentation", bodyContains(`"swagger"`, `"openapi"`)},
+	{"swagger", "v2/api-docs", "exposed api documentation", bodyContains(`"swagger"`)},
+	{"swagger", "v3/api-docs", "exposed api documentation", bodyContains(`"openapi"`)},
+	{"swagger", "openapi.json", "exposed api documentation", bodyContains(`"openapi"`)},
+	{"swagger", "swagger-ui.html", "exposed swagger ui", bodyContains("swagger-ui", "Swagger UI")},
+	{"server-status", "server-status", "open apache server-status", bodyContains("Apache Server Status")},
+	{"server-info", "server-info", "open apache server-info", bodyContains("Apache Server Information")},
+	{"nginx-status", "nginx_status", "open nginx stub_status", bodyContains("Active connections")},
+	{"debug", "debug/pprof/", "open go pprof debug endpoint", bodyContains("/debug/pprof/", "Types of profiles available")},
+	{"debug", "debug/vars", "open go expvar debug endpoint", bodyContains(`"memstats"`, `"cmdline"`)},
+	{"phpinfo", "phpinfo.php", "exposed phpinfo page", bodyContains("phpinfo()", "PHP Version")},
+	{"druid", "druid/index.html", "unauthorized druid monitor console", bodyContains("Druid Stat Index")},
+	{"jolokia", "jolokia/list", "open jolokia endpoint", bodyContains(`"agent"`, `"jolokia"`)},
+	{"web-inf", "WEB-INF/web.xml", "readable WEB-INF/web.xml", bodyContains("<web-app")},
+	{"crossdomain", "crossdomain.xml", "permissive crossdomain.xml policy", bodyContains(`domain="*"`)},
+}
+
+// QuickPaths 快速扫描的路径列表, 作为字典使用
+func QuickPaths() []string {
+	paths := make([]string, len(QuickChecks))
+	for i, c := range QuickChecks {
+		paths[i] = c.Path
+	}
+	return paths
+}
+
+// FindQuickCheck 根据路径找到对应的检查项, 非quick路径返回nil
+func FindQuickCheck(path string) *QuickCheck {
+	for _, c := range QuickChecks {
+		if strings.HasSuffix(path, "/"+c.Path) {
+			return c
+		}
+	}
+	return nil
+}
@@ -0,0 +1,222 @@
+package pkg
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NumberRanges 数值区间列表, 支持逗号分隔的单值与区间, e.g.: 0,100-200,1000-
+type NumberRanges [][2]int
+
+func ParseNumberRanges(s string) (NumberRanges, error) {
+	var ranges NumberRanges
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		lo, hi := part, part
+		if i := strings.Index(part, "-"); i != -1 {
+			lo, hi = part[:i], part[i+1:]
+		}
+		var r [2]int
+		var err error
+		if lo == "" {
+			r[0] = 0
+		} else if r[0], err = strconv.Atoi(strings.TrimSpace(lo)); err != nil {
+			return nil, fmt.Errorf("invalid range %q", part)
+		}
+		if hi == "" {
+			r[1] = -1 // 无上限
+		} else if r[1], err = strconv.Atoi(strings.TrimSpace(hi)); err != nil {
+			return nil, fmt.Errorf("invalid range %q", part)
+		}
+		if r[1] != -1 && r[1] < r[0] {
+			return nil, fmt.Errorf("invalid range %q", part)
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges, nil
+}
+
+func (nr NumberRanges) Contains(n int) bool {
+	for _, r := range nr {
+		if n >= r[0] && (r[1] == -1 || n <= r[1]) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseTimeRanges 解析响应耗时条件, 转换为毫秒区间, 不带单位时视为毫秒
+// e.g.: >500ms, <=1s, 200ms-2s, >1s,<50ms
+func ParseTimeRanges(s string) (NumberRanges, error) {
+	var ranges NumberRanges
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		var r [2]int
+		var err error
+		switch {
+		case strings.HasPrefix(part, ">="):
+			r[0], err = parseMillis(part[2:])
+			r[1] = -1
+		case strings.HasPrefix(part, ">"):
+			r[0], err = parseMillis(part[1:])
+			r[0]++
+			r[1] = -1
+		case strings.HasPrefix(part, "<="):
+			r[1], err = parseMillis(part[2:])
+		case strings.HasPrefix(part, "<"):
+			if r[1], err = parseMillis(part[1:]); err == nil && r[1] <= 0 {
+				// 没有满足<0的耗时, 减一后也会与表示无上限的-1混淆
+				err = fmt.Errorf("no duration less than %d", r[1])
+			}
+			r[1]--
+		case strings.Contains(part, "-"):
+			i := strings.Index(part, "-")
+			if r[0], err = parseMillis(part[:i]); err == nil {
+				r[1], err = parseMillis(part[i+1:])
+			}
+		default:
+			r[0], err = parseMillis(part)
+			r[1] = r[0]
+		}
+		if err != nil || r[0] < 0 || (r[1] != -1 && r[1] < r[0]) {
+			return nil, fmt.Errorf("invalid time range %q", part)
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges, nil
+}
+
+func parseMillis(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	if i, err := strconv.Atoi(s); err == nil {
+		return i, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	return int(d.Milliseconds()), nil
+}
+
+// MaxRegexBodySize 正则只匹配body的前1MB, 避免超大响应拖慢处理
+const MaxRegexBodySize = 1 << 20
+
+// ResponseFilter --match-size/words/lines/regex/time与--filter-size/words/lines/regex/time
+// 设置的match条件需要全部满足, 命中任意一个filter条件即被过滤
+type ResponseFilter struct {
+	MatchSize   NumberRanges
+	MatchWords  NumberRanges
+	MatchLines  NumberRanges
+	MatchRegex  *regexp.Regexp
+	MatchTime   NumberRanges // 毫秒
+	FilterSize  NumberRanges
+	FilterWords NumberRanges
+	FilterLines NumberRanges
+	FilterRegex *regexp.Regexp
+	FilterTime  NumberRanges
+}
+
+// NewResponseFilter 参数依次为size, words, lines, regex, time的match与filter, 全部为空时返回nil
+func NewResponseFilter(match, filter [5]string) (*ResponseFilter, error) {
+	f := &ResponseFilter{}
+	targets := []*NumberRanges{&f.MatchSize, &f.MatchWords, &f.MatchLines, &f.FilterSize, &f.FilterWords, &f.FilterLines}
+	empty := true
+	for i, s := range append(match[:3], filter[:3]...) {
+		if s == "" {
+			continue
+		}
+		r, err := ParseNumberRanges(s)
+		if err != nil {
+			return nil, err
+		}
+		*targets[i] = r
+		empty = false
+	}
+	var err error
+	if match[3] != "" {
+		if f.MatchRegex, err = regexp.Compile(match[3]); err != nil {
+			return nil, fmt.Errorf("invalid match regex, %w", err)
+		}
+		empty = false
+	}
+	if filter[3] != "" {
+		if f.FilterRegex, err = regexp.Compile(filter[3]); err != nil {
+			return nil, fmt.Errorf("invalid filter regex, %w", err)
+		}
+		empty = false
+	}
+	if match[4] != "" {
+		if f.MatchTime, err = ParseTimeRanges(match[4]); err != nil {
+			return nil, err
+		}
+		empty = false
+	}
+	if filter[4] != "" {
+		if f.FilterTime, err = ParseTimeRanges(filter[4]); err != nil {
+			return nil, err
+		}
+		empty = false
+	}
+	if empty {
+		return nil, nil
+	}
+	return f, nil
+}
+
+func (f *ResponseFilter) HasMatch() bool {
+	return f != nil && (f.MatchSize != nil || f.MatchWords != nil || f.MatchLines != nil || f.MatchRegex != nil || f.MatchTime != nil)
+}
+
+func regexBody(bl *Baseline) []byte {
+	if len(bl.Body) > MaxRegexBodySize {
+		return bl.Body[:MaxRegexBodySize]
+	}
+	return bl.Body
+}
+
+func (f *ResponseFilter) Match(bl *Baseline) bool {
+	if f.MatchSize != nil && !f.MatchSize.Contains(bl.BodyLength) {
+		return false
+	}
+	if f.MatchWords != nil && !f.MatchWords.Contains(bl.Words) {
+		return false
+	}
+	if f.MatchLines != nil && !f.MatchLines.Contains(bl.Lines) {
+		return false
+	}
+	if f.MatchRegex != nil && !f.MatchRegex.Match(regexBody(bl)) {
+		return false
+	}
+	if f.MatchTime != nil && !f.MatchTime.Contains(int(bl.Spended)) {
+		return false
+	}
+	return true
+}
+
+func (f *ResponseFilter) Filter(bl *Baseline) bool {
+	if f == nil {
+		return false
+	}
+	if f.FilterSize.Contains(bl.BodyLength) || f.FilterWords.Contains(bl.Words) || f.FilterLines.Contains(bl.Lines) || f.FilterTime.Contains(int(bl.Spended)) {
+		return true
+	}
+	return f.FilterRegex != nil && f.FilterRegex.Match(regexBody(bl))
+}
+
+// CountWords 统计body的单词数与行数, 空body均为0
+func CountWords(body []byte) (int, int) {
+	if len(body) == 0 {
+		return 0, 0
+	}
+	return len(bytes.Fields(body)), bytes.Count(body, []byte("\n")) + 1
+}
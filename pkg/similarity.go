@@ -0,0 +1,140 @@
+package pkg
+
+import (
+	"bytes"
+	"unicode"
+
+	"github.com/glaslos/ssdeep"
+)
+
+// Levenshtein 计算两段内容的编辑距离, 只保留两行dp, 内存占用为O(min(n,m))
+func Levenshtein(a, b []byte) int {
+	if len(a) < len(b) {
+		a, b = b, a
+	}
+	if len(b) == 0 {
+		return len(a)
+	}
+
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+// SimilarityRatio 基于编辑距离的相似度, 1为完全一致, 0为完全不同
+func SimilarityRatio(a, b []byte) float64 {
+	longer := max(len(a), len(b))
+	if longer == 0 {
+		return 1
+	}
+	return 1 - float64(Levenshtein(a, b))/float64(longer)
+}
+
+const (
+	SimilaritySimhash = "simhash"
+	SimilaritySsdeep  = "ssdeep"
+	SimilarityTLSH    = "tlsh"
+	SimilarityJaccard = "jaccard"
+)
+
+var (
+	SimilarityAlgo = SimilaritySimhash // --similarity, fuzzy compare使用的算法
+	TLSHDistance   = 50                // tlsh距离阈值, 数字越小越相似
+)
+
+func init() {
+	// 404页面通常小于ssdeep要求的4096字节, 强制计算
+	ssdeep.Force = true
+}
+
+// Similarity 判断两个响应是否为同一类页面, Hash的结果会缓存在Baseline中, 避免baseline被重复计算
+type Similarity interface {
+	Hash(body []byte) interface{}
+	Similar(a, b interface{}) bool
+}
+
+var Similarities = map[string]Similarity{
+	SimilaritySsdeep:  ssdeepSimilarity{},
+	SimilarityTLSH:    tlshSimilarity{},
+	SimilarityJaccard: jaccardSimilarity{},
+}
+
+type ssdeepSimilarity struct{}
+
+func (ssdeepSimilarity) Hash(body []byte) interface{} {
+	h, err := ssdeep.FuzzyBytes(body)
+	if err != nil {
+		return nil
+	}
+	return h
+}
+
+// Similar ssdeep的匹配分数为0-100
+func (ssdeepSimilarity) Similar(a, b interface{}) bool {
+	score, err := ssdeep.Distance(a.(string), b.(string))
+	if err != nil {
+		return false
+	}
+	return float64(score)/100 >= Ratio
+}
+
+type tlshSimilarity struct{}
+
+func (tlshSimilarity) Hash(body []byte) interface{} {
+	if h := NewTLSH(body); h != nil {
+		return h
+	}
+	return nil
+}
+
+func (tlshSimilarity) Similar(a, b interface{}) bool {
+	return a.(*TLSH).Diff(b.(*TLSH)) <= TLSHDistance
+}
+
+// jaccardSimilarity 以token集合的交并比衡量相似度, 对模板中随机token, 时间戳等少量差异不敏感
+type jaccardSimilarity struct{}
+
+func (jaccardSimilarity) Hash(body []byte) interface{} {
+	set := make(map[string]struct{})
+	for _, token := range bytes.FieldsFunc(body, isTokenSep) {
+		set[string(token)] = struct{}{}
+	}
+	return set
+}
+
+func (jaccardSimilarity) Similar(a, b interface{}) bool {
+	return Jaccard(a.(map[string]struct{}), b.(map[string]struct{})) >= Ratio
+}
+
+func isTokenSep(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' && r != '-'
+}
+
+// Jaccard 两个集合的交集与并集之比, 均为空时视为完全一致
+func Jaccard(a, b map[string]struct{}) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 1
+	}
+	var inter int
+	for k := range a {
+		if _, ok := b[k]; ok {
+			inter++
+		}
+	}
+	return float64(inter) / float64(len(a)+len(b)-inter)
+}
@@ -0,0 +1,52 @@
+package pkg
+
+import (
+	"strings"
+
+	"github.com/chainreactors/parsers"
+)
+
+// spray自身定义的source, 接在parsers定义的source之后, parsers的Name()不认识这些source, 输出时使用SourceName
+const (
+	MethodSource parsers.SpraySource = parsers.AppendRuleSource + iota + 1
+	BypassSource
+	NucleiSource
+)
+
+var sourceNames = map[parsers.SpraySource]string{
+	MethodSource: "method",
+	BypassSource: "bypass",
+	NucleiSource: "nuclei",
+}
+
+// SourceName 兼容spray自定义source的名称
+func SourceName(s parsers.SpraySource) string {
+	if name, ok := sourceNames[s]; ok {
+		return name
+	}
+	return s.Name()
+}
+
+// renameSource SprayResult的String与ColorString使用parsers的Name(), 将自定义source的"[unknown]"替换为实际名称
+func (bl *Baseline) renameSource(line string) string {
+	name, ok := sourceNames[bl.Source]
+	if !ok {
+		return line
+	}
+	return strings.Replace(line, padding("["+bl.Source.Name()+"]", 10), padding("["+name+"]", 10), 1)
+}
+
+func (bl *Baseline) String() string {
+	return bl.renameSource(bl.SprayResult.String())
+}
+
+func (bl *Baseline) ColorString() string {
+	return bl.renameSource(bl.SprayResult.ColorString())
+}
+
+func padding(s string, size int) string {
+	if len(s) >= size {
+		return s
+	}
+	return s + strings.Repeat(" ", size-len(s))
+}
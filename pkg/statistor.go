@@ -4,11 +4,14 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"github.com/chainreactors/fingers/common"
 	"github.com/chainreactors/logs"
 	"github.com/chainreactors/parsers"
+	"github.com/chainreactors/utils/iutils"
 	"io/ioutil"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 )
 
@@ -28,9 +31,13 @@ func NewStatistorFromStat(origin *Statistor) *Statistor {
 		BaseUrl:      origin.BaseUrl,
 		Word:         origin.Word,
 		Dictionaries: origin.Dictionaries,
-		Offset:       origin.End,
+		Offset:       int(origin.End),
 		RuleFiles:    origin.RuleFiles,
 		RuleFilter:   origin.RuleFilter,
+		RuleSyntax:   origin.RuleSyntax,
+		Depth:        origin.Depth,
+		InputHash:    origin.InputHash,
+		WordOrder:    origin.WordOrder,
 		Counts:       make(map[int]int),
 		Sources:      map[parsers.SpraySource]int{},
 		StartTime:    time.Now().Unix(),
@@ -40,16 +47,22 @@ func NewStatistorFromStat(origin *Statistor) *Statistor {
 type Statistor struct {
 	BaseUrl        string                      `json:"url"`
 	Error          string                      `json:"error"`
+	Precheck       string                      `json:"precheck,omitempty"`
+	Waf            string                      `json:"waf,omitempty"`
+	FaviconMmh3    string                      `json:"favicon_mmh3,omitempty"`
+	FaviconMd5     string                      `json:"favicon_md5,omitempty"`
+	Frameworks     []string                    `json:"frameworks,omitempty"` // 目标上识别到的所有指纹
 	Counts         map[int]int                 `json:"counts"`
 	Sources        map[parsers.SpraySource]int `json:"sources"`
 	FailedNumber   int32                       `json:"failed"`
+	TLSFailed      int32                       `json:"tls_failed"`
 	ReqTotal       int32                       `json:"req_total"`
 	CheckNumber    int                         `json:"check"`
-	FoundNumber    int                         `json:"found"`
+	FoundNumber    int64                       `json:"found"`
 	FilteredNumber int                         `json:"filtered"`
 	FuzzyNumber    int                         `json:"fuzzy"`
 	WafedNumber    int                         `json:"wafed"`
-	End            int                         `json:"end"`
+	End            int64                       `json:"end"`
 	Skipped        int                         `json:"skipped"`
 	Offset         int                         `json:"offset"`
 	Total          int                         `json:"total"`
@@ -60,6 +73,10 @@ type Statistor struct {
 	Dictionaries   []string                    `json:"dictionaries"`
 	RuleFiles      []string                    `json:"rule_files"`
 	RuleFilter     string                      `json:"rule_filter"`
+	RuleSyntax     string                      `json:"rule_syntax,omitempty"`
+	Depth          int                         `json:"depth,omitempty"` // 递归深度, 输入的目标为0
+	InputHash      string                      `json:"input_hash,omitempty"`
+	WordOrder
 }
 
 func (stat *Statistor) ColorString() string {
@@ -68,13 +85,16 @@ func (stat *Statistor) ColorString() string {
 		logs.GreenLine(stat.BaseUrl),
 		stat.EndTime-stat.StartTime,
 		logs.YellowBold(strconv.Itoa(int(stat.ReqTotal))),
-		logs.YellowBold(strconv.Itoa(stat.End)),
+		logs.YellowBold(strconv.FormatInt(stat.End, 10)),
 		logs.YellowBold(strconv.Itoa(stat.Total)),
 		logs.YellowLine(strconv.Itoa(stat.Skipped)),
-		logs.YellowBold(strconv.Itoa(stat.FoundNumber)),
+		logs.YellowBold(strconv.FormatInt(stat.FoundNumber, 10)),
 		logs.YellowBold(strconv.Itoa(stat.CheckNumber)),
 		logs.YellowBold(strconv.Itoa(int(stat.FailedNumber)))))
 
+	if stat.TLSFailed != 0 {
+		s.WriteString(", tls failed: " + logs.Yellow(strconv.Itoa(int(stat.TLSFailed))))
+	}
 	if stat.FuzzyNumber != 0 {
 		s.WriteString(", fuzzy: " + logs.Yellow(strconv.Itoa(stat.FuzzyNumber)))
 	}
@@ -84,6 +104,15 @@ func (stat *Statistor) ColorString() string {
 	if stat.WafedNumber != 0 {
 		s.WriteString(", wafed: " + logs.Yellow(strconv.Itoa(stat.WafedNumber)))
 	}
+	if stat.Waf != "" {
+		s.WriteString(", waf: " + logs.Yellow(stat.Waf))
+	}
+	if stat.FaviconMmh3 != "" {
+		s.WriteString(", favicon: " + logs.Yellow(stat.FaviconMmh3))
+	}
+	if len(stat.Frameworks) != 0 {
+		s.WriteString(", frameworks: " + logs.Yellow(strings.Join(stat.Frameworks, ",")))
+	}
 	return s.String()
 }
 func (stat *Statistor) String() string {
@@ -99,6 +128,9 @@ func (stat *Statistor) String() string {
 		stat.CheckNumber,
 		stat.FailedNumber))
 
+	if stat.TLSFailed != 0 {
+		s.WriteString(", tls failed: " + strconv.Itoa(int(stat.TLSFailed)))
+	}
 	if stat.FuzzyNumber != 0 {
 		s.WriteString(", fuzzy: " + strconv.Itoa(stat.FuzzyNumber))
 	}
@@ -108,9 +140,64 @@ func (stat *Statistor) String() string {
 	if stat.WafedNumber != 0 {
 		s.WriteString(", wafed: " + strconv.Itoa(stat.WafedNumber))
 	}
+	if stat.Waf != "" {
+		s.WriteString(", waf: " + stat.Waf)
+	}
+	if stat.FaviconMmh3 != "" {
+		s.WriteString(", favicon: " + stat.FaviconMmh3)
+	}
+	if len(stat.Frameworks) != 0 {
+		s.WriteString(", frameworks: " + strings.Join(stat.Frameworks, ","))
+	}
 	return s.String()
 }
 
+// AddFrameworks 汇总结果中的指纹, 去重后按出现顺序记录
+func (stat *Statistor) AddFrameworks(fs common.Frameworks) {
+	for _, name := range fs.GetNames() {
+		if !iutils.StringsContains(stat.Frameworks, name) {
+			stat.Frameworks = append(stat.Frameworks, name)
+		}
+	}
+}
+
+// Merge 合并同一目标不同字典分片的stat, 用于分布式扫描时master汇总worker的结果
+func (stat *Statistor) Merge(other *Statistor) {
+	stat.FailedNumber += other.FailedNumber
+	stat.TLSFailed += other.TLSFailed
+	stat.ReqTotal += other.ReqTotal
+	stat.CheckNumber += other.CheckNumber
+	stat.FoundNumber += other.FoundNumber
+	stat.FilteredNumber += other.FilteredNumber
+	stat.FuzzyNumber += other.FuzzyNumber
+	stat.WafedNumber += other.WafedNumber
+	stat.End += other.End
+	stat.Skipped += other.Skipped
+	for k, v := range other.Counts {
+		stat.Counts[k] += v
+	}
+	for k, v := range other.Sources {
+		stat.Sources[k] += v
+	}
+	if stat.Error == "" {
+		stat.Error = other.Error
+	}
+	if stat.Waf == "" {
+		stat.Waf = other.Waf
+	}
+	if stat.FaviconMmh3 == "" {
+		stat.FaviconMmh3, stat.FaviconMd5 = other.FaviconMmh3, other.FaviconMd5
+	}
+	for _, name := range other.Frameworks {
+		if !iutils.StringsContains(stat.Frameworks, name) {
+			stat.Frameworks = append(stat.Frameworks, name)
+		}
+	}
+	if other.EndTime > stat.EndTime {
+		stat.EndTime = other.EndTime
+	}
+}
+
 func (stat *Statistor) CountString() string {
 	if len(stat.Counts) == 0 {
 		return ""
@@ -135,7 +222,7 @@ func (stat *Statistor) SourceString() string {
 	s.WriteString("[stat] ")
 	s.WriteString(stat.BaseUrl)
 	for k, v := range stat.Sources {
-		s.WriteString(fmt.Sprintf(" %s: %d,", k.Name(), v))
+		s.WriteString(fmt.Sprintf(" %s: %d,", SourceName(k), v))
 	}
 	return s.String()
 }
@@ -162,7 +249,7 @@ func (stat *Statistor) ColorSourceString() string {
 	var s strings.Builder
 	s.WriteString(fmt.Sprintf("[stat] %s ", stat.BaseUrl))
 	for k, v := range stat.Sources {
-		s.WriteString(fmt.Sprintf(" %s: %s,", logs.Cyan(k.Name()), logs.YellowBold(strconv.Itoa(v))))
+		s.WriteString(fmt.Sprintf(" %s: %s,", logs.Cyan(SourceName(k)), logs.YellowBold(strconv.Itoa(v))))
 	}
 	return s.String()
 }
@@ -175,6 +262,55 @@ func (stat *Statistor) Json() string {
 	return string(content) + "\n"
 }
 
+// Progress 正在运行的任务的进度副本, 不包含运行过程中会被并发修改的map与slice, 用于checkpoint与退出前的快照
+func (stat *Statistor) Progress() *Statistor {
+	return &Statistor{
+		BaseUrl:      stat.BaseUrl,
+		Error:        stat.Error,
+		Waf:          stat.Waf,
+		FailedNumber: atomic.LoadInt32(&stat.FailedNumber),
+		ReqTotal:     atomic.LoadInt32(&stat.ReqTotal),
+		CheckNumber:  stat.CheckNumber,
+		FoundNumber:  atomic.LoadInt64(&stat.FoundNumber),
+		End:          atomic.LoadInt64(&stat.End),
+		Skipped:      stat.Skipped,
+		Offset:       stat.Offset,
+		Total:        stat.Total,
+		StartTime:    stat.StartTime,
+		EndTime:      time.Now().Unix(),
+		WordCount:    stat.WordCount,
+		Word:         stat.Word,
+		Dictionaries: stat.Dictionaries,
+		RuleFiles:    stat.RuleFiles,
+		RuleFilter:   stat.RuleFilter,
+		RuleSyntax:   stat.RuleSyntax,
+		Depth:        stat.Depth,
+		InputHash:    stat.InputHash,
+		WordOrder:    stat.WordOrder,
+	}
+}
+
+// CheckInput 检查stat中记录的word, 字典与规则当前生成的内容是否与扫描时一致, 不一致时续传的offset会对应到错误的位置
+func (stat *Statistor) CheckInput() error {
+	if stat.InputHash == "" {
+		// 旧版本的stat文件没有记录hash
+		return nil
+	}
+	gen, err := LoadWordGenerator(stat.Word, stat.Dictionaries)
+	if err != nil {
+		return err
+	}
+	rules, err := LoadRuleAndCombine(stat.RuleFiles)
+	if err != nil {
+		return err
+	}
+	if HashInputs(gen, rules, stat.RuleFilter, stat.WordOrder) != stat.InputHash {
+		return fmt.Errorf("%s: wordlist or rules changed since stat was saved, word: %s, dictionaries: %s, rules: %s",
+			stat.BaseUrl, stat.Word, strings.Join(stat.Dictionaries, ","), strings.Join(stat.RuleFiles, ","))
+	}
+	return nil
+}
+
 func ReadStatistors(filename string) (Statistors, error) {
 	content, err := ioutil.ReadFile(filename)
 	if err != nil {
@@ -0,0 +1,227 @@
+package pkg
+
+import (
+	"fmt"
+	"math"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// statusRange 单个状态码也表示为lo与hi相同的范围, raw保留原始输入用于输出
+type statusRange struct {
+	raw    string
+	lo, hi int
+}
+
+func (r statusRange) overlap(o statusRange) bool {
+	return r.lo <= o.hi && o.lo <= r.hi
+}
+
+// parseStatusRange 支持200, 300-399, 2xx/40x通配符与all
+func parseStatusRange(s string) (statusRange, error) {
+	r := statusRange{raw: s}
+	lower := strings.ToLower(s)
+	switch {
+	case lower == "all" || lower == "*":
+		r.raw, r.lo, r.hi = "all", 0, math.MaxInt32
+		return r, nil
+	case strings.Contains(lower, "-"):
+		lo, hi, _ := strings.Cut(lower, "-")
+		var err1, err2 error
+		r.lo, err1 = strconv.Atoi(lo)
+		r.hi, err2 = strconv.Atoi(hi)
+		if err1 != nil || err2 != nil || r.lo > r.hi {
+			return r, fmt.Errorf("invalid status range %q", s)
+		}
+		return r, nil
+	case strings.HasSuffix(lower, "x"):
+		prefix := strings.TrimRight(lower, "x")
+		if len(lower) != 3 || strings.Trim(prefix, "0123456789") != "" {
+			return r, fmt.Errorf("invalid status wildcard %q", s)
+		}
+		r.lo, _ = strconv.Atoi(prefix + strings.Repeat("0", 3-len(prefix)))
+		r.hi, _ = strconv.Atoi(prefix + strings.Repeat("9", 3-len(prefix)))
+		return r, nil
+	}
+	code, err := strconv.Atoi(s)
+	if err != nil {
+		return r, fmt.Errorf("invalid status %q", s)
+	}
+	r.lo, r.hi = code, code
+	return r, nil
+}
+
+// StatusSet 状态码集合, 所有status相关的参数(--white-status, --black-status, --fuzzy-status...)共用,
+// 包含的项可以是单个状态码, 范围(300-399), 通配符(2xx, 40x)或all, 排除的项优先于包含的项
+type StatusSet struct {
+	include []statusRange
+	exclude []statusRange
+}
+
+func NewStatusSet(codes ...int) *StatusSet {
+	s := &StatusSet{}
+	for _, code := range codes {
+		s.include = append(s.include, statusRange{raw: strconv.Itoa(code), lo: code, hi: code})
+	}
+	return s
+}
+
+func (s *StatusSet) Contains(status int) bool {
+	if s == nil {
+		return false
+	}
+	for _, r := range s.exclude {
+		if status >= r.lo && status <= r.hi {
+			return false
+		}
+	}
+	for _, r := range s.include {
+		if status >= r.lo && status <= r.hi {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *StatusSet) clone() *StatusSet {
+	if s == nil {
+		return &StatusSet{}
+	}
+	return &StatusSet{
+		include: append([]statusRange{}, s.include...),
+		exclude: append([]statusRange{}, s.exclude...),
+	}
+}
+
+func (s *StatusSet) add(r statusRange) {
+	s.exclude = removeStatusRange(s.exclude, r)
+	s.include = append(removeStatusRange(s.include, r), r)
+}
+
+// remove 先删除相同的项, 仍然与其他包含的项有交集时, 例如从4xx中去掉404, 再作为排除项
+func (s *StatusSet) remove(r statusRange) {
+	s.include = removeStatusRange(s.include, r)
+	for _, inc := range s.include {
+		if inc.overlap(r) {
+			s.exclude = append(removeStatusRange(s.exclude, r), r)
+			return
+		}
+	}
+}
+
+func removeStatusRange(rs []statusRange, r statusRange) []statusRange {
+	var res []statusRange
+	for _, o := range rs {
+		if o.lo != r.lo || o.hi != r.hi {
+			res = append(res, o)
+		}
+	}
+	return res
+}
+
+// String 与输入的格式相同, 例如: 200,3xx,!304
+func (s *StatusSet) String() string {
+	if s == nil {
+		return ""
+	}
+	var items []string
+	for _, r := range s.include {
+		items = append(items, r.raw)
+	}
+	for _, r := range s.exclude {
+		items = append(items, "!"+r.raw)
+	}
+	return strings.Join(items, ",")
+}
+
+// ParseStatus 解析逗号分隔的状态码, 为空时返回preset. +开头表示在preset基础上添加, !开头表示从preset中删除,
+// 否则替换preset. 单个项同样可以使用!排除, 例如: 2xx,300-399,!204
+func ParseStatus(preset *StatusSet, changed string) (*StatusSet, error) {
+	if changed == "" {
+		return preset, nil
+	}
+	var set *StatusSet
+	mode := changed[0]
+	if mode == '+' || mode == '!' {
+		set = preset.clone()
+		changed = changed[1:]
+	} else {
+		set = &StatusSet{}
+	}
+	for _, item := range strings.Split(changed, ",") {
+		item = strings.TrimSpace(item)
+		exclude := mode == '!'
+		if strings.HasPrefix(item, "!") {
+			exclude = true
+			item = item[1:]
+		}
+		if item == "" {
+			continue
+		}
+		r, err := parseStatusRange(item)
+		if err != nil {
+			return nil, err
+		}
+		if exclude {
+			set.remove(r)
+		} else {
+			set.add(r)
+		}
+	}
+	return set, nil
+}
+
+// ExtStatus --ext-status指定的后缀状态码, 对应后缀的路径使用各自的白名单与黑名单代替全局的WhiteStatus与BlackStatus
+var ExtStatus = map[string]*ExtStatusRule{}
+
+// ExtStatusRule 没有指定的部分与全局的WhiteStatus与BlackStatus相同
+type ExtStatusRule struct {
+	White *StatusSet
+	Black *StatusSet
+}
+
+// ParseExtStatus 解析ext:white[:black], 同一个参数中的多个后缀使用空格分隔, 例如: php:200,500 zip:2xx:416,
+// white与black的语法与ParseStatus相同, +与!基于全局的WhiteStatus与BlackStatus, 因此需要在它们之后解析
+func ParseExtStatus(ss []string) (map[string]*ExtStatusRule, error) {
+	rules := make(map[string]*ExtStatusRule)
+	for _, s := range ss {
+		for _, field := range strings.Fields(s) {
+			parts := strings.Split(field, ":")
+			ext := strings.ToLower(strings.TrimPrefix(parts[0], "."))
+			if len(parts) < 2 || len(parts) > 3 || ext == "" {
+				return nil, fmt.Errorf("invalid %q, format is ext:white[:black], e.g.: php:200,500:404", field)
+			}
+			var black string
+			if len(parts) == 3 {
+				black = parts[2]
+			}
+			white, err := ParseStatus(WhiteStatus, parts[1])
+			if err != nil {
+				return nil, fmt.Errorf("%s %w", field, err)
+			}
+			rule := &ExtStatusRule{White: white}
+			if rule.Black, err = ParseStatus(BlackStatus, black); err != nil {
+				return nil, fmt.Errorf("%s %w", field, err)
+			}
+			rules[ext] = rule
+		}
+	}
+	return rules, nil
+}
+
+// StatusOfPath 返回路径对应的白名单与黑名单状态码, 没有匹配的--ext-status时为全局的WhiteStatus与BlackStatus
+func StatusOfPath(p string) (white, black *StatusSet) {
+	white, black = WhiteStatus, BlackStatus
+	if len(ExtStatus) == 0 {
+		return white, black
+	}
+	if i := strings.IndexAny(p, "?#"); i != -1 {
+		p = p[:i]
+	}
+	ext := strings.ToLower(strings.TrimPrefix(path.Ext(p), "."))
+	if rule, ok := ExtStatus[ext]; ok {
+		white, black = rule.White, rule.Black
+	}
+	return white, black
+}
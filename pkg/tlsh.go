@@ -0,0 +1,165 @@
+package pkg
+
+import (
+	"math"
+	"sort"
+)
+
+// TLSH 按照Trend Micro TLSH的算法实现的局部敏感hash(128 buckets, 1 byte checksum)
+// 只用于spray内部的相似度对比, pearson置换表由固定种子生成, 与官方实现的hash值不通用
+type TLSH struct {
+	checksum byte
+	lvalue   byte
+	q1ratio  byte
+	q2ratio  byte
+	code     [tlshCodeSize]byte
+}
+
+const (
+	tlshWindow     = 5
+	tlshBuckets    = 128
+	tlshCodeSize   = tlshBuckets / 4
+	tlshMinDataLen = 50
+)
+
+var tlshTable [256]byte
+
+func init() {
+	for i := range tlshTable {
+		tlshTable[i] = byte(i)
+	}
+	// xorshift打乱, 保证每次运行得到相同的置换表
+	seed := uint32(0x9e3779b9)
+	for i := len(tlshTable) - 1; i > 0; i-- {
+		seed ^= seed << 13
+		seed ^= seed >> 17
+		seed ^= seed << 5
+		j := int(seed % uint32(i+1))
+		tlshTable[i], tlshTable[j] = tlshTable[j], tlshTable[i]
+	}
+}
+
+func tlshMapping(salt, i, j, k byte) byte {
+	h := tlshTable[salt]
+	h = tlshTable[h^i]
+	h = tlshTable[h^j]
+	return tlshTable[h^k]
+}
+
+// NewTLSH 数据过短或特征过少(超过一半的bucket为空)时无法得到有意义的hash, 返回nil
+func NewTLSH(data []byte) *TLSH {
+	if len(data) < tlshMinDataLen {
+		return nil
+	}
+	var buckets [256]uint32
+	var checksum byte
+	for i := tlshWindow - 1; i < len(data); i++ {
+		c0, c1, c2, c3, c4 := data[i], data[i-1], data[i-2], data[i-3], data[i-4]
+		checksum = tlshMapping(0, c0, c1, checksum)
+		buckets[tlshMapping(2, c0, c1, c2)]++
+		buckets[tlshMapping(3, c0, c1, c3)]++
+		buckets[tlshMapping(5, c0, c2, c3)]++
+		buckets[tlshMapping(7, c0, c2, c4)]++
+		buckets[tlshMapping(11, c0, c1, c4)]++
+		buckets[tlshMapping(13, c0, c3, c4)]++
+	}
+
+	sorted := make([]uint32, tlshBuckets)
+	copy(sorted, buckets[:tlshBuckets])
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	q1, q2, q3 := sorted[tlshBuckets/4-1], sorted[tlshBuckets/2-1], sorted[tlshBuckets*3/4-1]
+	if q3 == 0 {
+		return nil
+	}
+	var nonzero int
+	for _, b := range buckets[:tlshBuckets] {
+		if b > 0 {
+			nonzero++
+		}
+	}
+	if nonzero <= tlshBuckets/2 {
+		return nil
+	}
+
+	h := &TLSH{
+		checksum: checksum,
+		lvalue:   tlshLength(len(data)),
+		q1ratio:  byte(uint64(q1)*100/uint64(q3)) % 16,
+		q2ratio:  byte(uint64(q2)*100/uint64(q3)) % 16,
+	}
+	for i := 0; i < tlshCodeSize; i++ {
+		var v byte
+		for j := 0; j < 4; j++ {
+			k := buckets[i*4+j]
+			switch {
+			case k > q3:
+				v |= 3 << (j * 2)
+			case k > q2:
+				v |= 2 << (j * 2)
+			case k > q1:
+				v |= 1 << (j * 2)
+			}
+		}
+		h.code[i] = v
+	}
+	return h
+}
+
+func tlshLength(n int) byte {
+	l := math.Log(float64(n))
+	var v float64
+	switch {
+	case n <= 656:
+		v = l / math.Log(1.5)
+	case n <= 3199:
+		v = l/math.Log(1.3) - 8.72777
+	default:
+		v = l/math.Log(1.1) - 62.5472
+	}
+	return byte(int(math.Floor(v)) & 0xff)
+}
+
+func tlshModDiff(x, y, r int) int {
+	dl := x - y
+	if dl < 0 {
+		dl = -dl
+	}
+	return min(dl, r-dl)
+}
+
+// Diff 两个hash的距离, 0为完全一致, 一般小于50可以认为是同一类页面
+func (h *TLSH) Diff(other *TLSH) int {
+	var diff int
+	if h.checksum != other.checksum {
+		diff++
+	}
+	if d := tlshModDiff(int(h.lvalue), int(other.lvalue), 256); d <= 1 {
+		diff += d
+	} else {
+		diff += d * 12
+	}
+	for _, d := range []int{
+		tlshModDiff(int(h.q1ratio), int(other.q1ratio), 16),
+		tlshModDiff(int(h.q2ratio), int(other.q2ratio), 16),
+	} {
+		if d <= 1 {
+			diff += d
+		} else {
+			diff += (d - 1) * 12
+		}
+	}
+	for i := range h.code {
+		x, y := h.code[i], other.code[i]
+		for j := 0; j < 4; j++ {
+			d := int(x>>(j*2)&3) - int(y>>(j*2)&3)
+			if d < 0 {
+				d = -d
+			}
+			if d == 3 {
+				d = 6
+			}
+			diff += d
+		}
+	}
+	return diff
+}
@@ -3,6 +3,7 @@ package pkg
 import (
 	"bufio"
 	"bytes"
+	"fmt"
 	"github.com/chainreactors/files"
 	"github.com/chainreactors/fingers"
 	"github.com/chainreactors/logs"
@@ -12,8 +13,8 @@ import (
 	"github.com/chainreactors/words/rule"
 	"github.com/expr-lang/expr"
 	"github.com/expr-lang/expr/vm"
-	"io/ioutil"
 	"math/rand"
+	"net"
 	"net/http"
 	"net/url"
 	"path"
@@ -27,11 +28,12 @@ import (
 var (
 	LogVerbose   = logs.Warn - 2
 	LogFuzz      = logs.Warn - 1
-	WhiteStatus  = []int{} // cmd input, 200
-	BlackStatus  = []int{} // cmd input, 400,410
-	FuzzyStatus  = []int{} // cmd input, 500,501,502,503
-	WAFStatus    = []int{493, 418, 1020, 406, 429}
-	UniqueStatus = []int{} // 相同unique的403表示命中了同一条acl, 相同unique的200表示default页面
+	WhiteStatus  = NewStatusSet() // cmd input, 200
+	BlackStatus  = NewStatusSet() // cmd input, 400,410
+	FuzzyStatus  = NewStatusSet() // cmd input, 500,501,502,503
+	WAFStatus    = NewStatusSet(493, 418, 1020, 406, 429)
+	UniqueStatus = NewStatusSet() // 相同unique的403表示命中了同一条acl, 相同unique的200表示default页面
+	DeferStatus  = NewStatusSet() // cmd input, 502,503,504, 暂时性的错误, 任务结束前重新请求一次再判断
 
 	// plugins
 	EnableAllFingerEngine = false
@@ -222,6 +224,27 @@ func BakGenerator(domain string) []string {
 	return possibilities
 }
 
+// BakHostNames 由域名生成常见的整站备份文件名, 相比BakGenerator只保留有意义的组合
+// e.g.: www.example.com:8080 => www.example.com, example.com, www_example_com, wwwexamplecom, www, example
+func BakHostNames(host string) []string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if net.ParseIP(host) != nil {
+		return []string{host}
+	}
+	names := []string{host}
+	labels := strings.Split(host, ".")
+	if len(labels) > 2 {
+		names = append(names, strings.Join(labels[len(labels)-2:], "."))
+	}
+	names = append(names, strings.Join(labels, "_"), strings.Join(labels, ""))
+	for _, label := range labels[:max(len(labels)-1, 1)] {
+		names = append(names, label)
+	}
+	return iutils.StringsUnique(names)
+}
+
 var MbTable = []uint16{
 	0x0000, 0xC0C1, 0xC181, 0x0140, 0xC301, 0x03C0, 0x0280, 0xC241,
 	0xC601, 0x06C0, 0x0780, 0xC741, 0x0500, 0xC5C1, 0xC481, 0x0440,
@@ -365,6 +388,60 @@ func BaseURL(u *url.URL) string {
 	return u.Scheme + "://" + u.Host
 }
 
+// NormalizeURL 统一scheme与host大小写, 去掉默认端口, 空路径补全为"/", 结尾连续的"/"合并为一个, 用于目标去重
+// "/admin"与"/admin/"在spray中分别作为文件与目录扫描, 不做合并
+func NormalizeURL(s string) string {
+	u, err := url.Parse(s)
+	if err != nil || u.Host == "" {
+		return s
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	host, port := strings.ToLower(u.Hostname()), u.Port()
+	if (u.Scheme == "http" && port == "80") || (u.Scheme == "https" && port == "443") {
+		port = ""
+	}
+	if port != "" {
+		u.Host = net.JoinHostPort(host, port)
+	} else if strings.Contains(host, ":") {
+		u.Host = "[" + host + "]"
+	} else {
+		u.Host = host
+	}
+	if u.Path == "" && u.RawPath == "" {
+		u.Path = "/"
+	} else if strings.HasSuffix(u.Path, "//") {
+		u.Path = strings.TrimRight(u.Path, "/") + "/"
+		if u.RawPath != "" {
+			u.RawPath = strings.TrimRight(u.RawPath, "/") + "/"
+		}
+	}
+	return u.String()
+}
+
+// GuessContentType 根据body内容猜测Content-Type, 用于--data未指定Content-Type时
+func GuessContentType(body []byte) string {
+	trimmed := bytes.TrimSpace(body)
+	switch {
+	case len(trimmed) == 0:
+		return "application/x-www-form-urlencoded"
+	case trimmed[0] == '{' || trimmed[0] == '[':
+		return "application/json"
+	case trimmed[0] == '<':
+		return "application/xml"
+	default:
+		return "application/x-www-form-urlencoded"
+	}
+}
+
+// SetUserAgents 使用自定义的user-agent池替换内置的列表
+func SetUserAgents(uas []string) {
+	if len(uas) == 0 {
+		return
+	}
+	randomUserAgent = uas
+	uacount = len(uas)
+}
+
 func RandomUA() string {
 	return randomUserAgent[rand.Intn(uacount)]
 }
@@ -422,42 +499,6 @@ func ParseExtension(s string) string {
 	return ""
 }
 
-func ParseStatus(preset []int, changed string) []int {
-	if changed == "" {
-		return preset
-	}
-	if strings.HasPrefix(changed, "+") {
-		for _, s := range strings.Split(changed[1:], ",") {
-			if t, err := strconv.Atoi(s); err != nil {
-				continue
-			} else {
-				preset = append(preset, t)
-			}
-		}
-	} else if strings.HasPrefix(changed, "!") {
-		for _, s := range strings.Split(changed[1:], ",") {
-			for i, status := range preset {
-				if t, err := strconv.Atoi(s); err != nil {
-					break
-				} else if t == status {
-					preset = append(preset[:i], preset[i+1:]...)
-					break
-				}
-			}
-		}
-	} else {
-		preset = []int{}
-		for _, s := range strings.Split(changed, ",") {
-			if t, err := strconv.Atoi(s); err != nil {
-				continue
-			} else {
-				preset = append(preset, t)
-			}
-		}
-	}
-	return preset
-}
-
 func LoadFileToSlice(filename string) ([]string, error) {
 	var ss []string
 	if dicts, ok := Dicts[filename]; ok {
@@ -466,18 +507,23 @@ func LoadFileToSlice(filename string) ([]string, error) {
 		}
 		return dicts, nil
 	}
-	content, err := ioutil.ReadFile(filename)
+	f, err := OpenFile(filename)
 	if err != nil {
 		return nil, err
 	}
+	defer f.Close()
 
-	ss = strings.Split(strings.TrimSpace(string(content)), "\n")
-
-	// 统一windows与linux的回车换行差异
-	for i, word := range ss {
-		ss[i] = strings.TrimSpace(word)
+	// 按行读取, 避免超大的字典同时存在文件内容与切分后的两份拷贝
+	err = scanLines(f, func(line string) bool {
+		ss = append(ss, line)
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("read %s, %w", filename, err)
+	}
+	if len(ss) == 0 {
+		ss = []string{""}
 	}
-
 	return ss, nil
 }
 
@@ -488,7 +534,7 @@ func LoadRuleAndCombine(filename []string) (string, error) {
 			bs.WriteString(strings.TrimSpace(data))
 			bs.WriteString("\n")
 		} else {
-			content, err := ioutil.ReadFile(f)
+			content, err := ReadFile(f)
 			if err != nil {
 				return "", err
 			}
@@ -539,13 +585,25 @@ func LoadWordlist(word string, dictNames []string) ([]string, error) {
 	return wl, nil
 }
 
+// LoadWordGenerator 与LoadWordlist相同, 但不展开字典, 用于超大的字典
+func LoadWordGenerator(word string, dictNames []string) (*WordGenerator, error) {
+	if word == "{?0}" && len(dictNames) == 1 && IsLazyDict(dictNames[0]) {
+		return NewFileWordGenerator(dictNames[0])
+	}
+	dicts, err := loadDictionaries(dictNames)
+	if err != nil {
+		return nil, err
+	}
+	return NewWordGenerator(word, dicts)
+}
+
 func LoadRuleWithFiles(ruleFiles []string, filter string) ([]rule.Expression, error) {
 	if rules, ok := ruleCache[strings.Join(ruleFiles, ",")]; ok {
 		return rules, nil
 	}
 	var rules bytes.Buffer
 	for _, filename := range ruleFiles {
-		content, err := ioutil.ReadFile(filename)
+		content, err := ReadFile(filename)
 		if err != nil {
 			return nil, err
 		}
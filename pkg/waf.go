@@ -0,0 +1,39 @@
+package pkg
+
+import (
+	"regexp"
+)
+
+// WafSignature 常见waf/cdn的响应特征, header匹配原始响应头(包含Set-Cookie), body匹配拦截页面
+type WafSignature struct {
+	Name   string
+	Type   string // waf或cdn
+	Header *regexp.Regexp
+	Body   *regexp.Regexp
+}
+
+var WafSignatures = []*WafSignature{
+	{Name: "cloudflare", Type: "cdn", Header: regexp.MustCompile(`(?i)server: cloudflare|cf-ray:|__cfduid|cf_clearance`), Body: regexp.MustCompile(`(?i)attention required! \| cloudflare|cloudflare ray id`)},
+	{Name: "akamai", Type: "cdn", Header: regexp.MustCompile(`(?i)server: akamaighost|akamai-grn|x-akamai-`), Body: regexp.MustCompile(`(?i)access denied.{0,200}reference #[0-9a-f]+\.`)},
+	{Name: "cloudfront", Type: "cdn", Header: regexp.MustCompile(`(?i)x-amz-cf-id|server: cloudfront`), Body: regexp.MustCompile(`(?i)request blocked.{0,200}cloudfront`)},
+	{Name: "fastly", Type: "cdn", Header: regexp.MustCompile(`(?i)x-fastly-request-id|fastly-debug`)},
+	{Name: "imperva", Type: "waf", Header: regexp.MustCompile(`(?i)x-iinfo:|incap_ses_|visid_incap_`), Body: regexp.MustCompile(`(?i)incapsula incident id`)},
+	{Name: "aliyun", Type: "waf", Header: regexp.MustCompile(`(?i)aliyungf_tc|acw_tc=|server: tengine/aserver`), Body: regexp.MustCompile(`(?i)errors\.aliyun\.com|由于您访问的URL有可能对网站造成安全威胁`)},
+	{Name: "tencent", Type: "waf", Body: regexp.MustCompile(`(?i)waf\.tencent-cloud\.com|腾讯云web应用防火墙`)},
+	{Name: "safedog", Type: "waf", Header: regexp.MustCompile(`(?i)safedog|server: waf/2\.0`), Body: regexp.MustCompile(`(?i)safedog\.cn|网站防火墙`)},
+	{Name: "yunsuo", Type: "waf", Header: regexp.MustCompile(`(?i)yunsuo_session`), Body: regexp.MustCompile(`(?i)yunsuologo`)},
+	{Name: "360wzb", Type: "waf", Header: regexp.MustCompile(`(?i)x-powered-by-360wzb|wangzhan\.360\.cn`), Body: regexp.MustCompile(`(?i)wangzhan\.360\.cn`)},
+	{Name: "f5-asm", Type: "waf", Header: regexp.MustCompile(`(?i)bigipserver|\bts[0-9a-f]{6,}=`), Body: regexp.MustCompile(`(?i)the requested url was rejected\. please consult with your administrator`)},
+	{Name: "modsecurity", Type: "waf", Header: regexp.MustCompile(`(?i)mod_security|nyob`), Body: regexp.MustCompile(`(?i)mod_security|this error was generated by mod_security`)},
+}
+
+// DetectWaf 根据响应头与body识别waf/cdn, 返回命中的名称
+func DetectWaf(bl *Baseline) []string {
+	var names []string
+	for _, sig := range WafSignatures {
+		if (sig.Header != nil && sig.Header.Match(bl.Header)) || (sig.Body != nil && sig.Body.Match(bl.Body)) {
+			names = append(names, sig.Name)
+		}
+	}
+	return names
+}
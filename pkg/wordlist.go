@@ -0,0 +1,364 @@
+package pkg
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math/rand"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/chainreactors/logs"
+	"github.com/chainreactors/words/mask"
+)
+
+// LazyThreshold 生成的字典数量超过该值时不再展开到内存中, 扫描时按需生成
+var LazyThreshold = 1000000
+
+// LazyDictSize 超过该大小的字典文件单独使用时(word为{?0})不加载到内存, 先遍历一遍统计行数, 扫描时按行读取
+var LazyDictSize int64 = 64 << 20
+
+// WordGenerator 按需生成的字典, 只保存mask表达式与参数字典, 数量在解析时预先计算,
+// 每次调用Stream重新生成, 避免mask与字典的笛卡尔积全部展开到内存中
+type WordGenerator struct {
+	Word   string
+	Params [][]string
+	File   string // 按行读取的字典文件, 不为空时忽略Word与Params
+	Count  int
+}
+
+func NewWordGenerator(word string, params [][]string) (*WordGenerator, error) {
+	g := &WordGenerator{Word: word, Params: params}
+	program, err := g.parse()
+	if err != nil {
+		return nil, err
+	}
+	g.Count = maskCount(program)
+	return g, nil
+}
+
+func (g *WordGenerator) parse() (*mask.Program, error) {
+	p := mask.NewParser(mask.NewLexer(g.Word), g.Params, nil)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, fmt.Errorf("%s compile error, %s", g.Word, strings.Join(p.Errors(), ", "))
+	}
+	return program, nil
+}
+
+// maskCount 按mask.Eval的规则从语法树计算数量, mask.Eval会立即启动生成word的goroutine, 只计算数量时不能调用
+func maskCount(program *mask.Program) int {
+	var count int
+	for i, expr := range program.Expressions {
+		n := 1
+		if m, ok := expr.(*mask.MaskExpression); ok {
+			greedy := m.Start.Literal != "?"
+			n = 0
+			for j, product := 1, 1; j <= m.Repeat; j++ {
+				product *= len(m.CharacterSet)
+				if greedy {
+					n += product
+				} else {
+					n = product
+				}
+			}
+		}
+		if i == 0 {
+			count = n
+		} else {
+			count *= n
+		}
+	}
+	return count
+}
+
+// NewFileWordGenerator 按行读取字典文件, 只在创建时遍历一次文件统计行数
+func NewFileWordGenerator(filename string) (*WordGenerator, error) {
+	count, err := CountLines(filename)
+	if err != nil {
+		return nil, err
+	}
+	return &WordGenerator{Word: "{?0}", File: filename, Count: count}, nil
+}
+
+// IsLazyDict 字典文件超过LazyDictSize, 预设的字典总是在内存中
+// 压缩文件的大小不能反映字典的大小, 解压读取到LazyDictSize为止判断
+func IsLazyDict(filename string) bool {
+	if _, ok := Dicts[filename]; ok {
+		return false
+	}
+	info, err := os.Stat(filename)
+	if err != nil {
+		return false
+	} else if info.Size() > LazyDictSize {
+		return true
+	}
+	f, err := OpenFile(filename)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	if !f.(*decompressReader).compressed {
+		return false
+	}
+	n, _ := io.CopyN(io.Discard, f, LazyDictSize+1)
+	return n > LazyDictSize
+}
+
+// scanLines 按行读取字典, 每行去掉首尾的空白, 忽略文件首尾的空行, 中间的空行保留, fn返回false时停止读取
+// LoadFileToSlice, CountLines与按行读取的字典都使用该规则, 保证数量与内容一致
+func scanLines(r io.Reader, fn func(line string) bool) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	var started bool
+	var blanks int
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			if started {
+				// 之后还有非空行时才输出
+				blanks++
+			}
+			continue
+		}
+		started = true
+		for ; blanks > 0; blanks-- {
+			if !fn("") {
+				return nil
+			}
+		}
+		if !fn(line) {
+			return nil
+		}
+	}
+	return scanner.Err()
+}
+
+// CountLines 统计文件中的字典数量, 与按行读取得到的数量一致
+func CountLines(filename string) (int, error) {
+	f, err := OpenFile(filename)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	var count int
+	err = scanLines(f, func(string) bool {
+		count++
+		return true
+	})
+	return count, err
+}
+
+// streamFile done关闭时停止读取, 用于只需要读取前一部分的场景
+func (g *WordGenerator) streamFile(done chan struct{}) chan string {
+	ch := make(chan string)
+	go func() {
+		defer close(ch)
+		f, err := OpenFile(g.File)
+		if err != nil {
+			logs.Log.Error(err.Error())
+			return
+		}
+		defer f.Close()
+		err = scanLines(f, func(line string) bool {
+			select {
+			case ch <- line:
+				return true
+			case <-done:
+				return false
+			}
+		})
+		if err != nil {
+			logs.Log.Errorf("read %s, %s", g.File, err.Error())
+		}
+	}()
+	return ch
+}
+
+// Stream 从头开始生成字典
+func (g *WordGenerator) Stream() chan string {
+	if g.File != "" {
+		return g.streamFile(nil)
+	}
+	var gen *mask.GENERATOR
+	if program, err := g.parse(); err == nil {
+		gen, _ = mask.Eval(program).(*mask.GENERATOR)
+	}
+	if gen == nil {
+		ch := make(chan string)
+		close(ch)
+		return ch
+	}
+	return gen.Streamer
+}
+
+// All 展开全部字典, 只用于数量较小或必须使用列表的场景
+func (g *WordGenerator) All() []string {
+	ws := make([]string, 0, g.Count)
+	for w := range g.Stream() {
+		ws = append(ws, w)
+	}
+	return ws
+}
+
+// HashInputs 生成的字典, 规则与规则过滤器的hash, 记录在stat中, 断点续传时检查字典与规则是否发生了变化,
+// 超过LazyThreshold时不完整生成一遍: 字典文件只读取前LazyThreshold行, mask字典使用表达式与参数字典计算
+func HashInputs(g *WordGenerator, rules, filter string, order WordOrder) string {
+	h := sha256.New()
+	h.Write([]byte(strconv.Itoa(g.Count)))
+	h.Write([]byte{0})
+	switch {
+	case g.File != "":
+		done := make(chan struct{})
+		var i int
+		for w := range g.streamFile(done) {
+			if i >= LazyThreshold {
+				break
+			}
+			h.Write([]byte(w))
+			h.Write([]byte{'\n'})
+			i++
+		}
+		close(done)
+	case g.Count > LazyThreshold:
+		h.Write([]byte(g.Word))
+		for _, param := range g.Params {
+			h.Write([]byte{0})
+			h.Write([]byte(strings.Join(param, "\n")))
+		}
+	default:
+		for w := range g.Stream() {
+			h.Write([]byte(w))
+			h.Write([]byte{'\n'})
+		}
+	}
+	h.Write([]byte{0})
+	h.Write([]byte(rules))
+	h.Write([]byte{0})
+	h.Write([]byte(filter))
+	if order.Enabled() {
+		h.Write([]byte{0})
+		h.Write([]byte(order.String()))
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// WordOrder --unique-words, --shuffle与--sort-by-priority, 会改变字典的数量与顺序, 记录在stat中,
+// 续传时按照stat中的记录重新排序, 保证offset对应到相同的word. 需要展开全部字典, 只支持不超过LazyThreshold的字典
+type WordOrder struct {
+	UniqueWords  bool `json:"unique_words,omitempty"`
+	Shuffle      bool `json:"shuffle,omitempty"`
+	SortPriority bool `json:"sort_by_priority,omitempty"`
+}
+
+func (o WordOrder) Enabled() bool {
+	return o.UniqueWords || o.Shuffle || o.SortPriority
+}
+
+func (o WordOrder) String() string {
+	var s []string
+	if o.UniqueWords {
+		s = append(s, "unique")
+	}
+	if o.Shuffle {
+		s = append(s, "shuffle")
+	}
+	if o.SortPriority {
+		s = append(s, "priority")
+	}
+	return strings.Join(s, ",")
+}
+
+// Apply 依次去重, 打乱与按优先级排序
+func (o WordOrder) Apply(ws []string) []string {
+	if o.UniqueWords {
+		ws = UniqueWords(ws)
+	}
+	if o.Shuffle {
+		ShuffleWords(ws)
+	}
+	if o.SortPriority {
+		// 与--shuffle同时使用时, 常见路径在前, 其余乱序
+		SortByPriority(ws)
+	}
+	return ws
+}
+
+// PriorityWords --sort-by-priority时优先扫描的常见路径, 越靠前优先级越高, quick中的高价值路径排在其后
+var PriorityWords = []string{
+	"admin", "login", "api", "index", "robots.txt", "sitemap.xml", ".env", ".git/HEAD",
+	"backup", "config", "console", "dashboard", "manager", "upload", "uploads", "test", "dev", "debug",
+	"swagger", "swagger-ui.html", "api-docs", "graphql", "actuator", "server-status", "phpmyadmin",
+	"wp-admin", "wp-login.php", "web.config", "install", "setup", "portal", "user", "static", "assets",
+	"old", "tmp", "db", "data", "private",
+}
+
+// UniqueWords 去除重复的word, 保留第一次出现的位置
+func UniqueWords(ws []string) []string {
+	seen := make(map[string]struct{}, len(ws))
+	uniq := ws[:0]
+	for _, w := range ws {
+		if _, ok := seen[w]; ok {
+			continue
+		}
+		seen[w] = struct{}{}
+		uniq = append(uniq, w)
+	}
+	return uniq
+}
+
+// ShuffleWords 打乱顺序, 种子由字典内容计算, 相同的输入总是得到相同的顺序, 保证--resume的offset仍然有效
+func ShuffleWords(ws []string) {
+	h := fnv.New64a()
+	for _, w := range ws {
+		h.Write([]byte(w))
+		h.Write([]byte{'\n'})
+	}
+	rnd := rand.New(rand.NewSource(int64(h.Sum64())))
+	rnd.Shuffle(len(ws), func(i, j int) {
+		ws[i], ws[j] = ws[j], ws[i]
+	})
+}
+
+// SortByPriority 将PriorityWords中的常见路径提前, 其他word保持原有顺序,
+// 先比较完整的路径, 再比较去掉后缀的文件名, 例如admin.php与admin优先级相同
+func SortByPriority(ws []string) {
+	priority := append(append([]string{}, PriorityWords...), QuickPaths()...)
+	ranks := make(map[string]int, len(priority))
+	for i := len(priority) - 1; i >= 0; i-- {
+		ranks[strings.ToLower(priority[i])] = i
+	}
+	rank := func(w string) int {
+		w = strings.ToLower(strings.Trim(w, "/"))
+		if i, ok := ranks[w]; ok {
+			return i
+		}
+		if ext := path.Ext(w); ext != "" {
+			if i, ok := ranks[strings.TrimSuffix(w, ext)]; ok {
+				return i
+			}
+		}
+		return len(priority)
+	}
+
+	type ranked struct {
+		word string
+		rank int
+	}
+	rs := make([]ranked, len(ws))
+	for i, w := range ws {
+		rs[i] = ranked{w, rank(w)}
+	}
+	sort.SliceStable(rs, func(i, j int) bool {
+		return rs[i].rank < rs[j].rank
+	})
+	for i, r := range rs {
+		ws[i] = r.word
+	}
+}
@@ -4,6 +4,7 @@ package main
 import (
 	"github.com/chainreactors/spray/cmd"
 	"github.com/gookit/config/v2"
+	"github.com/gookit/config/v2/toml"
 	"github.com/gookit/config/v2/yaml"
 	//_ "net/http/pprof"
 )
@@ -14,6 +15,7 @@ func init() {
 		opt.ParseDefault = true
 	})
 	config.AddDriver(yaml.Driver)
+	config.AddDriver(toml.Driver)
 }
 
 func main() {
@@ -0,0 +1,195 @@
+// Package spray 供其他go工具嵌入spray的编程接口
+//
+// 配置与命令行参数一一对应, 结果通过迭代器或channel读取:
+//
+//	opt, err := spray.NewOption("-u", "http://example.com", "-d", "1.txt", "-q")
+//	if err != nil {
+//		return err
+//	}
+//	results, err := spray.NewRunner(opt).Run(ctx)
+//	if err != nil {
+//		return err
+//	}
+//	for results.Next() {
+//		bl := results.Baseline()
+//		fmt.Println(bl.UrlString, bl.Status)
+//	}
+//	return results.Err()
+//
+// 指纹, 状态码等配置是进程内的全局状态, 同一时间只能运行一个扫描, 并发调用Run会依次执行.
+package spray
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/chainreactors/logs"
+	"github.com/chainreactors/spray/internal"
+	"github.com/chainreactors/spray/internal/ihttp"
+	"github.com/chainreactors/spray/pkg"
+	"github.com/jessevdk/go-flags"
+)
+
+type (
+	// Option 完整的扫描配置, 字段与命令行参数相同
+	Option = internal.Option
+	// Baseline 单个请求的结果
+	Baseline = pkg.Baseline
+	// Statistor 单个目标扫描结束后的统计
+	Statistor = pkg.Statistor
+	// Sink 额外的结果输出端, 可以通过Runner.Sinks添加
+	Sink = internal.Sink
+)
+
+// DefaultBuffer 结果channel的缓冲大小
+var DefaultBuffer = 100
+
+// running 全局状态同一时间只能被一个扫描使用
+var running sync.Mutex
+
+func init() {
+	logs.AddLevel(pkg.LogVerbose, "verbose", "[=] %s {{suffix}}")
+}
+
+// NewOption 以命令行参数的形式构造配置, 未指定的参数使用命令行的默认值, e.g.: NewOption("-u", "http://example.com", "-d", "1.txt")
+func NewOption(args ...string) (*Option, error) {
+	opt := &Option{}
+	parser := flags.NewParser(opt, flags.PassDoubleDash)
+	rest, err := parser.ParseArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) > 0 {
+		return nil, errors.New("unknown arguments: " + rest[0])
+	}
+	return opt, nil
+}
+
+// Runner 一次扫描, Run之前可以通过Sinks添加额外的输出端
+type Runner struct {
+	Option *Option
+	Sinks  []Sink
+	// Buffer 结果channel的缓冲, 缓冲满时扫描会等待结果被读取
+	Buffer int
+}
+
+func NewRunner(opt *Option) *Runner {
+	return &Runner{Option: opt, Buffer: DefaultBuffer}
+}
+
+// Run 在后台开始扫描, 立即返回结果迭代器. 配置错误在扫描开始前返回
+// ctx取消时扫描停止, 迭代器在所有结果读取完毕后结束; 调用方需要读取结果直到结束, 或取消ctx
+func (r *Runner) Run(ctx context.Context) (*Results, error) {
+	running.Lock()
+	runner, err := r.prepare()
+	if err != nil {
+		running.Unlock()
+		return nil, err
+	}
+
+	results := &Results{
+		ch:   make(chan *Baseline, r.Buffer),
+		done: make(chan struct{}),
+	}
+	sink := &resultSink{ctx: ctx, results: results}
+	runner.Sinks = append(runner.Sinks, r.Sinks...)
+	runner.Sinks = append(runner.Sinks, sink)
+	go func() {
+		defer running.Unlock()
+		ctx, cancel := context.WithTimeout(ctx, time.Duration(runner.Deadline)*time.Second)
+		defer cancel()
+		results.err = runner.Prepare(ctx)
+		runner.Close()
+		close(results.ch)
+		close(results.done)
+	}()
+	return results, nil
+}
+
+func (r *Runner) prepare() (*internal.Runner, error) {
+	if err := r.Option.Prepare(); err != nil {
+		return nil, err
+	}
+	runner, err := r.Option.NewRunner()
+	if err != nil {
+		return nil, err
+	}
+	if r.Option.ReadAll || runner.CrawlPlugin {
+		ihttp.DefaultMaxBodySize = -1
+	}
+	return runner, nil
+}
+
+// Results 扫描结果的迭代器, 同时提供channel形式的读取, 两者只能选择一种
+type Results struct {
+	ch      chan *Baseline
+	done    chan struct{}
+	current *Baseline
+	stats   []*Statistor
+	locker  sync.Mutex
+	err     error
+}
+
+// Next 等待下一个结果, 扫描结束且结果读取完毕时返回false
+func (res *Results) Next() bool {
+	bl, ok := <-res.ch
+	res.current = bl
+	return ok
+}
+
+// Baseline 当前结果, 在Next返回true之后调用
+func (res *Results) Baseline() *Baseline {
+	return res.current
+}
+
+// Chan 结果channel, 扫描结束后关闭
+func (res *Results) Chan() <-chan *Baseline {
+	return res.ch
+}
+
+// Wait 等待扫描结束并返回扫描的错误, 调用前需要读取完所有结果
+func (res *Results) Wait() error {
+	<-res.done
+	return res.err
+}
+
+// Err 扫描的错误, 在Next返回false之后调用
+func (res *Results) Err() error {
+	return res.Wait()
+}
+
+// Stats 已经结束的目标的统计
+func (res *Results) Stats() []*Statistor {
+	res.locker.Lock()
+	defer res.locker.Unlock()
+	return append([]*Statistor(nil), res.stats...)
+}
+
+// resultSink 将结果写入Results, ctx取消后不再阻塞等待读取
+type resultSink struct {
+	ctx     context.Context
+	results *Results
+}
+
+func (s *resultSink) WriteBaseline(bl *pkg.Baseline) error {
+	select {
+	case s.results.ch <- bl:
+		return nil
+	case <-s.ctx.Done():
+		// 扫描已取消, 调用方不再读取结果
+		return nil
+	}
+}
+
+func (s *resultSink) WriteStat(stat *pkg.Statistor) error {
+	s.results.locker.Lock()
+	defer s.results.locker.Unlock()
+	s.results.stats = append(s.results.stats, stat)
+	return nil
+}
+
+func (s *resultSink) Close() error {
+	return nil
+}